@@ -0,0 +1,113 @@
+// Command migrate aplica, revierte o muestra las migraciones del esquema de la base
+// configurada con las mismas variables DB_* que la API:
+//
+//	go run ./cmd/migrate [-dry-run] [-steps N] up|down|status|force-unlock
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"transport-challenge/config"
+	"transport-challenge/internal/infrastructure/persistence"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "muestra el SQL que se ejecutaría sin modificar la base")
+	steps := flag.Int("steps", 0, "cantidad de migraciones a aplicar o revertir; en down por defecto 1")
+	lockTimeout := flag.Duration("lock-timeout", 30*time.Second, "espera máxima por el bloqueo de otro proceso")
+	staleLock := flag.Duration("stale-lock-after", 15*time.Minute, "antigüedad a partir de la cual se toma un bloqueo abandonado; 0 lo desactiva")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "uso: migrate [opciones] up|down|status|force-unlock")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	db, err := persistence.OpenDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	opts := []persistence.MigratorOption{persistence.WithLockTimeout(*lockTimeout), persistence.WithStaleLockAfter(*staleLock)}
+	if *dryRun {
+		opts = append(opts, persistence.WithDryRun(os.Stdout))
+	}
+	migrator := persistence.NewMigrator(db, cfg.Database.Driver, opts...)
+
+	switch command := flag.Arg(0); command {
+	case "up":
+		migrations, err := migrator.Up(*steps)
+		report("applied", migrations, *dryRun)
+		if err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
+	case "down":
+		if *steps == 0 {
+			*steps = 1
+		}
+		migrations, err := migrator.Down(*steps)
+		report("rolled back", migrations, *dryRun)
+		if err != nil {
+			log.Fatalf("Error rolling back migrations: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Error reading migration status: %v", err)
+		}
+		printStatus(statuses)
+	case "force-unlock":
+		if err := migrator.ForceUnlock(); err != nil {
+			log.Fatalf("Error releasing migration lock: %v", err)
+		}
+		log.Println("Migration lock released")
+	default:
+		log.Printf("Unknown command %q", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func report(action string, migrations []persistence.Migration, dryRun bool) {
+	if dryRun {
+		action = "would be " + action
+	}
+	if len(migrations) == 0 {
+		log.Println("No migrations " + action)
+		return
+	}
+	for _, migration := range migrations {
+		log.Printf("Migration %04d_%s %s", migration.Version, migration.Name, action)
+	}
+}
+
+func printStatus(statuses []persistence.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	w.Flush()
+}
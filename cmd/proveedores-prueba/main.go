@@ -0,0 +1,57 @@
+// Command proveedores-prueba levanta un servidor SMTP y una API push simulados para
+// desarrollo local. Los mensajes capturados y las fallas se manejan por la API de administración.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"transport-challenge/internal/notification/notificationtest"
+)
+
+func main() {
+	direccionSMTP := flag.String("smtp", "127.0.0.1:2525", "dirección del servidor SMTP")
+	seguridad := flag.String("seguridad", "NINGUNA", "seguridad SMTP: TLS, STARTTLS o NINGUNA")
+	usuario := flag.String("usuario", "", "usuario SMTP requerido; vacío no exige autenticación")
+	clave := flag.String("clave", "", "clave SMTP")
+	direccionPush := flag.String("push", "127.0.0.1:8089", "dirección de la API push")
+	claveAPI := flag.String("clave-api", "", "clave Bearer requerida por la API push")
+	direccionAdmin := flag.String("admin", "127.0.0.1:8090", "dirección de la API de administración")
+	flag.Parse()
+
+	smtp, err := notificationtest.IniciarServidorSMTP(notificationtest.ConfigServidorSMTP{
+		Direccion: *direccionSMTP,
+		TLS:       *seguridad == "TLS",
+		STARTTLS:  *seguridad == "STARTTLS",
+		Usuario:   *usuario,
+		Clave:     *clave,
+	})
+	if err != nil {
+		log.Fatalf("Error al iniciar el servidor SMTP: %v", err)
+	}
+	defer smtp.Cerrar()
+
+	push, err := notificationtest.IniciarServidorPush(*direccionPush, *claveAPI)
+	if err != nil {
+		log.Fatalf("Error al iniciar la API push: %v", err)
+	}
+	defer push.Cerrar()
+
+	go func() {
+		if err := http.ListenAndServe(*direccionAdmin, notificationtest.NuevoManejadorAdministracion(smtp, push)); err != nil {
+			log.Fatalf("Error en la API de administración: %v", err)
+		}
+	}()
+
+	log.Printf("SMTP simulado en %s (seguridad %s, CA en %s)", smtp.Direccion(), *seguridad, smtp.ArchivoCA())
+	log.Printf("API push simulada en %s", push.URL())
+	log.Printf("Administración en http://%s", *direccionAdmin)
+
+	senales := make(chan os.Signal, 1)
+	signal.Notify(senales, os.Interrupt, syscall.SIGTERM)
+	<-senales
+}
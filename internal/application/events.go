@@ -0,0 +1,36 @@
+package application
+
+import "transport-challenge/internal/domain"
+
+// EventPublisher publica eventos de dominio luego de cada escritura exitosa.
+// events.Bus satisface esta interfaz.
+type EventPublisher interface {
+	Publish(event domain.Event)
+}
+
+// publish entrega los eventos al publicador configurado, si lo hay
+func (s *RouteService) publish(events ...domain.Event) {
+	if s.publisher == nil {
+		return
+	}
+
+	for _, event := range events {
+		s.publisher.Publish(event)
+	}
+}
+
+// routeStatusChanged construye el evento de cambio de estado de una ruta
+func routeStatusChanged(route domain.Route, from domain.RouteStatus) domain.RouteStatusChanged {
+	event := domain.RouteStatusChanged{
+		RouteID: route.ID,
+		From:    from,
+		To:      route.Status,
+		At:      route.UpdatedAt,
+	}
+
+	if route.Status == domain.RouteStatusCancelled {
+		event.Reason = route.CancellationReason
+	}
+
+	return event
+}
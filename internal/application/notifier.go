@@ -0,0 +1,119 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"transport-challenge/internal/domain"
+	"transport-challenge/internal/notification"
+)
+
+// Notifier envía notificaciones a los destinatarios de las compras.
+// notification.ServicioNotificaciones satisface esta interfaz.
+type Notifier interface {
+	Notificar(notificacion notification.Notificacion) error
+}
+
+// purchaseNotificationTypes indica qué notificación corresponde a cada estado de compra
+var purchaseNotificationTypes = map[domain.PurchaseStatus]notification.TipoNotificacion{
+	domain.PurchaseStatusAssigned:      notification.NotificacionCompraEnRuta,
+	domain.PurchaseStatusDispatched:    notification.NotificacionCompraEnRuta,
+	domain.PurchaseStatusDelivered:     notification.NotificacionCompraEntregada,
+	domain.PurchaseStatusFailedAttempt: notification.NotificacionCompraEnError,
+}
+
+// Translator resuelve las claves de los catálogos de mensajes siguiendo la cadena de idiomas
+// del destinatario. notification.MotorPlantillas y notification.ServicioNotificaciones
+// satisfacen esta interfaz.
+type Translator interface {
+	Traducir(idioma, clave string, args ...interface{}) string
+}
+
+// purchaseDescriptionKey es la clave del catálogo que describe el estado de la compra
+func purchaseDescriptionKey(status domain.PurchaseStatus) string {
+	return "descripcion." + string(status)
+}
+
+// purchaseNotifications arma las notificaciones del estado actual de la compra: una para
+// su destinatario, si tiene, y una copia para cada despachante de WithDispatchers. No
+// devuelve ninguna si el estado no se notifica.
+func (s *RouteService) purchaseNotifications(route domain.Route, purchase domain.Purchase) []notification.Notificacion {
+	tipo, ok := purchaseNotificationTypes[purchase.Status]
+	if !ok {
+		return nil
+	}
+
+	notifications := make([]notification.Notificacion, 0, 1+len(s.dispatchers))
+	if purchase.Recipient != "" {
+		notifications = append(notifications, s.purchaseNotification(route, purchase, tipo, purchase.Recipient, purchase.RecipientLocale))
+	}
+	for _, dispatcher := range s.dispatchers {
+		notifications = append(notifications, s.purchaseNotification(route, purchase, tipo, dispatcher, ""))
+	}
+
+	return notifications
+}
+
+func (s *RouteService) purchaseNotification(route domain.Route, purchase domain.Purchase, tipo notification.TipoNotificacion, recipient, locale string) notification.Notificacion {
+	return notification.Notificacion{
+		Tipo:         tipo,
+		IDCompra:     purchase.ID,
+		Descripcion:  s.translator.Traducir(locale, purchaseDescriptionKey(purchase.Status), route.Name),
+		Destinatario: recipient,
+		Idioma:       locale,
+		Ruta: &notification.DatosRuta{
+			ID:        route.ID,
+			Nombre:    route.Name,
+			Conductor: route.Driver,
+			Vehiculo:  route.Vehicle,
+		},
+	}
+}
+
+// recordPurchaseNotification guarda las notificaciones de la compra en la bandeja de salida
+// del repositorio de la transacción en curso, de modo que se confirma junto con el cambio.
+// Sin WithOutbox no hace nada.
+func (s *RouteService) recordPurchaseNotification(repo domain.RouteRepository, route domain.Route, purchase domain.Purchase) error {
+	if !s.outbox {
+		return nil
+	}
+
+	notifications := s.purchaseNotifications(route, purchase)
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	outbox, ok := repo.(domain.OutboxStore)
+	if !ok {
+		return errors.New("transactional route repository does not store an outbox")
+	}
+
+	for _, notificacion := range notifications {
+		payload, err := json.Marshal(notificacion)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+
+		if _, err := outbox.AddOutboxMessage(payload); err != nil {
+			return fmt.Errorf("failed to record notification: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// notifyPurchaseStatus avisa al destinatario y a los despachantes el nuevo estado de la compra con el notificador.
+// Los errores de notificación se registran pero no revierten el cambio ya persistido; con
+// WithOutbox la notificación ya quedó en la bandeja de salida y no se envía aquí.
+func (s *RouteService) notifyPurchaseStatus(route domain.Route, purchase domain.Purchase) {
+	if s.notifier == nil || s.outbox {
+		return
+	}
+
+	for _, notificacion := range s.purchaseNotifications(route, purchase) {
+		if err := s.notifier.Notificar(notificacion); err != nil {
+			log.Printf("failed to notify purchase %d status %s to %s: %v", purchase.ID, purchase.Status, notificacion.Destinatario, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"transport-challenge/internal/domain"
+	"transport-challenge/internal/notification"
+)
+
+// outboxStatuses relaciona los estados de la bandeja de notificaciones con los del repositorio
+var outboxStatuses = map[notification.EstadoEntrada]domain.OutboxStatus{
+	notification.EntradaPendiente: domain.OutboxStatusPending,
+	notification.EntradaEnviada:   domain.OutboxStatusSent,
+	notification.EntradaFallida:   domain.OutboxStatusFailed,
+}
+
+// NotificationOutbox expone la bandeja de salida de un repositorio (domain.OutboxStore) como
+// notification.AlmacenBandeja, para que el despachador y la API de la bandeja trabajen con
+// notificaciones mientras el repositorio solo guarda mensajes serializados
+type NotificationOutbox struct {
+	store domain.OutboxStore
+}
+
+func NewNotificationOutbox(store domain.OutboxStore) *NotificationOutbox {
+	return &NotificationOutbox{store: store}
+}
+
+func (o *NotificationOutbox) Agregar(notificacion notification.Notificacion) (notification.EntradaBandeja, error) {
+	payload, err := json.Marshal(notificacion)
+	if err != nil {
+		return notification.EntradaBandeja{}, fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	message, err := o.store.AddOutboxMessage(payload)
+	if err != nil {
+		return notification.EntradaBandeja{}, err
+	}
+
+	return outboxEntry(message)
+}
+
+func (o *NotificationOutbox) Pendientes(ahora time.Time, limite int) ([]notification.EntradaBandeja, error) {
+	messages, err := o.store.PendingOutboxMessages(ahora, limite)
+	if err != nil {
+		return nil, err
+	}
+
+	return outboxEntries(messages)
+}
+
+func (o *NotificationOutbox) Actualizar(entrada notification.EntradaBandeja) error {
+	message, err := outboxMessage(entrada)
+	if err != nil {
+		return err
+	}
+
+	return o.store.UpdateOutboxMessage(message)
+}
+
+func (o *NotificationOutbox) Listar(estado notification.EstadoEntrada) ([]notification.EntradaBandeja, error) {
+	status, ok := outboxStatuses[estado]
+	if estado != "" && !ok {
+		return []notification.EntradaBandeja{}, nil
+	}
+
+	messages, err := o.store.ListOutboxMessages(status)
+	if err != nil {
+		return nil, err
+	}
+
+	return outboxEntries(messages)
+}
+
+func outboxEntries(messages []domain.OutboxMessage) ([]notification.EntradaBandeja, error) {
+	entries := make([]notification.EntradaBandeja, 0, len(messages))
+	for _, message := range messages {
+		entry, err := outboxEntry(message)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func outboxEntry(message domain.OutboxMessage) (notification.EntradaBandeja, error) {
+	entry := notification.EntradaBandeja{
+		ID:             message.ID,
+		Intentos:       message.Attempts,
+		UltimoError:    message.LastError,
+		ProximoIntento: message.NextAttemptAt,
+		CreadaEn:       message.CreatedAt,
+		ActualizadaEn:  message.UpdatedAt,
+	}
+
+	if err := json.Unmarshal(message.Payload, &entry.Notificacion); err != nil {
+		return notification.EntradaBandeja{}, fmt.Errorf("failed to decode notification of outbox message %d: %w", message.ID, err)
+	}
+
+	for estado, status := range outboxStatuses {
+		if status == message.Status {
+			entry.Estado = estado
+		}
+	}
+
+	for _, channel := range message.Channels {
+		entry.Canales = append(entry.Canales, notification.EnvioCanal{
+			Canal:      channel.Channel,
+			Omitido:    channel.Skipped,
+			Error:      channel.Error,
+			Definitivo: channel.Permanent,
+		})
+	}
+
+	return entry, nil
+}
+
+func outboxMessage(entry notification.EntradaBandeja) (domain.OutboxMessage, error) {
+	payload, err := json.Marshal(entry.Notificacion)
+	if err != nil {
+		return domain.OutboxMessage{}, fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	message := domain.OutboxMessage{
+		ID:            entry.ID,
+		Payload:       payload,
+		Status:        outboxStatuses[entry.Estado],
+		Attempts:      entry.Intentos,
+		LastError:     entry.UltimoError,
+		NextAttemptAt: entry.ProximoIntento,
+		CreatedAt:     entry.CreadaEn,
+		UpdatedAt:     entry.ActualizadaEn,
+	}
+
+	for _, envio := range entry.Canales {
+		message.Channels = append(message.Channels, domain.OutboxChannel{
+			Channel:   envio.Canal,
+			Skipped:   envio.Omitido,
+			Error:     envio.Error,
+			Permanent: envio.Definitivo,
+		})
+	}
+
+	return message, nil
+}
+
+var _ notification.AlmacenBandeja = &NotificationOutbox{}
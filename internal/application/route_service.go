@@ -4,16 +4,78 @@ import (
 	"fmt"
 	"time"
 	"transport-challenge/internal/domain"
+	"transport-challenge/internal/notification"
 )
 
 type RouteService struct {
-	routeRepo domain.RouteRepository
+	routeRepo   domain.RouteRepository
+	notifier    Notifier
+	publisher   EventPublisher
+	translator  Translator
+	dispatchers []string
+	outbox      bool
 }
 
-func NewRouteService(repo domain.RouteRepository) *RouteService {
-	return &RouteService{
-		routeRepo: repo,
+// RouteServiceOption configura dependencias opcionales del servicio
+type RouteServiceOption func(*RouteService)
+
+// WithNotifier define el notificador usado para avisar cambios en las compras
+func WithNotifier(notifier Notifier) RouteServiceOption {
+	return func(s *RouteService) {
+		s.notifier = notifier
+	}
+}
+
+// WithOutbox guarda las notificaciones de las compras en la bandeja de salida del
+// repositorio, dentro de la misma transacción que el cambio que las origina, en lugar de
+// enviarlas con el notificador. El repositorio debe implementar domain.OutboxStore y un
+// notification.DespachadorBandeja, con NewNotificationOutbox, se encarga de los envíos.
+func WithOutbox() RouteServiceOption {
+	return func(s *RouteService) {
+		s.outbox = true
+	}
+}
+
+// WithDispatchers envía a los despachantes indicados una copia de cada notificación de
+// compra. Para que reciban un único resumen por ruta, su notificador debe agruparlas
+// (ver notification.DerivadorResumen).
+func WithDispatchers(recipients ...string) RouteServiceOption {
+	return func(s *RouteService) {
+		s.dispatchers = append(s.dispatchers, recipients...)
+	}
+}
+
+// WithTranslator define los catálogos con que se describen los estados de las compras en
+// las notificaciones. Por defecto se usan los catálogos incluidos en notification.
+func WithTranslator(translator Translator) RouteServiceOption {
+	return func(s *RouteService) {
+		s.translator = translator
+	}
+}
+
+// WithEventPublisher define dónde se publican los eventos de dominio
+func WithEventPublisher(publisher EventPublisher) RouteServiceOption {
+	return func(s *RouteService) {
+		s.publisher = publisher
+	}
+}
+
+func NewRouteService(repo domain.RouteRepository, opts ...RouteServiceOption) *RouteService {
+	service := &RouteService{
+		routeRepo:  repo,
+		translator: notification.NuevoMotorPlantillas(),
+	}
+
+	for _, opt := range opts {
+		opt(service)
+	}
+
+	// Sin bandeja de salida cada cambio de compra fallaría al registrar su notificación
+	if _, ok := repo.(domain.OutboxStore); service.outbox && !ok {
+		panic(fmt.Sprintf("application: WithOutbox requires a repository that implements domain.OutboxStore, got %T", repo))
 	}
+
+	return service
 }
 
 // CreateRoute crea una nueva ruta con validaciones de negocio
@@ -24,6 +86,7 @@ func (s *RouteService) CreateRoute(route *domain.Route) (int, error) {
 	}
 
 	route.Status = domain.RouteStatusPending
+	route.Version = 1
 	route.CreatedAt = time.Now()
 	route.UpdatedAt = time.Now()
 
@@ -33,6 +96,10 @@ func (s *RouteService) CreateRoute(route *domain.Route) (int, error) {
 		return 0, fmt.Errorf("failed to create route: %w", err)
 	}
 
+	created := *route
+	created.ID = id
+	s.publish(domain.RouteCreated{Route: created, At: created.CreatedAt})
+
 	return id, nil
 }
 
@@ -46,27 +113,61 @@ func (s *RouteService) GetRouteByID(id int) (domain.Route, error) {
 	return route, nil
 }
 
-// UpdateRoute actualiza una ruta existente
+// UpdateRoute actualiza una ruta existente. Si route.Version no es cero se usa como
+// versión esperada y la actualización falla con un error de conflicto cuando la ruta
+// cambió desde entonces. Al terminar route.Version queda con la versión guardada.
 func (s *RouteService) UpdateRoute(id int, route *domain.Route) error {
 	// Validar la ruta
 	if err := route.Validate(); err != nil {
 		return err
 	}
 
-	existingRoute, err := s.routeRepo.GetByID(id)
+	var existingRoute domain.Route
+	var previousStatus domain.RouteStatus
+	err := s.withinTx(func(repo domain.RouteRepository) error {
+		var err error
+		existingRoute, err = repo.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+
+		if route.Version != 0 && route.Version != existingRoute.Version {
+			return domain.NewVersionConflictError(id, route.Version, existingRoute.Version)
+		}
+
+		previousStatus = existingRoute.Status
+
+		// Actualiza campos modificables
+		existingRoute.Name = route.Name
+		existingRoute.Vehicle = route.Vehicle
+		existingRoute.Driver = route.Driver
+		existingRoute.UpdatedAt = time.Now()
+
+		// El estado solo cambia a través de la tabla de transiciones y con las mismas
+		// validaciones que los métodos dedicados
+		if route.Status != "" && route.Status != existingRoute.Status {
+			existingRoute.CancellationReason = route.CancellationReason
+			if err := transitionRoute(&existingRoute, route.Status, existingRoute.UpdatedAt); err != nil {
+				return err
+			}
+		}
+
+		if err := repo.Update(id, existingRoute); err != nil {
+			return fmt.Errorf("failed to update route: %w", err)
+		}
+		existingRoute.Version++
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("route not found: %w", err)
+		return err
 	}
 
-	// Actualiza campos modificables
-	existingRoute.Name = route.Name
-	existingRoute.Vehicle = route.Vehicle
-	existingRoute.Driver = route.Driver
-	existingRoute.Status = route.Status
-	existingRoute.UpdatedAt = time.Now()
+	route.Version = existingRoute.Version
 
-	if err := s.routeRepo.Update(id, existingRoute); err != nil {
-		return fmt.Errorf("failed to update route: %w", err)
+	s.publish(domain.RouteUpdated{Route: existingRoute, At: existingRoute.UpdatedAt})
+	if existingRoute.Status != previousStatus {
+		s.publish(routeStatusChanged(existingRoute, previousStatus))
 	}
 
 	return nil
@@ -85,58 +186,337 @@ func (s *RouteService) GetRoutesByStatus(status domain.RouteStatus) ([]domain.Ro
 	return routes, nil
 }
 
-// AssignPurchaseToRoute asigna una compra a una ruta
+// AssignPurchaseToRoute asigna una compra a una ruta. La asignación y el paso de
+// la ruta a IN_PROGRESS se confirman juntos o no se aplica ninguno.
 func (s *RouteService) AssignPurchaseToRoute(routeID int, purchase domain.Purchase) error {
+	now := time.Now()
+
+	var route domain.Route
+	var previousStatus domain.RouteStatus
+	err := s.withinTx(func(repo domain.RouteRepository) error {
+		var err error
+		route, err = repo.GetByID(routeID)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+
+		if route.Status != domain.RouteStatusPending && route.Status != domain.RouteStatusInProgress {
+			return domain.NewDomainError(
+				domain.ErrorCodes.InvalidState,
+				fmt.Sprintf("cannot assign purchase to route with status %s", route.Status),
+				domain.ErrInvalidRouteStatus,
+			)
+		}
+
+		if err := purchase.Validate(); err != nil {
+			return err
+		}
+
+		if purchase.CreatedAt.IsZero() {
+			purchase.CreatedAt = now
+		}
+
+		// Toda compra asignada pasa de CREATED a ASSIGNED
+		if err := purchase.TransitionTo(domain.PurchaseStatusAssigned, now); err != nil {
+			return err
+		}
+
+		if err := repo.AssignPurchaseToRoute(routeID, purchase); err != nil {
+			return fmt.Errorf("failed to assign purchase to route: %w", err)
+		}
+
+		// La asignación cambia la versión de la ruta, por eso se vuelve a leer
+		route, err = repo.GetByID(routeID)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+		previousStatus = route.Status
+
+		if route.Status == domain.RouteStatusPending {
+			if err := route.TransitionTo(domain.RouteStatusInProgress, time.Now()); err != nil {
+				return err
+			}
+
+			if err := repo.Update(routeID, route); err != nil {
+				return fmt.Errorf("failed to update route status: %w", err)
+			}
+			route.Version++
+		}
+
+		return s.recordPurchaseNotification(repo, route, purchase)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(domain.PurchaseAssigned{RouteID: routeID, Purchase: purchase, At: now})
+	if route.Status != previousStatus {
+		s.publish(routeStatusChanged(route, previousStatus))
+	}
+
+	s.notifyPurchaseStatus(route, purchase)
+
+	return nil
+}
+
+// ListRoutePurchases recupera las compras asignadas a una ruta
+func (s *RouteService) ListRoutePurchases(routeID int) ([]domain.Purchase, error) {
 	route, err := s.routeRepo.GetByID(routeID)
 	if err != nil {
-		return fmt.Errorf("route not found: %w", err)
+		return nil, fmt.Errorf("route not found: %w", err)
+	}
+
+	if route.Purchases == nil {
+		return []domain.Purchase{}, nil
 	}
 
-	if route.Status != domain.RouteStatusPending && route.Status != domain.RouteStatusInProgress {
-		return fmt.Errorf("cannot assign purchase to route with status %s", route.Status)
+	return route.Purchases, nil
+}
+
+// GetRoutePurchase recupera una compra asignada a una ruta
+func (s *RouteService) GetRoutePurchase(routeID, purchaseID int) (domain.Purchase, error) {
+	route, err := s.routeRepo.GetByID(routeID)
+	if err != nil {
+		return domain.Purchase{}, fmt.Errorf("route not found: %w", err)
 	}
 
-	err = s.routeRepo.AssignPurchaseToRoute(routeID, purchase)
+	index, err := findPurchase(route, purchaseID)
 	if err != nil {
-		return fmt.Errorf("failed to assign purchase to route: %w", err)
+		return domain.Purchase{}, err
 	}
 
-	if route.Status == domain.RouteStatusPending {
-		route.Status = domain.RouteStatusInProgress
+	return route.Purchases[index], nil
+}
+
+// RemovePurchaseFromRoute quita de la ruta una compra que todavía no fue despachada
+func (s *RouteService) RemovePurchaseFromRoute(routeID, purchaseID int) error {
+	var route domain.Route
+	err := s.withinTx(func(repo domain.RouteRepository) error {
+		var err error
+		route, err = repo.GetByID(routeID)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+
+		if err := ensurePurchasesEditable(route); err != nil {
+			return err
+		}
+
+		index, err := findPurchase(route, purchaseID)
+		if err != nil {
+			return err
+		}
+
+		switch route.Purchases[index].CurrentStatus() {
+		case domain.PurchaseStatusCreated, domain.PurchaseStatusAssigned, domain.PurchaseStatusCancelled:
+		default:
+			return domain.NewDomainError(
+				domain.ErrorCodes.InvalidState,
+				fmt.Sprintf("cannot remove purchase %d with status %s", purchaseID, route.Purchases[index].Status),
+				domain.ErrInvalidPurchaseTransition,
+			)
+		}
+
+		route.Purchases = append(route.Purchases[:index:index], route.Purchases[index+1:]...)
 		route.UpdatedAt = time.Now()
 
-		if err := s.routeRepo.Update(routeID, route); err != nil {
-			return fmt.Errorf("failed to update route status: %w", err)
+		if err := repo.Update(routeID, route); err != nil {
+			return fmt.Errorf("failed to remove purchase from route: %w", err)
 		}
+		route.Version++
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	s.publish(domain.PurchaseRemoved{RouteID: routeID, PurchaseID: purchaseID, At: route.UpdatedAt})
+
 	return nil
 }
 
-func (s *RouteService) CompleteRoute(routeID int) error {
-	route, err := s.routeRepo.GetByID(routeID)
+// UpdatePurchaseStatus avanza el ciclo de vida de una compra asignada a una ruta
+func (s *RouteService) UpdatePurchaseStatus(routeID, purchaseID int, status domain.PurchaseStatus) (domain.Purchase, error) {
+	now := time.Now()
+
+	var route domain.Route
+	var index int
+	var previousStatus domain.PurchaseStatus
+	err := s.withinTx(func(repo domain.RouteRepository) error {
+		var err error
+		route, err = repo.GetByID(routeID)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+
+		if err := ensurePurchasesEditable(route); err != nil {
+			return err
+		}
+
+		index, err = findPurchase(route, purchaseID)
+		if err != nil {
+			return err
+		}
+
+		previousStatus = route.Purchases[index].CurrentStatus()
+		purchases := append([]domain.Purchase(nil), route.Purchases...)
+		if err := purchases[index].TransitionTo(status, now); err != nil {
+			return err
+		}
+
+		route.Purchases = purchases
+		route.UpdatedAt = now
+
+		if err := repo.Update(routeID, route); err != nil {
+			return fmt.Errorf("failed to update purchase status: %w", err)
+		}
+		route.Version++
+
+		return s.recordPurchaseNotification(repo, route, route.Purchases[index])
+	})
 	if err != nil {
-		return fmt.Errorf("route not found: %w", err)
+		return domain.Purchase{}, err
+	}
+
+	purchase := route.Purchases[index]
+	s.publish(domain.PurchaseStatusChanged{
+		RouteID:  routeID,
+		Purchase: purchase,
+		From:     previousStatus,
+		To:       status,
+		At:       now,
+	})
+	if status == domain.PurchaseStatusDelivered {
+		s.publish(domain.PurchaseDelivered{RouteID: routeID, Purchase: purchase, At: now})
 	}
 
-	allDelivered := true
+	s.notifyPurchaseStatus(route, purchase)
+
+	return purchase, nil
+}
+
+// findPurchase devuelve la posición de la compra dentro de la ruta
+func findPurchase(route domain.Route, purchaseID int) (int, error) {
+	for i, purchase := range route.Purchases {
+		if purchase.ID == purchaseID {
+			return i, nil
+		}
+	}
+
+	return 0, domain.NewDomainError(
+		domain.ErrorCodes.NotFound,
+		fmt.Sprintf("purchase %d not found in route %d", purchaseID, route.ID),
+		domain.ErrPurchaseNotFound,
+	)
+}
+
+// ensurePurchasesEditable verifica que la ruta admita cambios en sus compras
+func ensurePurchasesEditable(route domain.Route) error {
+	switch route.Status {
+	case domain.RouteStatusCompleted:
+		return domain.NewDomainError(
+			domain.ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d has already been completed", route.ID),
+			domain.ErrRouteAlreadyCompleted,
+		)
+	case domain.RouteStatusCancelled:
+		return domain.NewDomainError(
+			domain.ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d has been cancelled", route.ID),
+			domain.ErrInvalidRouteStatus,
+		)
+	}
+	return nil
+}
+
+// StartRoute inicia el recorrido de una ruta pendiente
+func (s *RouteService) StartRoute(routeID int) error {
+	return s.changeRouteStatus(routeID, func(route *domain.Route, now time.Time) error {
+		return route.TransitionTo(domain.RouteStatusInProgress, now)
+	})
+}
+
+// CancelRoute cancela una ruta registrando el motivo de la cancelación
+func (s *RouteService) CancelRoute(routeID int, reason string) error {
+	return s.changeRouteStatus(routeID, func(route *domain.Route, now time.Time) error {
+		return route.Cancel(reason, now)
+	})
+}
+
+// ReopenRoute vuelve a dejar pendiente una ruta cancelada
+func (s *RouteService) ReopenRoute(routeID int) error {
+	return s.changeRouteStatus(routeID, func(route *domain.Route, now time.Time) error {
+		return route.TransitionTo(domain.RouteStatusPending, now)
+	})
+}
+
+// CompleteRoute completa una ruta cuando todas sus compras fueron entregadas
+func (s *RouteService) CompleteRoute(routeID int) error {
+	return s.changeRouteStatus(routeID, completeRoute)
+}
+
+// completeRoute completa la ruta si todas sus compras fueron entregadas
+func completeRoute(route *domain.Route, now time.Time) error {
 	for _, purchase := range route.Purchases {
-		if purchase.Status != "DELIVERED" {
-			allDelivered = false
-			break
+		if purchase.Status != domain.PurchaseStatusDelivered {
+			return domain.NewDomainError(
+				domain.ErrorCodes.InvalidState,
+				"cannot complete route: not all purchases are delivered",
+				domain.ErrInvalidRouteStatus,
+			)
 		}
 	}
 
-	if !allDelivered {
-		return fmt.Errorf("cannot complete route: not all purchases are delivered")
+	return route.TransitionTo(domain.RouteStatusCompleted, now)
+}
+
+// transitionRoute lleva la ruta al estado indicado aplicando las validaciones de
+// los métodos dedicados, por ejemplo las compras entregadas al completarla
+func transitionRoute(route *domain.Route, status domain.RouteStatus, now time.Time) error {
+	if status == domain.RouteStatusCompleted {
+		return completeRoute(route, now)
 	}
+	return route.TransitionTo(status, now)
+}
 
-	route.Status = domain.RouteStatusCompleted
-	route.UpdatedAt = time.Now()
+// changeRouteStatus recupera la ruta, aplica el cambio de estado y la persiste
+func (s *RouteService) changeRouteStatus(routeID int, change func(route *domain.Route, now time.Time) error) error {
+	var route domain.Route
+	var previousStatus domain.RouteStatus
+	err := s.withinTx(func(repo domain.RouteRepository) error {
+		var err error
+		route, err = repo.GetByID(routeID)
+		if err != nil {
+			return fmt.Errorf("route not found: %w", err)
+		}
+
+		previousStatus = route.Status
+		if err := change(&route, time.Now()); err != nil {
+			return err
+		}
+
+		if err := repo.Update(routeID, route); err != nil {
+			return fmt.Errorf("failed to update route status: %w", err)
+		}
+		route.Version++
 
-	if err := s.routeRepo.Update(routeID, route); err != nil {
-		return fmt.Errorf("failed to complete route: %w", err)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	s.publish(routeStatusChanged(route, previousStatus))
+
 	return nil
 }
+
+// withinTx ejecuta fn de forma atómica si el repositorio lo soporta; si no, fn
+// corre directamente sobre el repositorio
+func (s *RouteService) withinTx(fn func(repo domain.RouteRepository) error) error {
+	if transactor, ok := s.routeRepo.(domain.RouteTransactor); ok {
+		return transactor.WithinTx(fn)
+	}
+	return fn(s.routeRepo)
+}
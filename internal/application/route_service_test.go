@@ -0,0 +1,319 @@
+package application
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"transport-challenge/internal/domain"
+	"transport-challenge/internal/infrastructure/persistence"
+	"transport-challenge/internal/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNotifier registra las notificaciones recibidas para verificarlas en las pruebas
+type fakeNotifier struct {
+	sent []notification.Notificacion
+	err  error
+}
+
+func (f *fakeNotifier) Notificar(notificacion notification.Notificacion) error {
+	f.sent = append(f.sent, notificacion)
+	return f.err
+}
+
+func newServiceWithRoute(t *testing.T, opts ...RouteServiceOption) (*RouteService, int) {
+	t.Helper()
+
+	service := NewRouteService(persistence.NewRouteRepository(), opts...)
+	routeID, err := service.CreateRoute(&domain.Route{
+		Name:    "Zona Norte",
+		Vehicle: "ABC-123",
+		Driver:  "Julián",
+	})
+	assert.NoError(t, err)
+
+	return service, routeID
+}
+
+func TestAssignPurchaseNotifiesInRoute(t *testing.T) {
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier))
+
+	err := service.AssignPurchaseToRoute(routeID, domain.Purchase{
+		ID:          10,
+		Description: "Heladera",
+		Recipient:   "cliente@ejemplo.com",
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, notifier.sent, 1)
+	assert.Equal(t, notification.NotificacionCompraEnRuta, notifier.sent[0].Tipo)
+	assert.Equal(t, 10, notifier.sent[0].IDCompra)
+	assert.Equal(t, "cliente@ejemplo.com", notifier.sent[0].Destinatario)
+}
+
+func TestUpdatePurchaseStatusNotifiesEachStep(t *testing.T) {
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "cliente@ejemplo.com"}))
+
+	steps := []struct {
+		status domain.PurchaseStatus
+		tipo   notification.TipoNotificacion
+	}{
+		{domain.PurchaseStatusDispatched, notification.NotificacionCompraEnRuta},
+		{domain.PurchaseStatusFailedAttempt, notification.NotificacionCompraEnError},
+		{domain.PurchaseStatusDispatched, notification.NotificacionCompraEnRuta},
+		{domain.PurchaseStatusDelivered, notification.NotificacionCompraEntregada},
+	}
+
+	for i, step := range steps {
+		_, err := service.UpdatePurchaseStatus(routeID, 10, step.status)
+		assert.NoError(t, err)
+		assert.Len(t, notifier.sent, i+2)
+		assert.Equal(t, step.tipo, notifier.sent[i+1].Tipo)
+	}
+}
+
+func TestRejectedTransitionDoesNotNotify(t *testing.T) {
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "cliente@ejemplo.com"}))
+
+	_, err := service.UpdatePurchaseStatus(routeID, 10, domain.PurchaseStatusDelivered)
+
+	assert.True(t, domain.IsInvalidStateError(err))
+	assert.Len(t, notifier.sent, 1)
+}
+
+func TestNotifierFailureDoesNotFailOperation(t *testing.T) {
+	notifier := &fakeNotifier{err: errors.New("smtp caído")}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier))
+
+	err := service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "cliente@ejemplo.com"})
+
+	assert.NoError(t, err)
+	assert.Len(t, notifier.sent, 1)
+
+	purchase, err := service.GetRoutePurchase(routeID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PurchaseStatusAssigned, purchase.Status)
+}
+
+// recordingPublisher registra los eventos publicados por el servicio
+type recordingPublisher struct {
+	events []domain.Event
+}
+
+func (p *recordingPublisher) Publish(event domain.Event) {
+	p.events = append(p.events, event)
+}
+
+func (p *recordingPublisher) names() []string {
+	names := make([]string, 0, len(p.events))
+	for _, event := range p.events {
+		names = append(names, event.EventName())
+	}
+	return names
+}
+
+func TestRouteServicePublishesEvents(t *testing.T) {
+	publisher := &recordingPublisher{}
+	service, routeID := newServiceWithRoute(t, WithEventPublisher(publisher))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10}))
+	_, err := service.UpdatePurchaseStatus(routeID, 10, domain.PurchaseStatusDispatched)
+	assert.NoError(t, err)
+	_, err = service.UpdatePurchaseStatus(routeID, 10, domain.PurchaseStatusDelivered)
+	assert.NoError(t, err)
+	assert.NoError(t, service.CompleteRoute(routeID))
+
+	assert.Equal(t, []string{
+		domain.EventRouteCreated,
+		domain.EventPurchaseAssigned,
+		domain.EventRouteStatusChanged,
+		domain.EventPurchaseStatusChanged,
+		domain.EventPurchaseStatusChanged,
+		domain.EventPurchaseDelivered,
+		domain.EventRouteStatusChanged,
+	}, publisher.names())
+
+	completed := publisher.events[len(publisher.events)-1].(domain.RouteStatusChanged)
+	assert.Equal(t, domain.RouteStatusInProgress, completed.From)
+	assert.Equal(t, domain.RouteStatusCompleted, completed.To)
+}
+
+func TestFailedOperationPublishesNothing(t *testing.T) {
+	publisher := &recordingPublisher{}
+	service, routeID := newServiceWithRoute(t, WithEventPublisher(publisher))
+
+	assert.Error(t, service.CancelRoute(routeID, ""))
+
+	assert.Equal(t, []string{domain.EventRouteCreated}, publisher.names())
+}
+
+func TestNotificationUsesRecipientLocale(t *testing.T) {
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "cliente@ejemplo.com", RecipientLocale: "pt-BR"}))
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 11, Recipient: "customer@example.com", RecipientLocale: "fr"}))
+
+	assert.Len(t, notifier.sent, 2)
+	assert.Equal(t, "pt-BR", notifier.sent[0].Idioma)
+	assert.True(t, strings.HasPrefix(notifier.sent[0].Descripcion, "Sua compra foi atribuída"))
+	assert.True(t, strings.HasPrefix(notifier.sent[1].Descripcion, "Tu compra fue asignada"))
+	assert.Equal(t, "Zona Norte", notifier.sent[0].Ruta.Nombre)
+}
+
+func TestDispatchersReceiveACopyOfEachPurchaseNotification(t *testing.T) {
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier), WithDispatchers("despacho@ejemplo.com"))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "cliente@ejemplo.com", RecipientLocale: "en"}))
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 11}))
+
+	assert.Len(t, notifier.sent, 3)
+	assert.Equal(t, "cliente@ejemplo.com", notifier.sent[0].Destinatario)
+	assert.Equal(t, "despacho@ejemplo.com", notifier.sent[1].Destinatario)
+	assert.Equal(t, 10, notifier.sent[1].IDCompra)
+	assert.True(t, strings.HasPrefix(notifier.sent[1].Descripcion, "Tu compra fue asignada"))
+	assert.Equal(t, "despacho@ejemplo.com", notifier.sent[2].Destinatario)
+	assert.Equal(t, 11, notifier.sent[2].IDCompra)
+}
+
+func TestNotificationDescriptionsComeFromTheCatalogs(t *testing.T) {
+	dir := t.TempDir()
+	catalog := `{"descripcion.ASSIGNED": "Votre commande a été assignée à la tournée %q"}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "mensajes.fr.json"), []byte(catalog), 0o644))
+	templates, err := notification.CargarMotorPlantillas(dir)
+	assert.NoError(t, err)
+
+	notifier := &fakeNotifier{}
+	service, routeID := newServiceWithRoute(t, WithNotifier(notifier), WithTranslator(templates))
+
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Recipient: "client@exemple.fr", RecipientLocale: "fr-CA"}))
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 11, Recipient: "customer@example.com", RecipientLocale: "en"}))
+
+	assert.Len(t, notifier.sent, 2)
+	assert.Equal(t, `Votre commande a été assignée à la tournée "Zona Norte"`, notifier.sent[0].Descripcion)
+	assert.Equal(t, `Your order was assigned to route "Zona Norte"`, notifier.sent[1].Descripcion)
+}
+
+// failingUpdateRepository hace fallar Update dentro de las transacciones del repositorio en memoria
+type failingUpdateRepository struct {
+	*persistence.InMemoryRouteRepository
+}
+
+type failingUpdateTx struct {
+	domain.RouteRepository
+}
+
+func (failingUpdateTx) Update(int, domain.Route) error {
+	return errors.New("disk full")
+}
+
+func (r failingUpdateRepository) WithinTx(fn func(repo domain.RouteRepository) error) error {
+	return r.InMemoryRouteRepository.WithinTx(func(repo domain.RouteRepository) error {
+		return fn(failingUpdateTx{repo})
+	})
+}
+
+func TestAssignPurchaseRollsBackWhenRouteUpdateFails(t *testing.T) {
+	repo := failingUpdateRepository{persistence.NewRouteRepository()}
+	publisher := &recordingPublisher{}
+	service := NewRouteService(repo, WithEventPublisher(publisher))
+	routeID, err := service.CreateRoute(&domain.Route{Name: "Zona Norte", Vehicle: "ABC-123", Driver: "Julián"})
+	assert.NoError(t, err)
+
+	err = service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Description: "Heladera"})
+	assert.Error(t, err)
+
+	route, err := repo.GetByID(routeID)
+	assert.NoError(t, err)
+	assert.Empty(t, route.Purchases)
+	assert.Equal(t, domain.RouteStatusPending, route.Status)
+	assert.Equal(t, []string{domain.EventRouteCreated}, publisher.names())
+}
+
+func TestUpdateRouteRejectsStaleVersion(t *testing.T) {
+	service, routeID := newServiceWithRoute(t)
+
+	err := service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Description: "Heladera"})
+	assert.NoError(t, err)
+
+	stale := &domain.Route{Name: "Zona Sur", Vehicle: "ABC-123", Driver: "Julián", Version: 1}
+	err = service.UpdateRoute(routeID, stale)
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+	route, err := service.GetRouteByID(routeID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Zona Norte", route.Name)
+
+	current := &domain.Route{Name: "Zona Sur", Vehicle: "ABC-123", Driver: "Julián", Version: route.Version}
+	assert.NoError(t, service.UpdateRoute(routeID, current))
+	assert.Equal(t, route.Version+1, current.Version)
+}
+
+func TestOutboxRecordsNotificationWithTheChange(t *testing.T) {
+	repo := persistence.NewRouteRepository()
+	notifier := &fakeNotifier{}
+	service := NewRouteService(repo, WithOutbox(), WithNotifier(notifier))
+	routeID, err := service.CreateRoute(&domain.Route{Name: "Zona Norte", Vehicle: "ABC-123", Driver: "Julián"})
+	assert.NoError(t, err)
+
+	err = service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Description: "Heladera", Recipient: "cliente@ejemplo.com"})
+	assert.NoError(t, err)
+
+	outbox := NewNotificationOutbox(repo)
+	entries, err := outbox.Listar(notification.EntradaPendiente)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, notification.NotificacionCompraEnRuta, entries[0].Notificacion.Tipo)
+	assert.Equal(t, 10, entries[0].Notificacion.IDCompra)
+	assert.Empty(t, notifier.sent, "with an outbox the dispatcher sends the notification")
+
+	entry := entries[0]
+	entry.Estado = notification.EntradaFallida
+	entry.Intentos = 1
+	entry.Canales = []notification.EnvioCanal{{Canal: notification.CanalSMS, Error: "número inválido", Definitivo: true}}
+	assert.NoError(t, outbox.Actualizar(entry))
+
+	failed, err := outbox.Listar(notification.EntradaFallida)
+	assert.NoError(t, err)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, entry.Canales, failed[0].Canales)
+	assert.Equal(t, entry.Notificacion, failed[0].Notificacion)
+
+	pending, err := outbox.Listar(notification.EntradaPendiente)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestWithOutboxRequiresAnOutboxStore(t *testing.T) {
+	// Embeber la interfaz oculta los métodos de la bandeja de salida del repositorio
+	repo := struct{ domain.RouteRepository }{persistence.NewRouteRepository()}
+
+	assert.Panics(t, func() { NewRouteService(repo, WithOutbox()) })
+	assert.NotPanics(t, func() { NewRouteService(repo) })
+}
+
+func TestUpdateRouteCannotCompleteWithUndeliveredPurchases(t *testing.T) {
+	service, routeID := newServiceWithRoute(t)
+	err := service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 10, Description: "Heladera"})
+	assert.NoError(t, err)
+
+	err = service.UpdateRoute(routeID, &domain.Route{Name: "Zona Norte", Vehicle: "ABC-123", Driver: "Julián", Status: domain.RouteStatusCompleted})
+	assert.ErrorIs(t, err, domain.ErrInvalidRouteStatus)
+	assert.True(t, domain.IsInvalidStateError(err))
+
+	route, err := service.GetRouteByID(routeID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RouteStatusInProgress, route.Status)
+}
@@ -17,12 +17,19 @@ var (
 	ErrInvalidDriver         = errors.New("driver information is required")
 	ErrRouteAlreadyCompleted = errors.New("route has already been completed")
 	ErrInvalidRouteStatus    = errors.New("invalid route status")
+
+	ErrCancellationReasonRequired = errors.New("cancellation reason is required")
+	ErrVersionConflict            = errors.New("route was modified concurrently")
 )
 
 // Errores específicos de Compra
 var (
 	ErrInvalidPurchaseID     = errors.New("purchase ID is invalid")
 	ErrPurchaseAlreadyExists = errors.New("purchase already exists in route")
+	ErrPurchaseNotFound      = errors.New("purchase not found in route")
+
+	ErrInvalidPurchaseStatus     = errors.New("invalid purchase status")
+	ErrInvalidPurchaseTransition = errors.New("purchase status transition is not allowed")
 )
 
 // DomainError error personalizado para errores de dominio
@@ -51,28 +58,61 @@ func NewDomainError(code, message string, err error) *DomainError {
 	}
 }
 
+// NewVersionConflictError indica que la ruta cambió desde que se leyó la versión esperada
+func NewVersionConflictError(routeID, expected, current int) *DomainError {
+	return NewDomainError(
+		ErrorCodes.Conflict,
+		fmt.Sprintf("Route %d was modified: expected version %d, current version %d", routeID, expected, current),
+		ErrVersionConflict,
+	)
+}
+
 // ErrorCodes define códigos de error
 var ErrorCodes = struct {
 	NotFound            string
 	ValidationError     string
 	AlreadyExists       string
 	InvalidState        string
+	Conflict            string
 	InternalServerError string
 }{
 	NotFound:            "NOT_FOUND",
 	ValidationError:     "VALIDATION_ERROR",
 	AlreadyExists:       "ALREADY_EXISTS",
 	InvalidState:        "INVALID_STATE",
+	Conflict:            "CONFLICT",
 	InternalServerError: "INTERNAL_SERVER_ERROR",
 }
 
 // IsNotFoundError verifica si el error es de tipo "no encontrado"
 func IsNotFoundError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) && domainErr.Code == ErrorCodes.NotFound {
+		return true
+	}
 	return errors.Is(err, ErrNotFound)
 }
 
+// IsAlreadyExistsError verifica si el error indica un elemento duplicado
+func IsAlreadyExistsError(err error) bool {
+	var domainErr *DomainError
+	return errors.As(err, &domainErr) && domainErr.Code == ErrorCodes.AlreadyExists
+}
+
 // IsValidationError verifica si el error es de validación
 func IsValidationError(err error) bool {
 	var domainErr *DomainError
 	return errors.As(err, &domainErr) && domainErr.Code == ErrorCodes.ValidationError
 }
+
+// IsInvalidStateError verifica si el error corresponde a un estado no válido
+func IsInvalidStateError(err error) bool {
+	var domainErr *DomainError
+	return errors.As(err, &domainErr) && domainErr.Code == ErrorCodes.InvalidState
+}
+
+// IsConflictError verifica si el error corresponde a una escritura sobre una versión desactualizada
+func IsConflictError(err error) bool {
+	var domainErr *DomainError
+	return errors.As(err, &domainErr) && domainErr.Code == ErrorCodes.Conflict
+}
@@ -0,0 +1,108 @@
+package domain
+
+import "time"
+
+// Nombres de los eventos de dominio publicados por el servicio de rutas
+const (
+	EventRouteCreated          = "route.created"
+	EventRouteUpdated          = "route.updated"
+	EventRouteStatusChanged    = "route.status_changed"
+	EventPurchaseAssigned      = "purchase.assigned"
+	EventPurchaseRemoved       = "purchase.removed"
+	EventPurchaseStatusChanged = "purchase.status_changed"
+	EventPurchaseDelivered     = "purchase.delivered"
+)
+
+// Event representa un hecho ocurrido en el dominio
+type Event interface {
+	// EventName identifica el tipo de evento
+	EventName() string
+
+	// OccurredAt indica cuándo ocurrió el evento
+	OccurredAt() time.Time
+}
+
+// RouteCreated se publica al crear una ruta
+type RouteCreated struct {
+	Route Route     `json:"route"`
+	At    time.Time `json:"at"`
+}
+
+func (e RouteCreated) EventName() string     { return EventRouteCreated }
+func (e RouteCreated) OccurredAt() time.Time { return e.At }
+
+// RouteUpdated se publica al modificar los datos de una ruta
+type RouteUpdated struct {
+	Route Route     `json:"route"`
+	At    time.Time `json:"at"`
+}
+
+func (e RouteUpdated) EventName() string     { return EventRouteUpdated }
+func (e RouteUpdated) OccurredAt() time.Time { return e.At }
+
+// RouteStatusChanged se publica cuando una ruta cambia de estado
+type RouteStatusChanged struct {
+	RouteID int         `json:"route_id"`
+	From    RouteStatus `json:"from"`
+	To      RouteStatus `json:"to"`
+	Reason  string      `json:"reason,omitempty"`
+	At      time.Time   `json:"at"`
+}
+
+func (e RouteStatusChanged) EventName() string     { return EventRouteStatusChanged }
+func (e RouteStatusChanged) OccurredAt() time.Time { return e.At }
+
+// PurchaseAssigned se publica al asignar una compra a una ruta
+type PurchaseAssigned struct {
+	RouteID  int       `json:"route_id"`
+	Purchase Purchase  `json:"purchase"`
+	At       time.Time `json:"at"`
+}
+
+func (e PurchaseAssigned) EventName() string     { return EventPurchaseAssigned }
+func (e PurchaseAssigned) OccurredAt() time.Time { return e.At }
+
+// PurchaseRemoved se publica al quitar una compra de una ruta
+type PurchaseRemoved struct {
+	RouteID    int       `json:"route_id"`
+	PurchaseID int       `json:"purchase_id"`
+	At         time.Time `json:"at"`
+}
+
+func (e PurchaseRemoved) EventName() string     { return EventPurchaseRemoved }
+func (e PurchaseRemoved) OccurredAt() time.Time { return e.At }
+
+// PurchaseStatusChanged se publica en cada transición del ciclo de vida de una compra
+type PurchaseStatusChanged struct {
+	RouteID  int            `json:"route_id"`
+	Purchase Purchase       `json:"purchase"`
+	From     PurchaseStatus `json:"from"`
+	To       PurchaseStatus `json:"to"`
+	At       time.Time      `json:"at"`
+}
+
+func (e PurchaseStatusChanged) EventName() string     { return EventPurchaseStatusChanged }
+func (e PurchaseStatusChanged) OccurredAt() time.Time { return e.At }
+
+// PurchaseDelivered se publica cuando una compra es entregada
+type PurchaseDelivered struct {
+	RouteID  int       `json:"route_id"`
+	Purchase Purchase  `json:"purchase"`
+	At       time.Time `json:"at"`
+}
+
+func (e PurchaseDelivered) EventName() string     { return EventPurchaseDelivered }
+func (e PurchaseDelivered) OccurredAt() time.Time { return e.At }
+
+// EventNames devuelve los nombres de todos los eventos de dominio
+func EventNames() []string {
+	return []string{
+		EventRouteCreated,
+		EventRouteUpdated,
+		EventRouteStatusChanged,
+		EventPurchaseAssigned,
+		EventPurchaseRemoved,
+		EventPurchaseStatusChanged,
+		EventPurchaseDelivered,
+	}
+}
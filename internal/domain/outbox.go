@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxStatus representa el estado de entrega de un mensaje de la bandeja de salida
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	OutboxStatusSent    OutboxStatus = "SENT"
+	OutboxStatusFailed  OutboxStatus = "FAILED"
+)
+
+// OutboxMessage es un mensaje registrado junto con el cambio que lo origina, pendiente
+// de entrega. Payload es el mensaje serializado y el repositorio no lo interpreta.
+type OutboxMessage struct {
+	ID            int             `json:"id"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	Channels      []OutboxChannel `json:"channels,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// OutboxChannel es el último resultado de la entrega de un mensaje por un canal
+type OutboxChannel struct {
+	Channel   string `json:"channel"`
+	Skipped   string `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Permanent bool   `json:"permanent,omitempty"`
+}
+
+// OutboxStore guarda la bandeja de salida (outbox). Los repositorios que también implementan
+// RouteTransactor confirman los mensajes agregados dentro de WithinTx junto con los cambios
+// de las rutas.
+type OutboxStore interface {
+	// AddOutboxMessage registra un mensaje pendiente de entrega
+	AddOutboxMessage(payload []byte) (OutboxMessage, error)
+
+	// PendingOutboxMessages devuelve, ordenados por ID, los mensajes pendientes cuyo
+	// próximo intento ya llegó; limit cero no limita la cantidad
+	PendingOutboxMessages(now time.Time, limit int) ([]OutboxMessage, error)
+
+	// UpdateOutboxMessage guarda el resultado de un intento de entrega
+	UpdateOutboxMessage(message OutboxMessage) error
+
+	// ListOutboxMessages devuelve los mensajes en el estado indicado, o todos si es vacío
+	ListOutboxMessages(status OutboxStatus) ([]OutboxMessage, error)
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// PurchaseStatus representa el estado actual de una compra
+type PurchaseStatus string
+
+// Definición de estados posibles para una compra
+const (
+	PurchaseStatusCreated       PurchaseStatus = "CREATED"
+	PurchaseStatusAssigned      PurchaseStatus = "ASSIGNED"
+	PurchaseStatusDispatched    PurchaseStatus = "DISPATCHED"
+	PurchaseStatusDelivered     PurchaseStatus = "DELIVERED"
+	PurchaseStatusFailedAttempt PurchaseStatus = "FAILED_ATTEMPT"
+	PurchaseStatusReturned      PurchaseStatus = "RETURNED"
+	PurchaseStatusCancelled     PurchaseStatus = "CANCELLED"
+)
+
+// purchaseTransitions define los cambios de estado permitidos para una compra.
+// Los estados sin entrada (DELIVERED, RETURNED, CANCELLED) son finales.
+var purchaseTransitions = map[PurchaseStatus][]PurchaseStatus{
+	PurchaseStatusCreated:       {PurchaseStatusAssigned, PurchaseStatusCancelled},
+	PurchaseStatusAssigned:      {PurchaseStatusDispatched, PurchaseStatusCancelled},
+	PurchaseStatusDispatched:    {PurchaseStatusDelivered, PurchaseStatusFailedAttempt},
+	PurchaseStatusFailedAttempt: {PurchaseStatusDispatched, PurchaseStatusReturned},
+}
+
+// IsValid indica si el estado es uno de los estados conocidos
+func (s PurchaseStatus) IsValid() bool {
+	switch s {
+	case PurchaseStatusCreated,
+		PurchaseStatusAssigned,
+		PurchaseStatusDispatched,
+		PurchaseStatusDelivered,
+		PurchaseStatusFailedAttempt,
+		PurchaseStatusReturned,
+		PurchaseStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsFinal indica si el estado ya no admite transiciones
+func (s PurchaseStatus) IsFinal() bool {
+	return s.IsValid() && len(purchaseTransitions[s]) == 0
+}
+
+// CanTransitionTo indica si la transición desde el estado actual está permitida
+func (s PurchaseStatus) CanTransitionTo(next PurchaseStatus) bool {
+	for _, allowed := range purchaseTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// PurchaseStatusChange registra una transición de estado de una compra
+type PurchaseStatusChange struct {
+	From PurchaseStatus `json:"from"`
+	To   PurchaseStatus `json:"to"`
+	At   time.Time      `json:"at"`
+}
+
+// Purchase representa una compra asociada a una ruta. RecipientLocale es el idioma
+// del destinatario (por ejemplo "es", "pt-BR" o "en") usado en sus notificaciones.
+type Purchase struct {
+	ID              int                    `json:"id"`
+	Description     string                 `json:"description"`
+	Recipient       string                 `json:"recipient,omitempty"`
+	RecipientLocale string                 `json:"recipient_locale,omitempty"`
+	Status          PurchaseStatus         `json:"status"`
+	History         []PurchaseStatusChange `json:"history,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// NewPurchase crea una compra en estado CREATED
+func NewPurchase(id int, description string, at time.Time) Purchase {
+	return Purchase{
+		ID:          id,
+		Description: description,
+		Status:      PurchaseStatusCreated,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+	}
+}
+
+// Validate realiza validaciones de negocio para una compra
+func (p *Purchase) Validate() error {
+	if p.ID <= 0 {
+		return NewDomainError(
+			ErrorCodes.ValidationError,
+			"Purchase ID must be a positive number",
+			ErrInvalidPurchaseID,
+		)
+	}
+
+	if p.Status != "" && !p.Status.IsValid() {
+		return NewDomainError(
+			ErrorCodes.ValidationError,
+			fmt.Sprintf("Unknown purchase status %q", p.Status),
+			ErrInvalidPurchaseStatus,
+		)
+	}
+
+	return nil
+}
+
+// CurrentStatus devuelve el estado de la compra, considerando CREATED
+// cuando el estado todavía no fue informado
+func (p *Purchase) CurrentStatus() PurchaseStatus {
+	if p.Status == "" {
+		return PurchaseStatusCreated
+	}
+	return p.Status
+}
+
+// TransitionTo cambia el estado de la compra si la transición está permitida
+// y registra el momento en que ocurrió
+func (p *Purchase) TransitionTo(next PurchaseStatus, at time.Time) error {
+	if !next.IsValid() {
+		return NewDomainError(
+			ErrorCodes.ValidationError,
+			fmt.Sprintf("Unknown purchase status %q", next),
+			ErrInvalidPurchaseStatus,
+		)
+	}
+
+	current := p.CurrentStatus()
+	if !current.CanTransitionTo(next) {
+		return NewDomainError(
+			ErrorCodes.InvalidState,
+			fmt.Sprintf("Purchase %d cannot change from %s to %s", p.ID, current, next),
+			ErrInvalidPurchaseTransition,
+		)
+	}
+
+	p.History = append(p.History, PurchaseStatusChange{
+		From: current,
+		To:   next,
+		At:   at,
+	})
+	p.Status = next
+	p.UpdatedAt = at
+
+	return nil
+}
+
+// StatusChangedAt devuelve el momento de la última transición hacia el estado indicado
+func (p *Purchase) StatusChangedAt(status PurchaseStatus) (time.Time, bool) {
+	for i := len(p.History) - 1; i >= 0; i-- {
+		if p.History[i].To == status {
+			return p.History[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurchaseTransitionTo_HappyPath(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	purchase := NewPurchase(1, "Heladera", start)
+
+	steps := []PurchaseStatus{
+		PurchaseStatusAssigned,
+		PurchaseStatusDispatched,
+		PurchaseStatusFailedAttempt,
+		PurchaseStatusDispatched,
+		PurchaseStatusDelivered,
+	}
+
+	for i, status := range steps {
+		at := start.Add(time.Duration(i+1) * time.Hour)
+		assert.NoError(t, purchase.TransitionTo(status, at))
+		assert.Equal(t, status, purchase.Status)
+		assert.Equal(t, at, purchase.UpdatedAt)
+	}
+
+	assert.Len(t, purchase.History, len(steps))
+	assert.Equal(t, PurchaseStatusCreated, purchase.History[0].From)
+	assert.True(t, purchase.Status.IsFinal())
+
+	deliveredAt, ok := purchase.StatusChangedAt(PurchaseStatusDelivered)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(5*time.Hour), deliveredAt)
+
+	dispatchedAt, ok := purchase.StatusChangedAt(PurchaseStatusDispatched)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(4*time.Hour), dispatchedAt)
+}
+
+func TestPurchaseTransitionTo_InvalidTransition(t *testing.T) {
+	purchase := NewPurchase(1, "Televisor", time.Now())
+
+	err := purchase.TransitionTo(PurchaseStatusDelivered, time.Now())
+
+	assert.Error(t, err)
+	assert.True(t, IsInvalidStateError(err))
+	assert.True(t, errors.Is(err, ErrInvalidPurchaseTransition))
+	assert.Equal(t, PurchaseStatusCreated, purchase.Status)
+	assert.Empty(t, purchase.History)
+}
+
+func TestPurchaseTransitionTo_FinalStates(t *testing.T) {
+	for _, status := range []PurchaseStatus{PurchaseStatusDelivered, PurchaseStatusReturned, PurchaseStatusCancelled} {
+		purchase := Purchase{ID: 1, Status: status}
+
+		err := purchase.TransitionTo(PurchaseStatusDispatched, time.Now())
+
+		assert.True(t, IsInvalidStateError(err), "status %s", status)
+	}
+}
+
+func TestPurchaseTransitionTo_UnknownStatus(t *testing.T) {
+	purchase := NewPurchase(1, "Lavarropas", time.Now())
+
+	err := purchase.TransitionTo("LOST", time.Now())
+
+	assert.True(t, IsValidationError(err))
+	assert.True(t, errors.Is(err, ErrInvalidPurchaseStatus))
+}
+
+func TestPurchaseValidate(t *testing.T) {
+	assert.Error(t, (&Purchase{ID: 0}).Validate())
+	assert.Error(t, (&Purchase{ID: 1, Status: "LOST"}).Validate())
+	assert.NoError(t, (&Purchase{ID: 1}).Validate())
+	assert.NoError(t, (&Purchase{ID: 1, Status: PurchaseStatusAssigned}).Validate())
+}
@@ -17,9 +17,22 @@ type Repository[T any] interface {
 	List() ([]T, error)
 }
 
+// RouteRepository guarda las rutas con control de concurrencia optimista: Create
+// asigna la versión 1, Update solo guarda si route.Version coincide con la versión
+// almacenada (si no, devuelve un error de conflicto) y la incrementa, y
+// AssignPurchaseToRoute también incrementa la versión de la ruta.
 type RouteRepository interface {
 	Repository[Route]
 
 	FindByStatus(status RouteStatus) ([]Route, error)
 	AssignPurchaseToRoute(routeID int, purchase Purchase) error
 }
+
+// RouteTransactor lo implementan los repositorios capaces de agrupar varias
+// operaciones de forma atómica
+type RouteTransactor interface {
+	// WithinTx ejecuta fn con un repositorio transaccional: los cambios hechos a
+	// través de repo se confirman juntos si fn no devuelve error, o se descartan.
+	// Dentro de fn solo debe usarse repo, no el repositorio original.
+	WithinTx(fn func(repo RouteRepository) error) error
+}
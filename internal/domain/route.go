@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -15,9 +16,38 @@ const (
 	RouteStatusCancelled  RouteStatus = "CANCELLED"
 )
 
-// Route representa una ruta de distribución en el sistema de logística
+// routeTransitions define los cambios de estado permitidos para una ruta.
+// Una ruta COMPLETED no admite más cambios; una CANCELLED solo puede reabrirse.
+var routeTransitions = map[RouteStatus][]RouteStatus{
+	RouteStatusPending:    {RouteStatusInProgress, RouteStatusCancelled},
+	RouteStatusInProgress: {RouteStatusCompleted, RouteStatusCancelled},
+	RouteStatusCancelled:  {RouteStatusPending},
+}
+
+// IsValid indica si el estado es uno de los estados conocidos
+func (s RouteStatus) IsValid() bool {
+	switch s {
+	case RouteStatusPending, RouteStatusInProgress, RouteStatusCompleted, RouteStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo indica si la transición desde el estado actual está permitida
+func (s RouteStatus) CanTransitionTo(next RouteStatus) bool {
+	for _, allowed := range routeTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Route representa una ruta de distribución en el sistema de logística.
+// Version aumenta con cada cambio guardado y permite detectar escrituras concurrentes.
 type Route struct {
 	ID        int         `json:"id"`
+	Version   int         `json:"version"`
 	Name      string      `json:"name"`
 	Vehicle   string      `json:"vehicle"`
 	Driver    string      `json:"driver"`
@@ -25,14 +55,9 @@ type Route struct {
 	Purchases []Purchase  `json:"purchases"`
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
-}
 
-// Purchase representa una compra asociada a una ruta
-type Purchase struct {
-	ID          int       `json:"id"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
+	CancellationReason string     `json:"cancellation_reason,omitempty"`
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
 }
 
 // Validate realiza validaciones de negocio para una ruta
@@ -79,3 +104,79 @@ func (r *Route) validate() error {
 
 	return nil
 }
+
+// TransitionTo cambia el estado de la ruta si la transición está permitida
+func (r *Route) TransitionTo(next RouteStatus, at time.Time) error {
+	if !next.IsValid() {
+		return NewDomainError(
+			ErrorCodes.ValidationError,
+			fmt.Sprintf("Unknown route status %q", next),
+			ErrInvalidRouteStatus,
+		)
+	}
+
+	if r.Status == RouteStatusCompleted {
+		return NewDomainError(
+			ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d has already been completed", r.ID),
+			ErrRouteAlreadyCompleted,
+		)
+	}
+
+	if next == RouteStatusCancelled {
+		return r.Cancel(r.CancellationReason, at)
+	}
+
+	if !r.Status.CanTransitionTo(next) {
+		return NewDomainError(
+			ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d cannot change from %s to %s", r.ID, r.Status, next),
+			ErrInvalidRouteStatus,
+		)
+	}
+
+	// Al reabrir una ruta se descarta el motivo de la cancelación anterior
+	if r.Status == RouteStatusCancelled {
+		r.CancellationReason = ""
+		r.CancelledAt = nil
+	}
+
+	r.Status = next
+	r.UpdatedAt = at
+
+	return nil
+}
+
+// Cancel cancela la ruta registrando el motivo informado
+func (r *Route) Cancel(reason string, at time.Time) error {
+	if reason == "" {
+		return NewDomainError(
+			ErrorCodes.ValidationError,
+			"Cancellation reason is required",
+			ErrCancellationReasonRequired,
+		)
+	}
+
+	if r.Status == RouteStatusCompleted {
+		return NewDomainError(
+			ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d has already been completed", r.ID),
+			ErrRouteAlreadyCompleted,
+		)
+	}
+
+	if !r.Status.CanTransitionTo(RouteStatusCancelled) {
+		return NewDomainError(
+			ErrorCodes.InvalidState,
+			fmt.Sprintf("Route %d cannot change from %s to %s", r.ID, r.Status, RouteStatusCancelled),
+			ErrInvalidRouteStatus,
+		)
+	}
+
+	r.Status = RouteStatusCancelled
+	r.CancellationReason = reason
+	r.CancelledAt = &at
+	r.UpdatedAt = at
+
+	return nil
+}
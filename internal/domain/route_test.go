@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteTransitionTo(t *testing.T) {
+	route := Route{ID: 1, Status: RouteStatusPending}
+	now := time.Now()
+
+	assert.NoError(t, route.TransitionTo(RouteStatusInProgress, now))
+	assert.NoError(t, route.TransitionTo(RouteStatusCompleted, now))
+	assert.Equal(t, RouteStatusCompleted, route.Status)
+
+	err := route.TransitionTo(RouteStatusPending, now)
+	assert.True(t, IsInvalidStateError(err))
+	assert.True(t, errors.Is(err, ErrRouteAlreadyCompleted))
+}
+
+func TestRouteTransitionTo_NotAllowed(t *testing.T) {
+	route := Route{ID: 1, Status: RouteStatusPending}
+
+	err := route.TransitionTo(RouteStatusCompleted, time.Now())
+
+	assert.True(t, IsInvalidStateError(err))
+	assert.True(t, errors.Is(err, ErrInvalidRouteStatus))
+	assert.Equal(t, RouteStatusPending, route.Status)
+}
+
+func TestRouteCancel(t *testing.T) {
+	route := Route{ID: 1, Status: RouteStatusInProgress}
+	now := time.Now()
+
+	err := route.Cancel("", now)
+	assert.True(t, IsValidationError(err))
+
+	assert.NoError(t, route.Cancel("Driver unavailable", now))
+	assert.Equal(t, RouteStatusCancelled, route.Status)
+	assert.Equal(t, "Driver unavailable", route.CancellationReason)
+	assert.Equal(t, now, *route.CancelledAt)
+
+	assert.NoError(t, route.TransitionTo(RouteStatusPending, now))
+	assert.Empty(t, route.CancellationReason)
+	assert.Nil(t, route.CancelledAt)
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"transport-challenge/internal/domain"
+)
+
+// DeliveryMode indica cómo se entrega un evento a un suscriptor
+type DeliveryMode int
+
+const (
+	// Sync entrega el evento en la goroutine que lo publica
+	Sync DeliveryMode = iota
+	// Async entrega el evento en una goroutine propia
+	Async
+)
+
+// allEvents clave usada por los suscriptores que reciben todos los eventos
+const allEvents = "*"
+
+// Handler procesa un evento de dominio
+type Handler func(event domain.Event)
+
+type subscription struct {
+	id      int
+	handler Handler
+	mode    DeliveryMode
+}
+
+// Bus distribuye eventos de dominio entre suscriptores en el mismo proceso.
+// Un suscriptor que entra en pánico no afecta al publicador ni al resto de suscriptores.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscription
+	nextID      int
+	pending     sync.WaitGroup
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[string][]subscription),
+	}
+}
+
+// Subscribe registra un handler para un tipo de evento y devuelve la función para darlo de baja
+func (b *Bus) Subscribe(eventName string, handler Handler, mode DeliveryMode) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subscribers[eventName] = append(b.subscribers[eventName], subscription{
+		id:      id,
+		handler: handler,
+		mode:    mode,
+	})
+
+	return func() {
+		b.unsubscribe(eventName, id)
+	}
+}
+
+// SubscribeAll registra un handler que recibe todos los eventos publicados
+func (b *Bus) SubscribeAll(handler Handler, mode DeliveryMode) func() {
+	return b.Subscribe(allEvents, handler, mode)
+}
+
+// Subscribe registra un handler tipado; solo recibe eventos del tipo E
+func Subscribe[E domain.Event](b *Bus, handler func(event E), mode DeliveryMode) func() {
+	var zero E
+	return b.Subscribe(zero.EventName(), func(event domain.Event) {
+		if typed, ok := event.(E); ok {
+			handler(typed)
+		}
+	}, mode)
+}
+
+// Publish entrega el evento a los suscriptores de su tipo y a los suscriptores globales
+func (b *Bus) Publish(event domain.Event) {
+	b.mu.RLock()
+	subs := make([]subscription, 0, len(b.subscribers[event.EventName()])+len(b.subscribers[allEvents]))
+	subs = append(subs, b.subscribers[event.EventName()]...)
+	subs = append(subs, b.subscribers[allEvents]...)
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.mode == Async {
+			b.pending.Add(1)
+			go func(sub subscription) {
+				defer b.pending.Done()
+				deliver(sub, event)
+			}(sub)
+			continue
+		}
+
+		deliver(sub, event)
+	}
+}
+
+// Wait bloquea hasta que terminen las entregas asíncronas en curso
+func (b *Bus) Wait() {
+	b.pending.Wait()
+}
+
+func (b *Bus) unsubscribe(eventName string, id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscribers[eventName]
+	for i, sub := range subs {
+		if sub.id == id {
+			b.subscribers[eventName] = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliver ejecuta el handler aislando cualquier pánico
+func deliver(sub subscription, event domain.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("event handler for %s panicked: %v\n%s", event.EventName(), r, debug.Stack())
+		}
+	}()
+
+	sub.handler(event)
+}
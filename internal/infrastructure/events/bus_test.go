@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"transport-challenge/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishDeliversToTypedSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var assigned []domain.PurchaseAssigned
+	Subscribe(bus, func(event domain.PurchaseAssigned) {
+		assigned = append(assigned, event)
+	}, Sync)
+
+	var all []string
+	bus.SubscribeAll(func(event domain.Event) {
+		all = append(all, event.EventName())
+	}, Sync)
+
+	bus.Publish(domain.PurchaseAssigned{RouteID: 1, Purchase: domain.Purchase{ID: 10}, At: time.Now()})
+	bus.Publish(domain.RouteCreated{Route: domain.Route{ID: 1}, At: time.Now()})
+
+	assert.Len(t, assigned, 1)
+	assert.Equal(t, 10, assigned[0].Purchase.ID)
+	assert.Equal(t, []string{domain.EventPurchaseAssigned, domain.EventRouteCreated}, all)
+}
+
+func TestPanickingSubscriberIsIsolated(t *testing.T) {
+	bus := NewBus()
+
+	Subscribe(bus, func(event domain.RouteCreated) {
+		panic("boom")
+	}, Sync)
+
+	delivered := false
+	Subscribe(bus, func(event domain.RouteCreated) {
+		delivered = true
+	}, Sync)
+
+	assert.NotPanics(t, func() {
+		bus.Publish(domain.RouteCreated{At: time.Now()})
+	})
+	assert.True(t, delivered)
+}
+
+func TestAsyncSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	received := 0
+	Subscribe(bus, func(event domain.PurchaseDelivered) {
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+	}, Async)
+	Subscribe(bus, func(event domain.PurchaseDelivered) {
+		panic("async boom")
+	}, Async)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(domain.PurchaseDelivered{At: time.Now()})
+	}
+	bus.Wait()
+
+	assert.Equal(t, 5, received)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := NewBus()
+
+	received := 0
+	unsubscribe := Subscribe(bus, func(event domain.RouteUpdated) {
+		received++
+	}, Sync)
+
+	bus.Publish(domain.RouteUpdated{})
+	unsubscribe()
+	bus.Publish(domain.RouteUpdated{})
+
+	assert.Equal(t, 1, received)
+}
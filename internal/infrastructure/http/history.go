@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"transport-challenge/internal/notification"
+)
+
+// WithNotificationHistory expone el historial de envíos de notificaciones
+func WithNotificationHistory(history notification.AlmacenHistorial) ServerOption {
+	return func(s *Server) {
+		s.History = history
+	}
+}
+
+func (s *Server) historyRoutes() {
+	s.Router.HandleFunc("/notifications", s.GetNotifications).Methods("GET")
+	s.Router.HandleFunc("/purchases/{id}/notifications", s.GetPurchaseNotifications).Methods("GET")
+}
+
+// GetNotifications devuelve el historial de envíos filtrado por purchase_id, channel, type,
+// recipient, status, since, until (RFC 3339) y limit
+func (s *Server) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	filter, err := historyFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writeHistory(w, filter)
+}
+
+// GetPurchaseNotifications devuelve los envíos de notificaciones de una compra
+func (s *Server) GetPurchaseNotifications(w http.ResponseWriter, r *http.Request) {
+	purchaseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid purchase ID", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := historyFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.IDCompra = purchaseID
+
+	s.writeHistory(w, filter)
+}
+
+func (s *Server) writeHistory(w http.ResponseWriter, filter notification.FiltroHistorial) {
+	records, err := s.History.Buscar(filter)
+	if err != nil {
+		http.Error(w, "Error fetching notification history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(records)
+}
+
+// historyFilter traduce los parámetros de la consulta a un filtro del historial
+func historyFilter(query url.Values) (notification.FiltroHistorial, error) {
+	filter := notification.FiltroHistorial{
+		Tipo:         notification.TipoNotificacion(query.Get("type")),
+		Canal:        query.Get("channel"),
+		Destinatario: query.Get("recipient"),
+		Estado:       notification.EstadoEnvio(query.Get("status")),
+	}
+
+	if filter.Estado != "" && !filter.Estado.EsValido() {
+		return filter, fmt.Errorf("Invalid status %q", filter.Estado)
+	}
+
+	if value := query.Get("purchase_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid purchase_id %q", value)
+		}
+		filter.IDCompra = id
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, fmt.Errorf("Invalid limit %q", value)
+		}
+		filter.Limite = limit
+	}
+
+	for param, target := range map[string]*time.Time{"since": &filter.Desde, "until": &filter.Hasta} {
+		if value := query.Get(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("Invalid %s %q, expected RFC 3339", param, value)
+			}
+			*target = parsed
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"transport-challenge/internal/notification"
+
+	"github.com/gorilla/mux"
+)
+
+// WithPreferences habilita la API de preferencias de notificación de los destinatarios
+func WithPreferences(store notification.AlmacenContactos) ServerOption {
+	return func(s *Server) {
+		s.Preferences = store
+	}
+}
+
+// WithPhoneCountryCode indica el código de país que se antepone a los teléfonos de las
+// preferencias guardados sin prefijo internacional, como hace el canal SMS
+func WithPhoneCountryCode(code string) ServerOption {
+	return func(s *Server) {
+		s.PhoneCountryCode = code
+	}
+}
+
+func (s *Server) preferenceRoutes() {
+	s.Router.HandleFunc("/recipients", s.GetRecipients).Methods("GET")
+	s.Router.HandleFunc("/recipients/{recipient}/preferences", s.GetRecipientPreferences).Methods("GET")
+	s.Router.HandleFunc("/recipients/{recipient}/preferences", s.PutRecipientPreferences).Methods("PUT")
+	s.Router.HandleFunc("/recipients/{recipient}/preferences", s.DeleteRecipientPreferences).Methods("DELETE")
+}
+
+func (s *Server) GetRecipients(w http.ResponseWriter, r *http.Request) {
+	contacts, err := s.Preferences.Listar()
+	if err != nil {
+		writePreferencesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(contacts)
+}
+
+func (s *Server) GetRecipientPreferences(w http.ResponseWriter, r *http.Request) {
+	contact, err := s.Preferences.Obtener(mux.Vars(r)["recipient"])
+	if err != nil {
+		writePreferencesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(contact)
+}
+
+// PutRecipientPreferences crea o reemplaza las preferencias del destinatario indicado en la URL.
+// El teléfono se guarda en formato E.164 y el estado que administra el servidor (TokenInvalido)
+// se conserva mientras no cambie el token de dispositivo.
+func (s *Server) PutRecipientPreferences(w http.ResponseWriter, r *http.Request) {
+	var contact notification.Contacto
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	contact.ID = mux.Vars(r)["recipient"]
+
+	if contact.Telefono != "" {
+		phone, err := notification.NormalizarTelefonoE164(contact.Telefono, s.PhoneCountryCode)
+		if err != nil {
+			http.Error(w, "Invalid phone number: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		contact.Telefono = phone
+	}
+
+	current, err := s.Preferences.Obtener(contact.ID)
+	switch {
+	case err == nil:
+		contact.TokenInvalido = current.TokenInvalido && current.TokenDispositivo == contact.TokenDispositivo
+	case errors.Is(err, notification.ErrContactoNoEncontrado):
+		contact.TokenInvalido = false
+	default:
+		writePreferencesError(w, err)
+		return
+	}
+
+	saved, err := s.Preferences.Guardar(contact)
+	if err != nil {
+		writePreferencesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(saved)
+}
+
+func (s *Server) DeleteRecipientPreferences(w http.ResponseWriter, r *http.Request) {
+	if err := s.Preferences.Eliminar(mux.Vars(r)["recipient"]); err != nil {
+		writePreferencesError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writePreferencesError traduce los errores de preferencias a códigos de estado HTTP
+func writePreferencesError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notification.ErrContactoNoEncontrado):
+		http.Error(w, "Recipient not found", http.StatusNotFound)
+	case errors.Is(err, notification.ErrPreferenciasInvalidas):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Error processing preferences: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,12 +2,16 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"transport-challenge/internal/application"
 	"transport-challenge/internal/domain"
+	"transport-challenge/internal/infrastructure/webhook"
+	"transport-challenge/internal/notification"
 
 	"github.com/gorilla/mux"
 )
@@ -15,9 +19,53 @@ import (
 type Server struct {
 	Router       *mux.Router
 	RouteService *application.RouteService
+	Outbox       notification.AlmacenBandeja
+	Channels     ChannelMonitor
+	Templates    TemplateReloader
+	Preferences  notification.AlmacenContactos
+	History      notification.AlmacenHistorial
+	Webhooks     *webhook.Manager
+
+	PhoneCountryCode string
+}
+
+// ChannelMonitor expone el estado de los disyuntores de cada canal de notificación.
+// notification.ServicioNotificaciones satisface esta interfaz.
+type ChannelMonitor interface {
+	EstadoDisyuntores() map[string]notification.InstantaneaDisyuntor
+}
+
+// TemplateReloader recarga las plantillas de notificación desde su directorio.
+// notification.ServicioNotificaciones satisface esta interfaz.
+type TemplateReloader interface {
+	RecargarPlantillas() error
+}
+
+// ServerOption configura componentes opcionales del servidor
+type ServerOption func(*Server)
+
+// WithOutbox expone la bandeja de salida de notificaciones para su consulta
+func WithOutbox(outbox notification.AlmacenBandeja) ServerOption {
+	return func(s *Server) {
+		s.Outbox = outbox
+	}
+}
+
+// WithChannelMonitor expone el estado de los canales de notificación para monitoreo
+func WithChannelMonitor(monitor ChannelMonitor) ServerOption {
+	return func(s *Server) {
+		s.Channels = monitor
+	}
 }
 
-func NewServer(routeService *application.RouteService) *Server {
+// WithTemplateReloader permite recargar las plantillas de notificación sin reiniciar el servidor
+func WithTemplateReloader(reloader TemplateReloader) ServerOption {
+	return func(s *Server) {
+		s.Templates = reloader
+	}
+}
+
+func NewServer(routeService *application.RouteService, opts ...ServerOption) *Server {
 	router := mux.NewRouter()
 
 	server := &Server{
@@ -25,6 +73,10 @@ func NewServer(routeService *application.RouteService) *Server {
 		RouteService: routeService,
 	}
 
+	for _, opt := range opts {
+		opt(server)
+	}
+
 	// Define rutas
 	server.routes()
 
@@ -36,6 +88,40 @@ func (s *Server) routes() {
 	s.Router.HandleFunc("/routes", s.GetRoutes).Methods("GET")
 	s.Router.HandleFunc("/routes/{id}", s.GetRouteByID).Methods("GET")
 	s.Router.HandleFunc("/routes/{id}", s.UpdateRoute).Methods("PUT")
+	s.Router.HandleFunc("/routes/{id}/start", s.StartRoute).Methods("POST")
+	s.Router.HandleFunc("/routes/{id}/cancel", s.CancelRoute).Methods("POST")
+	s.Router.HandleFunc("/routes/{id}/reopen", s.ReopenRoute).Methods("POST")
+	s.Router.HandleFunc("/routes/{id}/complete", s.CompleteRoute).Methods("POST")
+
+	s.Router.HandleFunc("/routes/{id}/purchases", s.AssignPurchase).Methods("POST")
+	s.Router.HandleFunc("/routes/{id}/purchases", s.GetRoutePurchases).Methods("GET")
+	s.Router.HandleFunc("/routes/{id}/purchases/{purchase_id}", s.GetRoutePurchase).Methods("GET")
+	s.Router.HandleFunc("/routes/{id}/purchases/{purchase_id}", s.RemovePurchase).Methods("DELETE")
+	s.Router.HandleFunc("/routes/{id}/purchases/{purchase_id}/status", s.UpdatePurchaseStatus).Methods("PATCH")
+
+	if s.Outbox != nil {
+		s.Router.HandleFunc("/notifications/outbox", s.GetOutbox).Methods("GET")
+	}
+
+	if s.Channels != nil {
+		s.Router.HandleFunc("/notifications/circuit-breakers", s.GetCircuitBreakers).Methods("GET")
+	}
+
+	if s.Templates != nil {
+		s.Router.HandleFunc("/notifications/templates/reload", s.ReloadTemplates).Methods("POST")
+	}
+
+	if s.History != nil {
+		s.historyRoutes()
+	}
+
+	if s.Preferences != nil {
+		s.preferenceRoutes()
+	}
+
+	if s.Webhooks != nil {
+		s.webhookRoutes()
+	}
 }
 
 func (s *Server) CreateRoute(w http.ResponseWriter, r *http.Request) {
@@ -89,19 +175,19 @@ func (s *Server) GetRouteByID(w http.ResponseWriter, r *http.Request) {
 	// Busca la ruta por ID
 	route, err := s.RouteService.GetRouteByID(id)
 	if err != nil {
-		if err == domain.ErrNotFound {
-			http.Error(w, "Route not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Error retrieving route: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeServiceError(w, err, "Error retrieving route")
 		return
 	}
 
+	setRouteETag(w, route)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(route)
 }
 
+// UpdateRoute actualiza la ruta. La versión esperada se toma del encabezado If-Match
+// o, si no viene, del campo version del cuerpo; una escritura sobre una versión
+// desactualizada responde 412 en el primer caso y 409 en el segundo.
 func (s *Server) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 	// Obtiene el ID de la ruta desde los parámetros de la URL
 	vars := mux.Vars(r)
@@ -123,17 +209,296 @@ func (s *Server) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" {
+		version, ok := parseIfMatch(ifMatch)
+		if !ok {
+			http.Error(w, "Invalid If-Match header", http.StatusPreconditionFailed)
+			return
+		}
+		route.Version = version
+	}
+
 	err = s.RouteService.UpdateRoute(id, &route)
 	if err != nil {
-		if err == domain.ErrNotFound {
-			http.Error(w, "Route not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Error updating route: "+err.Error(), http.StatusInternalServerError)
+		if ifMatch != "" && domain.IsConflictError(err) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
 		}
+		writeServiceError(w, err, "Error updating route")
+		return
+	}
+
+	setRouteETag(w, route)
+	w.WriteHeader(http.StatusOK)
+}
+
+// cancelRouteRequest cuerpo esperado para cancelar una ruta
+type cancelRouteRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) StartRoute(w http.ResponseWriter, r *http.Request) {
+	s.changeRouteStatus(w, r, s.RouteService.StartRoute)
+}
+
+func (s *Server) CancelRoute(w http.ResponseWriter, r *http.Request) {
+	var body cancelRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.changeRouteStatus(w, r, func(id int) error {
+		return s.RouteService.CancelRoute(id, body.Reason)
+	})
+}
+
+func (s *Server) ReopenRoute(w http.ResponseWriter, r *http.Request) {
+	s.changeRouteStatus(w, r, s.RouteService.ReopenRoute)
+}
+
+func (s *Server) CompleteRoute(w http.ResponseWriter, r *http.Request) {
+	s.changeRouteStatus(w, r, s.RouteService.CompleteRoute)
+}
+
+// changeRouteStatus aplica un cambio de estado y responde con la ruta actualizada
+func (s *Server) changeRouteStatus(w http.ResponseWriter, r *http.Request, change func(id int) error) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid route ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := change(id); err != nil {
+		writeServiceError(w, err, "Error changing route status")
+		return
+	}
+
+	route, err := s.RouteService.GetRouteByID(id)
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving route")
+		return
+	}
+
+	setRouteETag(w, route)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(route)
+}
+
+// purchaseStatusRequest cuerpo esperado para cambiar el estado de una compra
+type purchaseStatusRequest struct {
+	Status domain.PurchaseStatus `json:"status"`
+}
+
+func (s *Server) AssignPurchase(w http.ResponseWriter, r *http.Request) {
+	routeID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid route ID", http.StatusBadRequest)
+		return
+	}
+
+	var purchase domain.Purchase
+	if err := json.NewDecoder(r.Body).Decode(&purchase); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.RouteService.AssignPurchaseToRoute(routeID, purchase); err != nil {
+		writeServiceError(w, err, "Error assigning purchase")
+		return
+	}
+
+	assigned, err := s.RouteService.GetRoutePurchase(routeID, purchase.ID)
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving purchase")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(assigned)
+}
+
+func (s *Server) GetRoutePurchases(w http.ResponseWriter, r *http.Request) {
+	routeID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid route ID", http.StatusBadRequest)
+		return
+	}
+
+	purchases, err := s.RouteService.ListRoutePurchases(routeID)
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving purchases")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(purchases)
+}
+
+func (s *Server) GetRoutePurchase(w http.ResponseWriter, r *http.Request) {
+	routeID, purchaseID, ok := purchasePathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	purchase, err := s.RouteService.GetRoutePurchase(routeID, purchaseID)
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving purchase")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(purchase)
+}
+
+func (s *Server) RemovePurchase(w http.ResponseWriter, r *http.Request) {
+	routeID, purchaseID, ok := purchasePathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.RouteService.RemovePurchaseFromRoute(routeID, purchaseID); err != nil {
+		writeServiceError(w, err, "Error removing purchase")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) UpdatePurchaseStatus(w http.ResponseWriter, r *http.Request) {
+	routeID, purchaseID, ok := purchasePathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var body purchaseStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	purchase, err := s.RouteService.UpdatePurchaseStatus(routeID, purchaseID, body.Status)
+	if err != nil {
+		writeServiceError(w, err, "Error updating purchase status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(purchase)
+}
+
+// GetOutbox lista las notificaciones de la bandeja de salida, filtrando opcionalmente por estado
+func (s *Server) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	status := notification.EstadoEntrada(r.URL.Query().Get("status"))
+
+	switch status {
+	case "", notification.EntradaPendiente, notification.EntradaEnviada, notification.EntradaFallida:
+	default:
+		http.Error(w, "Invalid outbox status", http.StatusBadRequest)
 		return
 	}
 
+	entries, err := s.Outbox.Listar(status)
+	if err != nil {
+		http.Error(w, "Error retrieving outbox: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// GetCircuitBreakers devuelve el estado del disyuntor de cada canal de notificación
+func (s *Server) GetCircuitBreakers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.Channels.EstadoDisyuntores())
+}
+
+// ReloadTemplates vuelve a leer las plantillas de notificación; si alguna es inválida se conservan las anteriores
+func (s *Server) ReloadTemplates(w http.ResponseWriter, r *http.Request) {
+	if err := s.Templates.RecargarPlantillas(); err != nil {
+		http.Error(w, "Error reloading templates: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathID obtiene un identificador numérico desde los parámetros de la URL
+func pathID(r *http.Request, key string) (int, error) {
+	return strconv.Atoi(mux.Vars(r)[key])
+}
+
+// purchasePathIDs obtiene los identificadores de ruta y compra, respondiendo 400 si no son válidos
+func purchasePathIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	routeID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid route ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	purchaseID, err := pathID(r, "purchase_id")
+	if err != nil {
+		http.Error(w, "Invalid purchase ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return routeID, purchaseID, true
+}
+
+// setRouteETag expone la versión de la ruta como ETag fuerte
+func setRouteETag(w http.ResponseWriter, route domain.Route) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(route.Version)))
+}
+
+// parseIfMatch obtiene la versión esperada de un encabezado If-Match. "*" acepta
+// cualquier versión y se traduce a 0, que no impone condición.
+func parseIfMatch(header string) (int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, true
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// writeServiceError traduce los errores de dominio a códigos de estado HTTP
+func writeServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "Route not found", http.StatusNotFound)
+	case domain.IsNotFoundError(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case domain.IsAlreadyExistsError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case domain.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case domain.IsInvalidStateError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case domain.IsConflictError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
 }
 
 func (s *Server) Start() {
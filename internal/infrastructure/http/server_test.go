@@ -3,13 +3,16 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"transport-challenge/internal/application"
 	"transport-challenge/internal/domain"
+	"transport-challenge/internal/infrastructure/webhook"
+	"transport-challenge/internal/notification"
 
-	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,6 +29,7 @@ func NewMockRouteRepository() *MockRouteRepository {
 
 func (m *MockRouteRepository) Create(route domain.Route) (int, error) {
 	route.ID = len(m.routes) + 1
+	route.Version = 1
 	m.routes[route.ID] = route
 	return route.ID, nil
 }
@@ -39,10 +43,15 @@ func (m *MockRouteRepository) GetByID(id int) (domain.Route, error) {
 }
 
 func (m *MockRouteRepository) Update(id int, route domain.Route) error {
-	if _, exists := m.routes[id]; !exists {
+	stored, exists := m.routes[id]
+	if !exists {
 		return domain.ErrNotFound
 	}
+	if route.Version != stored.Version {
+		return domain.NewVersionConflictError(id, route.Version, stored.Version)
+	}
 	route.ID = id
+	route.Version++
 	m.routes[id] = route
 	return nil
 }
@@ -71,13 +80,25 @@ func (m *MockRouteRepository) FindByStatus(status domain.RouteStatus) ([]domain.
 }
 
 func (m *MockRouteRepository) AssignPurchaseToRoute(routeID int, purchase domain.Purchase) error {
+	route, exists := m.routes[routeID]
+	if !exists {
+		return domain.ErrNotFound
+	}
+	for _, existing := range route.Purchases {
+		if existing.ID == purchase.ID {
+			return domain.NewDomainError(domain.ErrorCodes.AlreadyExists, "purchase already exists", domain.ErrPurchaseAlreadyExists)
+		}
+	}
+	route.Purchases = append(route.Purchases, purchase)
+	route.Version++
+	m.routes[routeID] = route
 	return nil
 }
 
 func TestCreateRoute(t *testing.T) {
 	// Crea repositorio mock y servidor
 	mockRepo := NewMockRouteRepository()
-	server := NewServer(mockRepo)
+	server := NewServer(application.NewRouteService(mockRepo))
 
 	routeJSON := `{
 		"name": "Test Route",
@@ -114,9 +135,9 @@ func TestGetRouteByID(t *testing.T) {
 	}
 	routeID, _ := mockRepo.Create(testRoute)
 
-	server := NewServer(mockRepo)
+	server := NewServer(application.NewRouteService(mockRepo))
 
-	req, err := http.NewRequest("GET", "/routes/"+mux.Var{Key: "id", Value: "1"}, nil)
+	req, err := http.NewRequest("GET", "/routes/1", nil)
 	assert.NoError(t, err)
 
 	recorder := httptest.NewRecorder()
@@ -132,6 +153,20 @@ func TestGetRouteByID(t *testing.T) {
 	assert.Equal(t, "Test Route", route.Name)
 }
 
+func TestGetRouteByIDNotFound(t *testing.T) {
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()))
+
+	req, err := http.NewRequest("GET", "/routes/99", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("ETag"))
+}
+
 func TestUpdateRoute(t *testing.T) {
 	mockRepo := NewMockRouteRepository()
 
@@ -143,7 +178,7 @@ func TestUpdateRoute(t *testing.T) {
 	}
 	routeID, _ := mockRepo.Create(testRoute)
 
-	server := NewServer(mockRepo)
+	server := NewServer(application.NewRouteService(mockRepo))
 
 	updatedRouteJSON := `{
 		"name": "Updated Route",
@@ -152,7 +187,7 @@ func TestUpdateRoute(t *testing.T) {
 		"status": "IN_PROGRESS"
 	}`
 
-	req, err := http.NewRequest("PUT", "/routes/"+mux.Var{Key: "id", Value: "1"}, bytes.NewBufferString(updatedRouteJSON))
+	req, err := http.NewRequest("PUT", "/routes/1", bytes.NewBufferString(updatedRouteJSON))
 	assert.NoError(t, err)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -169,9 +204,56 @@ func TestUpdateRoute(t *testing.T) {
 	assert.Equal(t, domain.RouteStatusInProgress, updatedRoute.Status)
 }
 
+func TestUpdateRouteOptimisticConcurrency(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	mockRepo.Create(domain.Route{Name: "Original Route", Vehicle: "Truck", Driver: "Julian", Status: domain.RouteStatusPending})
+	server := NewServer(application.NewRouteService(mockRepo))
+
+	put := func(body string, header http.Header) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("PUT", "/routes/1", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	req, err := http.NewRequest("GET", "/routes/1", nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, `"1"`, recorder.Header().Get("ETag"))
+
+	body := `{"name": "Updated Route", "vehicle": "Bus", "driver": "Ramona"}`
+
+	recorder = put(body, http.Header{"If-Match": {`"1"`}})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"2"`, recorder.Header().Get("ETag"))
+
+	recorder = put(body, http.Header{"If-Match": {`"1"`}})
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+
+	recorder = put(body, http.Header{"If-Match": {"not-an-etag"}})
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+
+	recorder = put(`{"name": "Stale Route", "vehicle": "Bus", "driver": "Ramona", "version": 1}`, nil)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = put(body, http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"3"`, recorder.Header().Get("ETag"))
+
+	route, err := mockRepo.GetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Updated Route", route.Name)
+	assert.Equal(t, 3, route.Version)
+}
+
 func TestCreateRouteValidationError(t *testing.T) {
 	mockRepo := NewMockRouteRepository()
-	server := NewServer(mockRepo)
+	server := NewServer(application.NewRouteService(mockRepo))
 
 	routeJSON := `{
 		"vehicle": "Truck",
@@ -189,3 +271,406 @@ func TestCreateRouteValidationError(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 }
+
+func TestCancelAndReopenRoute(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	routeID, _ := mockRepo.Create(domain.Route{
+		Name:    "Route to cancel",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusPending,
+	})
+
+	server := NewServer(application.NewRouteService(mockRepo))
+
+	req, err := http.NewRequest("POST", "/routes/1/cancel", bytes.NewBufferString(`{}`))
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	req, err = http.NewRequest("POST", "/routes/1/cancel", bytes.NewBufferString(`{"reason": "Vehicle broke down"}`))
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	cancelled, _ := mockRepo.GetByID(routeID)
+	assert.Equal(t, domain.RouteStatusCancelled, cancelled.Status)
+	assert.Equal(t, "Vehicle broke down", cancelled.CancellationReason)
+	assert.NotNil(t, cancelled.CancelledAt)
+
+	req, err = http.NewRequest("POST", "/routes/1/reopen", nil)
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	reopened, _ := mockRepo.GetByID(routeID)
+	assert.Equal(t, domain.RouteStatusPending, reopened.Status)
+	assert.Empty(t, reopened.CancellationReason)
+}
+
+func TestUpdateRouteRejectsIllegalTransition(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	routeID, _ := mockRepo.Create(domain.Route{
+		Name:    "Completed Route",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusCompleted,
+	})
+
+	server := NewServer(application.NewRouteService(mockRepo))
+
+	updatedRouteJSON := `{
+		"name": "Completed Route",
+		"vehicle": "Truck",
+		"driver": "Julian",
+		"status": "PENDING"
+	}`
+
+	req, err := http.NewRequest("PUT", "/routes/1", bytes.NewBufferString(updatedRouteJSON))
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	route, _ := mockRepo.GetByID(routeID)
+	assert.Equal(t, domain.RouteStatusCompleted, route.Status)
+}
+
+func TestUpdateRouteRejectsUnknownStatus(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	mockRepo.Create(domain.Route{
+		Name:    "Route",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusPending,
+	})
+
+	server := NewServer(application.NewRouteService(mockRepo))
+
+	req, err := http.NewRequest("PUT", "/routes/1", bytes.NewBufferString(`{"name": "Route", "vehicle": "Truck", "driver": "Julian", "status": "LOST"}`))
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestPurchaseLifecycleEndpoints(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	routeID, _ := mockRepo.Create(domain.Route{
+		Name:    "Route with purchases",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusPending,
+	})
+
+	server := NewServer(application.NewRouteService(mockRepo))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve("POST", "/routes/1/purchases", `{"id": 10, "description": "Heladera"}`)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var purchase domain.Purchase
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &purchase))
+	assert.Equal(t, domain.PurchaseStatusAssigned, purchase.Status)
+
+	recorder = serve("POST", "/routes/1/purchases", `{"id": 10, "description": "Heladera"}`)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = serve("GET", "/routes/1/purchases", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var purchases []domain.Purchase
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &purchases))
+	assert.Len(t, purchases, 1)
+
+	route, _ := mockRepo.GetByID(routeID)
+	assert.Equal(t, domain.RouteStatusInProgress, route.Status)
+	assert.Len(t, route.Purchases, 1)
+
+	recorder = serve("PATCH", "/routes/1/purchases/10/status", `{"status": "DELIVERED"}`)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = serve("PATCH", "/routes/1/purchases/10/status", `{"status": "DISPATCHED"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &purchase))
+	assert.Equal(t, domain.PurchaseStatusDispatched, purchase.Status)
+
+	recorder = serve("DELETE", "/routes/1/purchases/10", "")
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = serve("GET", "/routes/1/purchases/10", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve("GET", "/routes/1/purchases/99", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestRemovePurchase(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	routeID, _ := mockRepo.Create(domain.Route{
+		Name:    "Route",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusPending,
+	})
+
+	service := application.NewRouteService(mockRepo)
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 7, Description: "Televisor"}))
+
+	server := NewServer(service)
+
+	req, err := http.NewRequest("DELETE", "/routes/1/purchases/7", nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	route, _ := mockRepo.GetByID(routeID)
+	assert.Empty(t, route.Purchases)
+}
+
+func TestGetOutbox(t *testing.T) {
+	mockRepo := NewMockRouteRepository()
+	routeID, _ := mockRepo.Create(domain.Route{
+		Name:    "Route",
+		Vehicle: "Truck",
+		Driver:  "Julian",
+		Status:  domain.RouteStatusPending,
+	})
+
+	outbox := notification.NuevaBandejaSalida()
+	service := application.NewRouteService(mockRepo, application.WithNotifier(outbox))
+	assert.NoError(t, service.AssignPurchaseToRoute(routeID, domain.Purchase{ID: 7, Recipient: "cliente@ejemplo.com"}))
+
+	server := NewServer(service, WithOutbox(outbox))
+
+	req, err := http.NewRequest("GET", "/notifications/outbox?status=PENDIENTE", nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var entries []notification.EntradaBandeja
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, 7, entries[0].Notificacion.IDCompra)
+
+	req, err = http.NewRequest("GET", "/notifications/outbox?status=UNKNOWN", nil)
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetCircuitBreakers(t *testing.T) {
+	notifications := notification.NuevoServicioNotificaciones(notification.ConfiguracionNotificaciones{})
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()), WithChannelMonitor(notifications))
+
+	req, err := http.NewRequest("GET", "/notifications/circuit-breakers", nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var states map[string]notification.InstantaneaDisyuntor
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &states))
+	assert.Equal(t, notification.DisyuntorCerrado, states["email"].Estado)
+	assert.Equal(t, notification.DisyuntorCerrado, states["push"].Estado)
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	manager := webhook.NewManager(webhook.NewInMemoryStore(), webhook.DefaultConfig())
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()), WithWebhooks(manager))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve("POST", "/webhooks", `{"url":"https://ejemplo.com/hook","events":["route.*"]}`)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var created webhook.Subscription
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Secret)
+	assert.True(t, created.Active)
+
+	recorder = serve("POST", "/webhooks", `{"url":"https://ejemplo.com/hook","events":["unknown.event"]}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = serve("GET", "/webhooks", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var subscriptions []webhook.Subscription
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &subscriptions))
+	assert.Len(t, subscriptions, 1)
+	assert.Empty(t, subscriptions[0].Secret)
+
+	recorder = serve("GET", fmt.Sprintf("/webhooks/%d/deliveries", created.ID), "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve("POST", fmt.Sprintf("/webhooks/%d/enable", created.ID), "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve("DELETE", fmt.Sprintf("/webhooks/%d", created.ID), "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = serve("GET", fmt.Sprintf("/webhooks/%d", created.ID), "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+type stubTemplateReloader struct {
+	err error
+}
+
+func (s stubTemplateReloader) RecargarPlantillas() error {
+	return s.err
+}
+
+func TestReloadTemplates(t *testing.T) {
+	service := application.NewRouteService(NewMockRouteRepository())
+
+	server := NewServer(service, WithTemplateReloader(stubTemplateReloader{}))
+	req, _ := http.NewRequest("POST", "/notifications/templates/reload", nil)
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	server = NewServer(service, WithTemplateReloader(stubTemplateReloader{err: fmt.Errorf("plantilla inválida")}))
+	recorder = httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+}
+
+func TestRecipientPreferencesEndpoints(t *testing.T) {
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()), WithPreferences(notification.NuevoAlmacenContactosMemoria()))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve("GET", "/recipients/cliente@ejemplo.com/preferences", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	body := `{"email":"cliente@ejemplo.com","telefono":"+5491112345678","canales":{"sms":false},` +
+		`"tipos":{"COMPRA_EN_RUTA":false},"horario_silencio":{"inicio":"22:00","fin":"07:00","zona_horaria":"America/Argentina/Buenos_Aires"}}`
+	recorder = serve("PUT", "/recipients/cliente@ejemplo.com/preferences", body)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve("GET", "/recipients/cliente@ejemplo.com/preferences", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var contact notification.Contacto
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &contact))
+	assert.Equal(t, "cliente@ejemplo.com", contact.ID)
+	assert.False(t, contact.AceptaCanal(notification.CanalSMS))
+	assert.True(t, contact.AceptaCanal(notification.CanalEmail))
+	assert.False(t, contact.AceptaTipo(notification.NotificacionCompraEnRuta))
+
+	recorder = serve("PUT", "/recipients/cliente@ejemplo.com/preferences", `{"horario_silencio":{"inicio":"25:00","fin":"07:00"}}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = serve("DELETE", "/recipients/cliente@ejemplo.com/preferences", "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = serve("DELETE", "/recipients/cliente@ejemplo.com/preferences", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestPutRecipientPreferencesValidatesPhoneAndKeepsServerState(t *testing.T) {
+	contacts := notification.NuevoAlmacenContactosMemoria()
+	_, err := contacts.Guardar(notification.Contacto{ID: "cliente", TokenDispositivo: "token-viejo", TokenInvalido: true})
+	assert.NoError(t, err)
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()), WithPreferences(contacts), WithPhoneCountryCode("54"))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("PUT", "/recipients/cliente/preferences", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve(`{"telefono":"no es un número"}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = serve(`{"token_dispositivo":"token-viejo","telefono":"011 1234-5678"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	contact, err := contacts.Obtener("cliente")
+	assert.NoError(t, err)
+	assert.Equal(t, "+541112345678", contact.Telefono)
+	assert.True(t, contact.TokenInvalido, "un PUT no debe borrar el token inválido")
+
+	recorder = serve(`{"token_dispositivo":"token-nuevo","token_invalido":true}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	contact, err = contacts.Obtener("cliente")
+	assert.NoError(t, err)
+	assert.False(t, contact.TokenInvalido, "un token nuevo vuelve a habilitar push")
+}
+
+func TestNotificationHistoryEndpoints(t *testing.T) {
+	history := notification.NuevoHistorialMemoria(0)
+	history.Registrar(notification.RegistroEnvio{IDCompra: 123, Canal: notification.CanalEmail, Estado: notification.EnvioExitoso, Tipo: notification.NotificacionCompraEntregada})
+	history.Registrar(notification.RegistroEnvio{IDCompra: 123, Canal: notification.CanalPush, Estado: notification.EnvioFallido, Error: "timeout"})
+	history.Registrar(notification.RegistroEnvio{IDCompra: 456, Canal: notification.CanalEmail, Estado: notification.EnvioExitoso})
+
+	server := NewServer(application.NewRouteService(NewMockRouteRepository()), WithNotificationHistory(history))
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	decode := func(recorder *httptest.ResponseRecorder) []notification.RegistroEnvio {
+		var records []notification.RegistroEnvio
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &records))
+		return records
+	}
+
+	recorder := serve("/purchases/123/notifications")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, decode(recorder), 2)
+
+	recorder = serve("/purchases/123/notifications?channel=email")
+	records := decode(recorder)
+	assert.Len(t, records, 1)
+	assert.Equal(t, notification.NotificacionCompraEntregada, records[0].Tipo)
+
+	recorder = serve("/notifications?status=FALLIDO")
+	records = decode(recorder)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "timeout", records[0].Error)
+
+	recorder = serve("/notifications?channel=email&limit=1")
+	records = decode(recorder)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 456, records[0].IDCompra)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/notifications?status=UNKNOWN").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/notifications?since=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/purchases/abc/notifications").Code)
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"transport-challenge/internal/infrastructure/webhook"
+)
+
+// WithWebhooks habilita la API de suscripciones a webhooks
+func WithWebhooks(manager *webhook.Manager) ServerOption {
+	return func(s *Server) {
+		s.Webhooks = manager
+	}
+}
+
+func (s *Server) webhookRoutes() {
+	s.Router.HandleFunc("/webhooks", s.CreateWebhook).Methods("POST")
+	s.Router.HandleFunc("/webhooks", s.GetWebhooks).Methods("GET")
+	s.Router.HandleFunc("/webhooks/{id}", s.GetWebhook).Methods("GET")
+	s.Router.HandleFunc("/webhooks/{id}", s.DeleteWebhook).Methods("DELETE")
+	s.Router.HandleFunc("/webhooks/{id}/enable", s.EnableWebhook).Methods("POST")
+	s.Router.HandleFunc("/webhooks/{id}/deliveries", s.GetWebhookDeliveries).Methods("GET")
+}
+
+func (s *Server) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var subscription webhook.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.Webhooks.Register(subscription)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	// El secreto solo se devuelve al crear la suscripción
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *Server) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := s.Webhooks.List()
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+func (s *Server) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := s.Webhooks.Get(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	subscription.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (s *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Webhooks.Delete(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) EnableWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := s.Webhooks.Enable(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	subscription.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (s *Server) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := s.Webhooks.Deliveries(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// writeWebhookError traduce los errores de suscripciones a códigos de estado HTTP
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrSubscriptionNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, webhook.ErrInvalidSubscription):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Error processing webhook: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,32 +1,29 @@
 package persistence
 
 import (
-	"errors"
-	"sync"
-)
+	"database/sql"
+	"fmt"
 
-type Database struct {
-	mu  sync.Mutex
-	ids map[string]int // Mapa ficticio para simular una tabla de secuencia
-}
+	"transport-challenge/config"
+)
 
-func NewDatabase() *Database {
-	return &Database{
-		ids: make(map[string]int),
+// OpenDatabase abre la base configurada y verifica que responda. El driver
+// correspondiente debe estar registrado por quien llama (por ejemplo main).
+func OpenDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-}
 
-// GetNextID simula la obtención del siguiente ID para una tabla de secuencia.
-func (db *Database) GetNextID(tableName string) (int, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	// SQLite admite un único escritor; una sola conexión evita errores de base bloqueada
+	if cfg.Driver == config.DriverSQLite {
+		db.SetMaxOpenConns(1)
+	}
 
-	// Simula la creación de un nuevo ID para la tabla indicada por tableName
-	if tableName == "" {
-		return 0, errors.New("table name cannot be empty")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Obtiene el siguiente ID para esa tabla
-	db.ids[tableName]++
-	return db.ids[tableName], nil
+	return db, nil
 }
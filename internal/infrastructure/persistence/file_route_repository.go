@@ -0,0 +1,594 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"transport-challenge/internal/domain"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	walHeaderSize          = 8
+	defaultSnapshotEvery   = 1000
+	snapshotFilePermission = 0o644
+)
+
+// ErrCorruptLog indica un registro dañado en medio del log, que no puede
+// atribuirse a una escritura interrumpida
+var ErrCorruptLog = errors.New("write-ahead log is corrupt")
+
+// Operaciones registradas en el log
+const (
+	walOpCreate = "create"
+	walOpUpdate = "update"
+	walOpDelete = "delete"
+	walOpAssign = "assign"
+	// walOpOutbox guarda un mensaje nuevo o actualizado de la bandeja de salida
+	walOpOutbox = "outbox"
+	// walOpBatch agrupa las entradas de un WithinTx en un único registro atómico
+	walOpBatch = "batch"
+)
+
+// walEntry es una mutación del repositorio. Seq crece en cada escritura y permite
+// descartar al reproducir las entradas ya incluidas en el snapshot.
+type walEntry struct {
+	Seq      uint64           `json:"seq"`
+	Op       string           `json:"op"`
+	RouteID  int              `json:"route_id"`
+	Route    *domain.Route    `json:"route,omitempty"`
+	Purchase *domain.Purchase `json:"purchase,omitempty"`
+	Entries  []walEntry       `json:"entries,omitempty"`
+
+	OutboxMessage *domain.OutboxMessage `json:"outbox_message,omitempty"`
+}
+
+type walSnapshot struct {
+	LastSeq uint64                 `json:"last_seq"`
+	NextID  int                    `json:"next_id"`
+	Routes  []domain.Route         `json:"routes"`
+	Outbox  []domain.OutboxMessage `json:"outbox,omitempty"`
+}
+
+// FileRouteRepository mantiene las rutas en memoria y guarda cada mutación en un
+// write-ahead log sincronizado a disco antes de confirmarla. Al iniciar carga el
+// último snapshot y reproduce el log; cada cierta cantidad de entradas
+// (WithSnapshotEvery) compacta el estado en un snapshot nuevo y vacía el log.
+//
+// Cada registro del log es: longitud (uint32) | CRC-32 (uint32) | entrada JSON.
+// Un registro final incompleto o con CRC inválido se considera una escritura
+// interrumpida por una caída y se trunca.
+type FileRouteRepository struct {
+	mu            sync.Mutex
+	dir           string
+	mem           *InMemoryRouteRepository
+	wal           *os.File
+	seq           uint64
+	walSize       int64
+	walEntries    int
+	snapshotEvery int
+
+	// batch acumula las entradas en los repositorios que entrega WithinTx
+	batch *[]walEntry
+}
+
+// FileRouteRepositoryOption configura opciones del repositorio en archivo
+type FileRouteRepositoryOption func(*FileRouteRepository)
+
+// WithSnapshotEvery define cada cuántas entradas del log se compacta en un snapshot;
+// cero o negativo desactiva la compactación automática
+func WithSnapshotEvery(entries int) FileRouteRepositoryOption {
+	return func(r *FileRouteRepository) {
+		r.snapshotEvery = entries
+	}
+}
+
+// NewFileRouteRepository abre o crea el repositorio en el directorio indicado
+func NewFileRouteRepository(dir string, opts ...FileRouteRepositoryOption) (*FileRouteRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	r := &FileRouteRepository{
+		dir:           dir,
+		mem:           NewRouteRepository(),
+		snapshotEvery: defaultSnapshotEvery,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := r.replay(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(r.path(walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, snapshotFilePermission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	r.wal = wal
+
+	info, err := wal.Stat()
+	if err != nil {
+		wal.Close()
+		return nil, fmt.Errorf("failed to read write-ahead log: %w", err)
+	}
+	r.walSize = info.Size()
+
+	if err := syncDir(dir); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FileRouteRepository) Create(route domain.Route) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := route.Validate(); err != nil {
+		return 0, err
+	}
+
+	route.ID = r.mem.nextRouteID()
+	route.Version = 1
+
+	if err := r.write(walEntry{Op: walOpCreate, RouteID: route.ID, Route: &route}); err != nil {
+		return 0, err
+	}
+
+	return route.ID, nil
+}
+
+func (r *FileRouteRepository) GetByID(id int) (domain.Route, error) {
+	return r.mem.GetByID(id)
+}
+
+func (r *FileRouteRepository) Update(id int, route domain.Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.mem.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := route.Validate(); err != nil {
+		return err
+	}
+
+	// El conflicto se detecta antes de escribir para no registrar una entrada que no aplica
+	if route.Version != stored.Version {
+		return domain.NewVersionConflictError(id, route.Version, stored.Version)
+	}
+
+	return r.write(walEntry{Op: walOpUpdate, RouteID: id, Route: &route})
+}
+
+func (r *FileRouteRepository) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.mem.GetByID(id); err != nil {
+		return err
+	}
+
+	return r.write(walEntry{Op: walOpDelete, RouteID: id})
+}
+
+func (r *FileRouteRepository) List() ([]domain.Route, error) {
+	return r.mem.List()
+}
+
+func (r *FileRouteRepository) FindByStatus(status domain.RouteStatus) ([]domain.Route, error) {
+	return r.mem.FindByStatus(status)
+}
+
+func (r *FileRouteRepository) AssignPurchaseToRoute(routeID int, purchase domain.Purchase) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	route, err := r.mem.GetByID(routeID)
+	if err != nil {
+		return fmt.Errorf("route with ID %d not found: %w", routeID, domain.ErrNotFound)
+	}
+
+	for _, existingPurchase := range route.Purchases {
+		if existingPurchase.ID == purchase.ID {
+			return domain.NewDomainError(
+				domain.ErrorCodes.AlreadyExists,
+				fmt.Sprintf("purchase with ID %d already exists in route", purchase.ID),
+				domain.ErrPurchaseAlreadyExists,
+			)
+		}
+	}
+
+	return r.write(walEntry{Op: walOpAssign, RouteID: routeID, Purchase: &purchase})
+}
+
+// WithinTx ejecuta fn sobre una copia del estado y, si fn no devuelve error,
+// guarda todos sus cambios en un único registro del log. Una caída antes de
+// sincronizarlo descarta la transacción completa.
+func (r *FileRouteRepository) WithinTx(fn func(repo domain.RouteRepository) error) error {
+	if r.batch != nil {
+		return fn(r)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var batch []walEntry
+	tx := &FileRouteRepository{mem: r.mem.clone(), batch: &batch}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return r.write(walEntry{Op: walOpBatch, Entries: batch})
+}
+
+// AddOutboxMessage registra un mensaje pendiente en la bandeja de salida. Dentro de
+// WithinTx el mensaje viaja en el mismo registro del log que los cambios de las rutas.
+func (r *FileRouteRepository) AddOutboxMessage(payload []byte) (domain.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message := newOutboxMessage(r.mem.nextOutboxMessageID(), payload, time.Now().UTC())
+	if err := r.write(walEntry{Op: walOpOutbox, OutboxMessage: &message}); err != nil {
+		return domain.OutboxMessage{}, err
+	}
+
+	return message, nil
+}
+
+func (r *FileRouteRepository) PendingOutboxMessages(now time.Time, limit int) ([]domain.OutboxMessage, error) {
+	return r.mem.PendingOutboxMessages(now, limit)
+}
+
+func (r *FileRouteRepository) UpdateOutboxMessage(message domain.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.mem.outboxMessage(message.ID); !exists {
+		return errOutboxMessageNotFound(message.ID)
+	}
+
+	message.UpdatedAt = time.Now().UTC()
+	return r.write(walEntry{Op: walOpOutbox, OutboxMessage: &message})
+}
+
+func (r *FileRouteRepository) ListOutboxMessages(status domain.OutboxStatus) ([]domain.OutboxMessage, error) {
+	return r.mem.ListOutboxMessages(status)
+}
+
+// Compact guarda el estado actual en un snapshot y vacía el log
+func (r *FileRouteRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.compact()
+}
+
+// Close cierra el log; el repositorio no debe usarse después
+func (r *FileRouteRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.wal.Close()
+}
+
+// write registra la entrada en el log, la sincroniza a disco y recién entonces la
+// aplica en memoria. Requiere r.mu tomado.
+func (r *FileRouteRepository) write(entry walEntry) error {
+	if r.batch != nil {
+		if err := r.applyOp(entry); err != nil {
+			return err
+		}
+		*r.batch = append(*r.batch, entry)
+		return nil
+	}
+
+	entry.Seq = r.seq + 1
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode log entry: %w", err)
+	}
+
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walHeaderSize:], payload)
+
+	// Ante un error se descarta el registro parcial para no dejar basura en medio del log
+	if _, err := r.wal.Write(record); err != nil {
+		r.wal.Truncate(r.walSize)
+		return fmt.Errorf("failed to write log entry: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		r.wal.Truncate(r.walSize)
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	r.walSize += int64(len(record))
+
+	if err := r.apply(entry); err != nil {
+		return err
+	}
+	r.walEntries++
+
+	if r.snapshotEvery > 0 && r.walEntries >= r.snapshotEvery {
+		// La mutación ya es durable en el log; una compactación fallida se reintenta en la próxima
+		if err := r.compact(); err != nil {
+			log.Printf("failed to compact route log: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// apply aplica una entrada sobre el estado en memoria y avanza la secuencia
+func (r *FileRouteRepository) apply(entry walEntry) error {
+	if err := r.applyOp(entry); err != nil {
+		return err
+	}
+
+	r.seq = entry.Seq
+	return nil
+}
+
+func (r *FileRouteRepository) applyOp(entry walEntry) error {
+	var err error
+
+	switch entry.Op {
+	case walOpCreate:
+		if entry.Route == nil {
+			return fmt.Errorf("%w: create entry %d without route", ErrCorruptLog, entry.Seq)
+		}
+		r.mem.put(*entry.Route)
+	case walOpUpdate:
+		if entry.Route == nil {
+			return fmt.Errorf("%w: update entry %d without route", ErrCorruptLog, entry.Seq)
+		}
+		err = r.mem.Update(entry.RouteID, *entry.Route)
+	case walOpDelete:
+		err = r.mem.Delete(entry.RouteID)
+	case walOpAssign:
+		if entry.Purchase == nil {
+			return fmt.Errorf("%w: assign entry %d without purchase", ErrCorruptLog, entry.Seq)
+		}
+		err = r.mem.AssignPurchaseToRoute(entry.RouteID, *entry.Purchase)
+	case walOpOutbox:
+		if entry.OutboxMessage == nil {
+			return fmt.Errorf("%w: outbox entry %d without message", ErrCorruptLog, entry.Seq)
+		}
+		r.mem.putOutboxMessage(*entry.OutboxMessage)
+	case walOpBatch:
+		for _, batched := range entry.Entries {
+			if err := r.applyOp(batched); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q in entry %d", ErrCorruptLog, entry.Op, entry.Seq)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to apply log entry %d: %w", entry.Seq, err)
+	}
+
+	return nil
+}
+
+// compact escribe el snapshot de forma atómica (archivo temporal, fsync y rename)
+// y luego vacía el log. Si el proceso cae entre ambos pasos, las entradas que
+// quedan en el log ya están en el snapshot y se descartan por su Seq.
+func (r *FileRouteRepository) compact() error {
+	routes, nextID := r.mem.snapshot()
+	content, err := json.Marshal(walSnapshot{LastSeq: r.seq, NextID: nextID, Routes: routes, Outbox: r.mem.outboxMessages()})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp := r.path(snapshotFileName + ".tmp")
+	if err := writeFileSync(tmp, content); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path(snapshotFileName)); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	if err := r.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	r.walSize = 0
+	r.walEntries = 0
+
+	return nil
+}
+
+func (r *FileRouteRepository) loadSnapshot() error {
+	content, err := os.ReadFile(r.path(snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot walSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	r.mem.restore(snapshot.Routes, snapshot.NextID)
+	for _, message := range snapshot.Outbox {
+		r.mem.putOutboxMessage(message)
+	}
+	r.seq = snapshot.LastSeq
+
+	return nil
+}
+
+// replay reproduce el log sobre el snapshot y trunca un registro final dañado
+func (r *FileRouteRepository) replay() error {
+	content, err := os.ReadFile(r.path(walFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read write-ahead log: %w", err)
+	}
+
+	offset := 0
+	for offset < len(content) {
+		// Un final relleno con ceros es la escritura interrumpida más común tras un corte de luz
+		if allZeros(content[offset:]) {
+			return r.truncateTornRecord(offset, len(content))
+		}
+
+		remaining := len(content) - offset
+		if remaining < walHeaderSize {
+			return r.truncateTornRecord(offset, len(content))
+		}
+
+		length := int(binary.BigEndian.Uint32(content[offset : offset+4]))
+		checksum := binary.BigEndian.Uint32(content[offset+4 : offset+walHeaderSize])
+		end := offset + walHeaderSize + length
+		if length > remaining-walHeaderSize {
+			// Solo el último registro puede quedar incompleto: si detrás aparece un registro
+			// válido, la longitud está dañada y truncar descartaría entradas ya confirmadas
+			if next, ok := findValidRecord(content, offset+1); ok {
+				return fmt.Errorf("%w: invalid length at offset %d, valid record found at offset %d", ErrCorruptLog, offset, next)
+			}
+			return r.truncateTornRecord(offset, len(content))
+		}
+
+		payload := content[offset+walHeaderSize : end]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			if end == len(content) {
+				return r.truncateTornRecord(offset, len(content))
+			}
+			return fmt.Errorf("%w: invalid checksum at offset %d", ErrCorruptLog, offset)
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			if end == len(content) {
+				return r.truncateTornRecord(offset, len(content))
+			}
+			return fmt.Errorf("%w: invalid entry at offset %d: %v", ErrCorruptLog, offset, err)
+		}
+
+		// Las entradas anteriores al snapshot ya están aplicadas
+		if entry.Seq > r.seq {
+			if err := r.apply(entry); err != nil {
+				return err
+			}
+		}
+		r.walEntries++
+		offset = end
+	}
+
+	return nil
+}
+
+// findValidRecord busca desde from un registro con longitud y checksum coherentes
+func findValidRecord(content []byte, from int) (int, bool) {
+	for offset := from; offset+walHeaderSize <= len(content); offset++ {
+		length := int(binary.BigEndian.Uint32(content[offset : offset+4]))
+		if length == 0 || length > len(content)-offset-walHeaderSize {
+			continue
+		}
+
+		payload := content[offset+walHeaderSize : offset+walHeaderSize+length]
+		if crc32.ChecksumIEEE(payload) == binary.BigEndian.Uint32(content[offset+4:offset+walHeaderSize]) && json.Valid(payload) {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+func allZeros(content []byte) bool {
+	for _, b := range content {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *FileRouteRepository) truncateTornRecord(offset, size int) error {
+	log.Printf("truncating torn record at offset %d of %s (%d bytes discarded)", offset, r.path(walFileName), size-offset)
+
+	if err := os.Truncate(r.path(walFileName), int64(offset)); err != nil {
+		return fmt.Errorf("failed to truncate torn record: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRouteRepository) path(name string) string {
+	return filepath.Join(r.dir, name)
+}
+
+func writeFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, snapshotFilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// syncDir sincroniza el directorio para que las creaciones y renombres sobrevivan a una caída
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open data directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync data directory: %w", err)
+	}
+	return nil
+}
+
+var (
+	_ domain.RouteRepository = &FileRouteRepository{}
+	_ domain.RouteTransactor = &FileRouteRepository{}
+	_ domain.OutboxStore     = &FileRouteRepository{}
+)
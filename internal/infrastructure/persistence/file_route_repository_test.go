@@ -0,0 +1,247 @@
+package persistence_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"transport-challenge/internal/domain"
+	"transport-challenge/internal/infrastructure/persistence"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileRepository(t *testing.T, dir string, opts ...persistence.FileRouteRepositoryOption) *persistence.FileRouteRepository {
+	t.Helper()
+
+	repo, err := persistence.NewFileRouteRepository(dir, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestFileRouteRepository_ReplaysLogAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir)
+
+	keptID, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+	deletedID, err := repo.Create(newRoute("Zona Sur"))
+	require.NoError(t, err)
+
+	at := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.AssignPurchaseToRoute(keptID, domain.NewPurchase(1, "Heladera", at)))
+	updated := newRoute("Zona Norte - Turno Tarde")
+	updated.ID = keptID
+	updated.Version = 2
+	updated.Purchases = []domain.Purchase{domain.NewPurchase(1, "Heladera", at)}
+	require.NoError(t, repo.Update(keptID, updated))
+	require.NoError(t, repo.Delete(deletedID))
+	require.NoError(t, repo.Close())
+
+	reopened := newFileRepository(t, dir)
+	routes, err := reopened.List()
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.Equal(t, "Zona Norte - Turno Tarde", routes[0].Name)
+	assert.Len(t, routes[0].Purchases, 1)
+
+	nextID, err := reopened.Create(newRoute("Zona Oeste"))
+	require.NoError(t, err)
+	assert.Equal(t, deletedID+1, nextID)
+}
+
+func TestFileRouteRepository_CompactsIntoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir, persistence.WithSnapshotEvery(2))
+
+	for _, name := range []string{"Zona Norte", "Zona Sur", "Zona Oeste"} {
+		_, err := repo.Create(newRoute(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	_, err := os.Stat(filepath.Join(dir, "snapshot.json"))
+	require.NoError(t, err)
+	assert.Less(t, fileSize(t, filepath.Join(dir, "wal.log")), int64(1024), "the log should only keep the entry written after the snapshot")
+
+	reopened := newFileRepository(t, dir)
+	routes, err := reopened.List()
+	require.NoError(t, err)
+	assert.Len(t, routes, 3)
+}
+
+func TestFileRouteRepository_SkipsLogEntriesAlreadyInSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir, persistence.WithSnapshotEvery(0))
+
+	_, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+	_, err = repo.Create(newRoute("Zona Sur"))
+	require.NoError(t, err)
+
+	walPath := filepath.Join(dir, "wal.log")
+	walBeforeCompaction, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Compact())
+	require.NoError(t, repo.Close())
+
+	// Simula una caída después de escribir el snapshot y antes de vaciar el log
+	require.NoError(t, os.WriteFile(walPath, walBeforeCompaction, 0o644))
+
+	reopened := newFileRepository(t, dir)
+	routes, err := reopened.List()
+	require.NoError(t, err)
+	assert.Len(t, routes, 2)
+}
+
+func TestFileRouteRepository_TruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir)
+
+	_, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "wal.log")
+	validSize := fileSize(t, walPath)
+
+	testCases := []struct {
+		name string
+		torn []byte
+	}{
+		{"Header incompleto", []byte{0, 0, 0}},
+		{"Payload incompleto", []byte{0, 0, 0, 50, 1, 2, 3, 4, '{', '"'}},
+		{"Checksum inválido", []byte{0, 0, 0, 2, 1, 2, 3, 4, '{', '}'}},
+		{"Final relleno con ceros", make([]byte, 16)},
+		{"JSON final incompleto con checksum válido", []byte{0, 0, 0, 1, 0x15, 0xd5, 0x47, 0x39, '{'}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+			require.NoError(t, err)
+			_, err = f.Write(tc.torn)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			reopened, err := persistence.NewFileRouteRepository(dir)
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			assert.Equal(t, validSize, fileSize(t, walPath))
+			routes, err := reopened.List()
+			require.NoError(t, err)
+			assert.Len(t, routes, 1)
+		})
+	}
+}
+
+func TestFileRouteRepository_RejectsCorruptRecordInTheMiddle(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir)
+
+	_, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+	_, err = repo.Create(newRoute("Zona Sur"))
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "wal.log")
+	content, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	content[10] ^= 0xff
+	require.NoError(t, os.WriteFile(walPath, content, 0o644))
+
+	_, err = persistence.NewFileRouteRepository(dir)
+	assert.ErrorIs(t, err, persistence.ErrCorruptLog)
+}
+
+func TestFileRouteRepository_RejectsCorruptLengthInTheMiddle(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir)
+
+	_, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+	_, err = repo.Create(newRoute("Zona Sur"))
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "wal.log")
+	content, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	content[0] = 0xff
+	require.NoError(t, os.WriteFile(walPath, content, 0o644))
+
+	_, err = persistence.NewFileRouteRepository(dir)
+	assert.ErrorIs(t, err, persistence.ErrCorruptLog)
+	assert.Equal(t, int64(len(content)), fileSize(t, walPath))
+}
+
+func TestFileRouteRepository_TransactionSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir)
+
+	id, err := repo.Create(newRoute("Zona Norte"))
+	require.NoError(t, err)
+
+	err = repo.WithinTx(func(tx domain.RouteRepository) error {
+		if err := tx.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", time.Now())); err != nil {
+			return err
+		}
+		route, err := tx.GetByID(id)
+		if err != nil {
+			return err
+		}
+		route.Status = domain.RouteStatusInProgress
+		return tx.Update(id, route)
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	reopened := newFileRepository(t, dir)
+	route, err := reopened.GetByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RouteStatusInProgress, route.Status)
+	assert.Len(t, route.Purchases, 1)
+}
+
+func TestFileRouteRepository_OutboxSurvivesRestartAndCompaction(t *testing.T) {
+	dir := t.TempDir()
+	repo := newFileRepository(t, dir, persistence.WithSnapshotEvery(2))
+
+	for id := 1; id <= 3; id++ {
+		_, err := repo.AddOutboxMessage([]byte(fmt.Sprintf(`{"id_compra":%d}`, id)))
+		require.NoError(t, err)
+	}
+	messages, err := repo.ListOutboxMessages("")
+	require.NoError(t, err)
+	message := messages[0]
+	message.Status = domain.OutboxStatusFailed
+	require.NoError(t, repo.UpdateOutboxMessage(message))
+	require.NoError(t, repo.Close())
+
+	reopened := newFileRepository(t, dir)
+	messages, err = reopened.ListOutboxMessages("")
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	assert.Equal(t, domain.OutboxStatusFailed, messages[0].Status)
+	assert.JSONEq(t, `{"id_compra":1}`, string(messages[0].Payload))
+
+	added, err := reopened.AddOutboxMessage([]byte(`{"id_compra":4}`))
+	require.NoError(t, err)
+	assert.Equal(t, 4, added.ID)
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Size()
+}
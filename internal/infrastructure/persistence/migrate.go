@@ -0,0 +1,559 @@
+package persistence
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"transport-challenge/config"
+)
+
+// embeddedMigrations contiene un directorio de migraciones por driver, con archivos
+// <versión>_<nombre>.up.sql y <versión>_<nombre>.down.sql
+//
+//go:embed migrations
+var embeddedMigrations embed.FS
+
+// ErrMigrationLocked indica que otro proceso está aplicando migraciones
+var ErrMigrationLocked = errors.New("migrations are locked by another runner")
+
+const (
+	defaultLockTimeout    = 30 * time.Second
+	defaultStaleLockAfter = 15 * time.Minute
+	lockPollInterval      = 100 * time.Millisecond
+
+	// lockHeartbeatsPerStaleAfter es cuántas veces se renueva el bloqueo en cada staleAfter
+	lockHeartbeatsPerStaleAfter = 3
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration es un cambio de esquema versionado con su SQL de aplicación y reversión
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus indica si una migración conocida fue aplicada y cuándo
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator aplica y revierte las migraciones registrándolas en schema_migrations.
+// Cada migración corre en su propia transacción; en MySQL las sentencias DDL
+// confirman la transacción implícitamente, por lo que conviene una sentencia
+// DDL por migración.
+type Migrator struct {
+	db          *sql.DB
+	driver      string
+	files       fs.FS
+	dryRun      io.Writer
+	lockTimeout time.Duration
+	staleAfter  time.Duration
+	owner       string
+	now         func() time.Time
+}
+
+// MigratorOption configura opciones del Migrator
+type MigratorOption func(*Migrator)
+
+// WithDryRun escribe el SQL que se ejecutaría en out sin modificar la base
+func WithDryRun(out io.Writer) MigratorOption {
+	return func(m *Migrator) {
+		m.dryRun = out
+	}
+}
+
+// WithLockTimeout define cuánto esperar a que otro proceso libere el bloqueo
+func WithLockTimeout(timeout time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.lockTimeout = timeout
+	}
+}
+
+// WithStaleLockAfter define la antigüedad a partir de la cual un bloqueo se
+// considera abandonado por un proceso que terminó sin liberarlo y se toma. Quien
+// tiene el bloqueo renueva locked_at varias veces en ese lapso mientras migra.
+// Un valor <= 0 desactiva la toma de bloqueos viejos.
+func WithStaleLockAfter(age time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.staleAfter = age
+	}
+}
+
+// WithMigrationFiles reemplaza las migraciones embebidas por las de fsys,
+// que debe contener los archivos .sql en su raíz
+func WithMigrationFiles(fsys fs.FS) MigratorOption {
+	return func(m *Migrator) {
+		m.files = fsys
+	}
+}
+
+func NewMigrator(db *sql.DB, driver string, opts ...MigratorOption) *Migrator {
+	hostname, _ := os.Hostname()
+
+	migrator := &Migrator{
+		db:          db,
+		driver:      driver,
+		lockTimeout: defaultLockTimeout,
+		staleAfter:  defaultStaleLockAfter,
+		owner:       fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(migrator)
+	}
+
+	return migrator
+}
+
+// Migrations devuelve las migraciones disponibles ordenadas por versión
+func (m *Migrator) Migrations() ([]Migration, error) {
+	files := m.files
+	if files == nil {
+		sub, err := fs.Sub(embeddedMigrations, "migrations/"+m.driver)
+		if err != nil {
+			return nil, fmt.Errorf("no migrations for driver %q: %w", m.driver, err)
+		}
+		files = sub
+	}
+
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		parts := migrationFileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(parts[1])
+		content, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, migration.Name, parts[2])
+		}
+
+		if parts[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up aplica hasta steps migraciones pendientes en orden, o todas si steps <= 0.
+// Devuelve las migraciones aplicadas, o las que se aplicarían en modo dry-run.
+func (m *Migrator) Up(steps int) ([]Migration, error) {
+	return m.run(func(migrations []Migration, applied map[int]time.Time) ([]Migration, error) {
+		known := make(map[int]bool, len(migrations))
+		for _, migration := range migrations {
+			known[migration.Version] = true
+		}
+		for version := range applied {
+			if !known[version] {
+				return nil, fmt.Errorf("database has migration %d applied, which is unknown to this version", version)
+			}
+		}
+
+		var pending []Migration
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				pending = append(pending, migration)
+			}
+		}
+		return limit(pending, steps), nil
+	}, true)
+}
+
+// Down revierte hasta steps migraciones aplicadas, empezando por la más reciente,
+// o todas si steps <= 0
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	return m.run(func(migrations []Migration, applied map[int]time.Time) ([]Migration, error) {
+		var rollback []Migration
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version]; ok {
+				rollback = append(rollback, migrations[i])
+			}
+		}
+		if len(rollback) < len(applied) {
+			return nil, errors.New("database has applied migrations unknown to this version")
+		}
+		return limit(rollback, steps), nil
+	}, false)
+}
+
+// Status devuelve el estado de cada migración conocida
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if at, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// ForceUnlock libera el bloqueo dejado por un proceso que terminó sin liberarlo
+func (m *Migrator) ForceUnlock() error {
+	exists, err := m.tableExists("schema_migrations_lock")
+	if err != nil || !exists {
+		return err
+	}
+
+	if _, err := m.db.Exec(`DELETE FROM schema_migrations_lock WHERE id = 1`); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	return nil
+}
+
+// run selecciona las migraciones con plan y las aplica bajo el bloqueo.
+// En modo dry-run no crea tablas ni toma el bloqueo.
+func (m *Migrator) run(plan func([]Migration, map[int]time.Time) ([]Migration, error), up bool) ([]Migration, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.dryRun == nil {
+		if err := m.ensureTables(); err != nil {
+			return nil, err
+		}
+		if err := m.lock(); err != nil {
+			return nil, err
+		}
+		defer m.unlock()
+
+		stop := m.heartbeat()
+		defer stop()
+	}
+
+	// Las migraciones aplicadas se leen con el bloqueo tomado
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := plan(migrations, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migration := range selected {
+		if err := m.apply(migration, up); err != nil {
+			return selected[:i], err
+		}
+	}
+
+	return selected, nil
+}
+
+func (m *Migrator) apply(migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %04d_%s (%s)\n%s\n", migration.Version, migration.Name, direction, strings.TrimSpace(script))
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d_%s (%s) failed: %w", migration.Version, migration.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, migration.Version, migration.Name, m.now().UTC())
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) ensureTables() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INTEGER PRIMARY KEY,
+			owner VARCHAR(255) NOT NULL,
+			locked_at DATETIME NOT NULL
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := m.db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create migration tables: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lock toma el bloqueo insertando la única fila de schema_migrations_lock;
+// si otro proceso la tiene, reintenta hasta lockTimeout. Un bloqueo más viejo
+// que staleAfter se considera abandonado y se toma.
+func (m *Migrator) lock() error {
+	deadline := m.now().Add(m.lockTimeout)
+
+	for {
+		_, insertErr := m.db.Exec(`INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)`, m.owner, m.now().UTC())
+		if insertErr == nil {
+			return nil
+		}
+
+		var owner string
+		var lockedAt time.Time
+		err := m.db.QueryRow(`SELECT owner, locked_at FROM schema_migrations_lock WHERE id = 1`).Scan(&owner, &lockedAt)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to read migration lock: %w", err)
+		}
+
+		if err == nil && m.isStale(lockedAt) {
+			taken, err := m.takeOverLock(owner)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return nil
+			}
+			continue
+		}
+
+		if !m.now().Before(deadline) {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to acquire migration lock: %w", insertErr)
+			}
+			return fmt.Errorf("%w (held by %s since %s)", ErrMigrationLocked, owner, lockedAt.UTC().Format(time.RFC3339))
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
+
+func (m *Migrator) isStale(lockedAt time.Time) bool {
+	return m.staleAfter > 0 && m.now().Sub(lockedAt) >= m.staleAfter
+}
+
+// takeOverLock reemplaza al dueño de un bloqueo abandonado. La condición sobre
+// el dueño anterior evita que dos procesos tomen el mismo bloqueo a la vez.
+func (m *Migrator) takeOverLock(staleOwner string) (bool, error) {
+	result, err := m.db.Exec(
+		`UPDATE schema_migrations_lock SET owner = ?, locked_at = ? WHERE id = 1 AND owner = ?`,
+		m.owner, m.now().UTC(), staleOwner,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to take over stale migration lock: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to take over stale migration lock: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// heartbeat renueva locked_at periódicamente mientras se aplican las migraciones, para que
+// otro proceso no tome como abandonado el bloqueo durante una migración larga. Devuelve la
+// función que lo detiene y espera a que termine.
+func (m *Migrator) heartbeat() func() {
+	if m.staleAfter <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(m.staleAfter / lockHeartbeatsPerStaleAfter)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				m.refreshLock()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// refreshLock actualiza locked_at si el bloqueo sigue siendo de este proceso
+func (m *Migrator) refreshLock() {
+	result, err := m.db.Exec(`UPDATE schema_migrations_lock SET locked_at = ? WHERE id = 1 AND owner = ?`, m.now().UTC(), m.owner)
+	if err != nil {
+		log.Printf("failed to refresh migration lock: %v", err)
+		return
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		log.Printf("migration lock of %s was taken by another runner", m.owner)
+	}
+}
+
+func (m *Migrator) unlock() {
+	m.db.Exec(`DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?`, m.owner)
+}
+
+// appliedMigrations devuelve la fecha de aplicación de cada versión registrada
+func (m *Migrator) appliedMigrations() (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	exists, err := m.tableExists("schema_migrations")
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to read applied migration: %w", err)
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+func (m *Migrator) tableExists(name string) (bool, error) {
+	query := `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	if m.driver == config.DriverSQLite {
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	}
+
+	var count int
+	if err := m.db.QueryRow(query, name).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", name, err)
+	}
+	return count > 0, nil
+}
+
+// splitStatements separa un script en sentencias terminadas en ";" al final de
+// línea, ya que los drivers no ejecutan varias sentencias en una sola llamada
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+func limit(migrations []Migration, steps int) []Migration {
+	if steps > 0 && steps < len(migrations) {
+		return migrations[:steps]
+	}
+	return migrations
+}
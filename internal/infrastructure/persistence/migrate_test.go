@@ -0,0 +1,199 @@
+package persistence_test
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"transport-challenge/config"
+	"transport-challenge/internal/infrastructure/persistence"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := persistence.OpenDatabase(config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		Name:   filepath.Join(t.TempDir(), "migrations.db"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count))
+	return count > 0
+}
+
+func TestMigrator_UpAppliesPendingMigrations(t *testing.T) {
+	db := newSQLiteDB(t)
+	migrator := persistence.NewMigrator(db, config.DriverSQLite)
+
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Len(t, applied, 6)
+	assert.True(t, tableExists(t, db, "routes"))
+	assert.True(t, tableExists(t, db, "purchase_status_changes"))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, "migration %d should be applied", status.Version)
+		assert.NotNil(t, status.AppliedAt)
+	}
+
+	applied, err = migrator.Up(0)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrator_DownRollsBackInReverseOrder(t *testing.T) {
+	db := newSQLiteDB(t)
+	migrator := persistence.NewMigrator(db, config.DriverSQLite)
+	_, err := migrator.Up(0)
+	require.NoError(t, err)
+
+	rolledBack, err := migrator.Down(5)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 5)
+	assert.Equal(t, 6, rolledBack[0].Version)
+	assert.Equal(t, 2, rolledBack[4].Version)
+	assert.False(t, tableExists(t, db, "purchases"))
+	assert.True(t, tableExists(t, db, "routes"))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	_, err = migrator.Down(0)
+	require.NoError(t, err)
+	assert.False(t, tableExists(t, db, "routes"))
+}
+
+func TestMigrator_DryRunDoesNotModifyTheDatabase(t *testing.T) {
+	db := newSQLiteDB(t)
+	var out bytes.Buffer
+
+	planned, err := persistence.NewMigrator(db, config.DriverSQLite, persistence.WithDryRun(&out)).Up(0)
+	require.NoError(t, err)
+	assert.Len(t, planned, 6)
+	assert.Contains(t, out.String(), "-- 0001_create_routes (up)")
+	assert.Contains(t, out.String(), "CREATE TABLE IF NOT EXISTS routes")
+
+	assert.False(t, tableExists(t, db, "routes"))
+	assert.False(t, tableExists(t, db, "schema_migrations"))
+}
+
+func TestMigrator_LockBlocksConcurrentRunners(t *testing.T) {
+	db := newSQLiteDB(t)
+	_, err := persistence.NewMigrator(db, config.DriverSQLite).Up(1)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'otro-host:42', ?)`, time.Now().UTC())
+	require.NoError(t, err)
+
+	migrator := persistence.NewMigrator(db, config.DriverSQLite, persistence.WithLockTimeout(50*time.Millisecond))
+	_, err = migrator.Up(0)
+	assert.ErrorIs(t, err, persistence.ErrMigrationLocked)
+	assert.Contains(t, err.Error(), "otro-host:42")
+
+	require.NoError(t, migrator.ForceUnlock())
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Len(t, applied, 5)
+}
+
+func TestMigrator_TakesOverStaleLock(t *testing.T) {
+	db := newSQLiteDB(t)
+	_, err := persistence.NewMigrator(db, config.DriverSQLite).Up(1)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'otro-host:42', ?)`, time.Now().Add(-time.Hour).UTC())
+	require.NoError(t, err)
+
+	migrator := persistence.NewMigrator(db, config.DriverSQLite,
+		persistence.WithLockTimeout(50*time.Millisecond),
+		persistence.WithStaleLockAfter(10*time.Minute),
+	)
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Len(t, applied, 5)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations_lock`).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestMigrator_HeartbeatKeepsLockFresh(t *testing.T) {
+	db := newSQLiteDB(t)
+	files := fstest.MapFS{
+		"0001_create_items.up.sql":   {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);\n")},
+		"0001_create_items.down.sql": {Data: []byte("DROP TABLE items;\n")},
+		// Una consulta lenta deja pasar varios intervalos de renovación
+		"0002_slow.up.sql": {Data: []byte(
+			"WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 500000) SELECT COUNT(*) FROM counter;\n",
+		)},
+		"0002_slow.down.sql": {Data: []byte("SELECT 1;\n")},
+		"0003_observe_lock.up.sql": {Data: []byte(
+			"CREATE TABLE observed AS SELECT l.locked_at > m.applied_at AS refreshed FROM schema_migrations_lock l, schema_migrations m WHERE m.version = 1;\n",
+		)},
+		"0003_observe_lock.down.sql": {Data: []byte("DROP TABLE observed;\n")},
+	}
+
+	migrator := persistence.NewMigrator(db, config.DriverSQLite,
+		persistence.WithMigrationFiles(files),
+		persistence.WithStaleLockAfter(30*time.Millisecond),
+	)
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+
+	var refreshed bool
+	require.NoError(t, db.QueryRow(`SELECT refreshed FROM observed`).Scan(&refreshed))
+	assert.True(t, refreshed, "the lock should be refreshed while migrations run")
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	db := newSQLiteDB(t)
+	files := fstest.MapFS{
+		"0001_create_items.up.sql":   {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);\n")},
+		"0001_create_items.down.sql": {Data: []byte("DROP TABLE items;\n")},
+		"0002_broken.up.sql": {Data: []byte(
+			"-- la segunda sentencia falla\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\nINSERT INTO missing (id) VALUES (1);\n",
+		)},
+		"0002_broken.down.sql": {Data: []byte("DROP TABLE tags;\n")},
+	}
+	migrator := persistence.NewMigrator(db, config.DriverSQLite, persistence.WithMigrationFiles(files))
+
+	applied, err := migrator.Up(0)
+	assert.Error(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 1, applied[0].Version)
+	assert.False(t, tableExists(t, db, "tags"))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestMigrator_RejectsIncompleteMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"0001_create_items.up.sql": {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);\n")},
+	}
+
+	_, err := persistence.NewMigrator(nil, config.DriverSQLite, persistence.WithMigrationFiles(files)).Migrations()
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS routes (
+	id INTEGER PRIMARY KEY AUTO_INCREMENT,
+	name VARCHAR(255) NOT NULL,
+	vehicle VARCHAR(255) NOT NULL,
+	driver VARCHAR(255) NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	cancellation_reason VARCHAR(255) NOT NULL DEFAULT '',
+	cancelled_at DATETIME(6) NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS purchase_status_changes (
+	route_id INTEGER NOT NULL,
+	purchase_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	from_status VARCHAR(32) NOT NULL,
+	to_status VARCHAR(32) NOT NULL,
+	changed_at DATETIME(6) NOT NULL,
+	PRIMARY KEY (route_id, purchase_id, position),
+	FOREIGN KEY (route_id, purchase_id) REFERENCES purchases (route_id, id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP INDEX idx_routes_status ON routes;
@@ -0,0 +1 @@
+CREATE INDEX idx_routes_status ON routes (status);
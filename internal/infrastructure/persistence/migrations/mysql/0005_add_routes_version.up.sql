@@ -0,0 +1 @@
+ALTER TABLE routes ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
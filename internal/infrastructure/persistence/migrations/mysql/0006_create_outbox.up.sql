@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY AUTO_INCREMENT,
+	payload TEXT NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL,
+	channels TEXT NOT NULL,
+	next_attempt_at DATETIME(6) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	INDEX idx_outbox_status_next_attempt (status, next_attempt_at)
+);
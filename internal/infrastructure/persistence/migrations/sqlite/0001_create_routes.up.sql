@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS routes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	vehicle VARCHAR(255) NOT NULL,
+	driver VARCHAR(255) NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	cancellation_reason VARCHAR(255) NOT NULL DEFAULT '',
+	cancelled_at DATETIME NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS purchases (
+	route_id INTEGER NOT NULL,
+	id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	recipient VARCHAR(255) NOT NULL DEFAULT '',
+	recipient_locale VARCHAR(16) NOT NULL DEFAULT '',
+	status VARCHAR(32) NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	PRIMARY KEY (route_id, id),
+	FOREIGN KEY (route_id) REFERENCES routes (id) ON DELETE CASCADE
+);
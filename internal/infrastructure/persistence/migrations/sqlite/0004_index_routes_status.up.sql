@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_routes_status ON routes (status);
@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"transport-challenge/internal/domain"
+)
+
+// Los repositorios también guardan la bandeja de salida (domain.OutboxStore), de modo que
+// un mensaje agregado dentro de WithinTx se confirma o se descarta junto con el cambio de
+// la ruta que lo origina.
+
+func newOutboxMessage(id int, payload []byte, now time.Time) domain.OutboxMessage {
+	return domain.OutboxMessage{
+		ID:            id,
+		Payload:       append([]byte(nil), payload...),
+		Status:        domain.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// pendingOutboxMessages devuelve, ordenados por ID, los mensajes pendientes cuyo
+// próximo intento ya llegó
+func pendingOutboxMessages(messages []domain.OutboxMessage, now time.Time, limit int) []domain.OutboxMessage {
+	var pending []domain.OutboxMessage
+	for _, message := range messages {
+		if message.Status == domain.OutboxStatusPending && !message.NextAttemptAt.After(now) {
+			pending = append(pending, message)
+		}
+	}
+
+	sortOutboxMessages(pending)
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	return pending
+}
+
+// filterOutboxMessages devuelve los mensajes en el estado indicado, o todos si es vacío
+func filterOutboxMessages(messages []domain.OutboxMessage, status domain.OutboxStatus) []domain.OutboxMessage {
+	if status == "" {
+		return messages
+	}
+
+	filtered := make([]domain.OutboxMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Status == status {
+			filtered = append(filtered, message)
+		}
+	}
+
+	return filtered
+}
+
+func sortOutboxMessages(messages []domain.OutboxMessage) {
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+}
+
+func errOutboxMessageNotFound(id int) error {
+	return fmt.Errorf("outbox message %d not found", id)
+}
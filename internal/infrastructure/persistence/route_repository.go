@@ -2,7 +2,9 @@ package persistence
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 	"transport-challenge/internal/domain"
 )
 
@@ -10,12 +12,17 @@ type InMemoryRouteRepository struct {
 	mu     sync.RWMutex
 	routes map[int]domain.Route
 	nextID int
+
+	outbox       map[int]domain.OutboxMessage
+	nextOutboxID int
 }
 
 func NewRouteRepository() *InMemoryRouteRepository {
 	return &InMemoryRouteRepository{
-		routes: make(map[int]domain.Route),
-		nextID: 1,
+		routes:       make(map[int]domain.Route),
+		nextID:       1,
+		outbox:       make(map[int]domain.OutboxMessage),
+		nextOutboxID: 1,
 	}
 }
 
@@ -28,8 +35,9 @@ func (r *InMemoryRouteRepository) Create(route domain.Route) (int, error) {
 		return 0, err
 	}
 
-	// Asignar ID
+	// Asignar ID y versión inicial
 	route.ID = r.nextID
+	route.Version = 1
 	r.routes[r.nextID] = route
 
 	// Incrementar el próximo ID
@@ -54,7 +62,7 @@ func (r *InMemoryRouteRepository) Update(id int, route domain.Route) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, exists := r.routes[id]
+	stored, exists := r.routes[id]
 	if !exists {
 		return domain.ErrNotFound
 	}
@@ -63,6 +71,11 @@ func (r *InMemoryRouteRepository) Update(id int, route domain.Route) error {
 		return err
 	}
 
+	if route.Version != stored.Version {
+		return domain.NewVersionConflictError(id, route.Version, stored.Version)
+	}
+
+	route.Version++
 	r.routes[id] = route
 
 	return nil
@@ -113,19 +126,210 @@ func (r *InMemoryRouteRepository) AssignPurchaseToRoute(routeID int, purchase do
 
 	route, exists := r.routes[routeID]
 	if !exists {
-		return fmt.Errorf("route with ID %d not found", routeID)
+		return fmt.Errorf("route with ID %d not found: %w", routeID, domain.ErrNotFound)
 	}
 
 	for _, existingPurchase := range route.Purchases {
 		if existingPurchase.ID == purchase.ID {
-			return fmt.Errorf("purchase with ID %d already exists in route", purchase.ID)
+			return domain.NewDomainError(
+				domain.ErrorCodes.AlreadyExists,
+				fmt.Sprintf("purchase with ID %d already exists in route", purchase.ID),
+				domain.ErrPurchaseAlreadyExists,
+			)
 		}
 	}
 
 	route.Purchases = append(route.Purchases, purchase)
+	route.Version++
 	r.routes[routeID] = route
 
 	return nil
 }
 
-var _ domain.RouteRepository = &InMemoryRouteRepository{}
+// WithinTx ejecuta fn sobre una copia del repositorio y la confirma si fn no
+// devuelve error. Las escrituras concurrentes esperan a que termine.
+func (r *InMemoryRouteRepository) WithinTx(fn func(repo domain.RouteRepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := r.cloneLocked()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.routes = tx.routes
+	r.nextID = tx.nextID
+	r.outbox = tx.outbox
+	r.nextOutboxID = tx.nextOutboxID
+
+	return nil
+}
+
+// AddOutboxMessage registra un mensaje pendiente en la bandeja de salida
+func (r *InMemoryRouteRepository) AddOutboxMessage(payload []byte) (domain.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message := newOutboxMessage(r.nextOutboxID, payload, time.Now())
+	r.putOutboxMessageLocked(message)
+
+	return message, nil
+}
+
+func (r *InMemoryRouteRepository) PendingOutboxMessages(now time.Time, limit int) ([]domain.OutboxMessage, error) {
+	return pendingOutboxMessages(r.outboxMessages(), now, limit), nil
+}
+
+func (r *InMemoryRouteRepository) UpdateOutboxMessage(message domain.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.outbox[message.ID]; !exists {
+		return errOutboxMessageNotFound(message.ID)
+	}
+
+	message.UpdatedAt = time.Now()
+	r.outbox[message.ID] = message
+
+	return nil
+}
+
+func (r *InMemoryRouteRepository) ListOutboxMessages(status domain.OutboxStatus) ([]domain.OutboxMessage, error) {
+	return filterOutboxMessages(r.outboxMessages(), status), nil
+}
+
+// clone devuelve una copia independiente del repositorio
+func (r *InMemoryRouteRepository) clone() *InMemoryRouteRepository {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cloneLocked()
+}
+
+// cloneLocked es clone con r.mu ya tomado
+func (r *InMemoryRouteRepository) cloneLocked() *InMemoryRouteRepository {
+	copied := &InMemoryRouteRepository{
+		routes:       make(map[int]domain.Route, len(r.routes)),
+		nextID:       r.nextID,
+		outbox:       make(map[int]domain.OutboxMessage, len(r.outbox)),
+		nextOutboxID: r.nextOutboxID,
+	}
+	for id, route := range r.routes {
+		copied.routes[id] = route
+	}
+	for id, message := range r.outbox {
+		copied.outbox[id] = message
+	}
+
+	return copied
+}
+
+// snapshot devuelve una copia de las rutas ordenadas por ID y el próximo ID a asignar
+func (r *InMemoryRouteRepository) snapshot() ([]domain.Route, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]domain.Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+
+	return routes, r.nextID
+}
+
+// restore reemplaza el contenido del repositorio por las rutas indicadas
+func (r *InMemoryRouteRepository) restore(routes []domain.Route, nextID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = make(map[int]domain.Route, len(routes))
+	r.nextID = 1
+	for _, route := range routes {
+		r.routes[route.ID] = route
+		if route.ID >= r.nextID {
+			r.nextID = route.ID + 1
+		}
+	}
+	if nextID > r.nextID {
+		r.nextID = nextID
+	}
+}
+
+// nextRouteID devuelve el ID que recibirá la próxima ruta creada
+func (r *InMemoryRouteRepository) nextRouteID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextID
+}
+
+// put guarda la ruta con el ID que ya trae asignado
+func (r *InMemoryRouteRepository) put(route domain.Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[route.ID] = route
+	if route.ID >= r.nextID {
+		r.nextID = route.ID + 1
+	}
+}
+
+// outboxMessages devuelve una copia de la bandeja de salida ordenada por ID
+func (r *InMemoryRouteRepository) outboxMessages() []domain.OutboxMessage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := make([]domain.OutboxMessage, 0, len(r.outbox))
+	for _, message := range r.outbox {
+		messages = append(messages, message)
+	}
+	sortOutboxMessages(messages)
+
+	return messages
+}
+
+// outboxMessage devuelve el mensaje de la bandeja de salida con el ID indicado
+func (r *InMemoryRouteRepository) outboxMessage(id int) (domain.OutboxMessage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	message, exists := r.outbox[id]
+	return message, exists
+}
+
+// nextOutboxMessageID devuelve el ID que recibirá el próximo mensaje de la bandeja de salida
+func (r *InMemoryRouteRepository) nextOutboxMessageID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextOutboxID
+}
+
+// putOutboxMessage guarda el mensaje de la bandeja de salida tal como viene
+func (r *InMemoryRouteRepository) putOutboxMessage(message domain.OutboxMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.putOutboxMessageLocked(message)
+}
+
+func (r *InMemoryRouteRepository) putOutboxMessageLocked(message domain.OutboxMessage) {
+	r.outbox[message.ID] = message
+	if message.ID >= r.nextOutboxID {
+		r.nextOutboxID = message.ID + 1
+	}
+}
+
+// sortRoutes ordena por ID para que los snapshots sean deterministas
+func sortRoutes(routes []domain.Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].ID < routes[j].ID
+	})
+}
+
+var (
+	_ domain.RouteRepository = &InMemoryRouteRepository{}
+	_ domain.RouteTransactor = &InMemoryRouteRepository{}
+	_ domain.OutboxStore     = &InMemoryRouteRepository{}
+)
@@ -1,37 +1,357 @@
 package persistence_test
 
 import (
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"transport-challenge/config"
+	"transport-challenge/internal/domain"
 	"transport-challenge/internal/infrastructure/persistence"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
 )
 
-func TestRouteRepository_CreateRoute(t *testing.T) {
+// repositories devuelve las implementaciones que deben cumplir el mismo contrato
+func repositories(t *testing.T) map[string]func() domain.RouteRepository {
+	return map[string]func() domain.RouteRepository{
+		"InMemory": func() domain.RouteRepository {
+			return persistence.NewRouteRepository()
+		},
+		"SQLite": func() domain.RouteRepository {
+			return newSQLiteRepository(t)
+		},
+		"File": func() domain.RouteRepository {
+			return newFileRepository(t, t.TempDir())
+		},
+	}
+}
+
+func newSQLiteRepository(t *testing.T) *persistence.SQLRouteRepository {
+	t.Helper()
+
+	cfg := config.DatabaseConfig{Driver: config.DriverSQLite, Name: filepath.Join(t.TempDir(), "routes.db")}
+	db, err := persistence.OpenDatabase(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = persistence.NewMigrator(db, cfg.Driver).Up(0)
+	require.NoError(t, err)
+
+	return persistence.NewSQLRouteRepository(db)
+}
+
+func newRoute(name string) domain.Route {
+	now := time.Date(2024, 5, 10, 9, 30, 0, 123456000, time.UTC)
+	return domain.Route{
+		Name:      name,
+		Vehicle:   "ABC-123",
+		Driver:    "Julián",
+		Status:    domain.RouteStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func runForEachRepository(t *testing.T, test func(t *testing.T, repo domain.RouteRepository)) {
+	for name, newRepo := range repositories(t) {
+		newRepo := newRepo
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo())
+		})
+	}
+}
+
+func TestRouteRepository_CreateAndGet(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		route := newRoute("Zona Norte")
+		route.Purchases = []domain.Purchase{domain.NewPurchase(7, "Heladera", route.CreatedAt)}
+
+		id, err := repo.Create(route)
+		require.NoError(t, err)
+		assert.Greater(t, id, 0)
+
+		stored, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, id, stored.ID)
+		assert.Equal(t, "Zona Norte", stored.Name)
+		assert.Equal(t, domain.RouteStatusPending, stored.Status)
+		assert.True(t, route.CreatedAt.Equal(stored.CreatedAt))
+		require.Len(t, stored.Purchases, 1)
+		assert.Equal(t, "Heladera", stored.Purchases[0].Description)
+	})
+}
+
+func TestRouteRepository_CreateInvalidRoute(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		_, err := repo.Create(domain.Route{Vehicle: "ABC-123", Driver: "Julián"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRouteName)
+	})
+}
+
+func TestRouteRepository_GetByIDNotFound(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		_, err := repo.GetByID(99)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestRouteRepository_UpdateReplacesRouteAndPurchases(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		route := newRoute("Zona Norte")
+		route.Purchases = []domain.Purchase{
+			domain.NewPurchase(1, "Heladera", route.CreatedAt),
+			domain.NewPurchase(2, "Lavarropas", route.CreatedAt),
+		}
+		id, err := repo.Create(route)
+		require.NoError(t, err)
+
+		route, err = repo.GetByID(id)
+		require.NoError(t, err)
+
+		later := route.CreatedAt.Add(time.Hour)
+		require.NoError(t, route.Purchases[0].TransitionTo(domain.PurchaseStatusAssigned, later))
+		route.Purchases = route.Purchases[:1]
+		require.NoError(t, route.Cancel("Vehículo averiado", later))
+
+		require.NoError(t, repo.Update(id, route))
+
+		stored, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RouteStatusCancelled, stored.Status)
+		assert.Equal(t, "Vehículo averiado", stored.CancellationReason)
+		require.NotNil(t, stored.CancelledAt)
+		assert.True(t, later.Equal(*stored.CancelledAt))
+
+		require.Len(t, stored.Purchases, 1)
+		purchase := stored.Purchases[0]
+		assert.Equal(t, domain.PurchaseStatusAssigned, purchase.Status)
+		require.Len(t, purchase.History, 1)
+		assert.Equal(t, domain.PurchaseStatusCreated, purchase.History[0].From)
+		assert.True(t, later.Equal(purchase.History[0].At))
+	})
+}
+
+func TestRouteRepository_UpdateErrors(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		assert.ErrorIs(t, repo.Update(99, newRoute("Zona Norte")), domain.ErrNotFound)
+
+		id, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
+
+		invalid := newRoute("Zona Norte")
+		invalid.Driver = ""
+		assert.ErrorIs(t, repo.Update(id, invalid), domain.ErrInvalidDriver)
+	})
+}
 
-	db := persistence.NewDatabase()
+func TestRouteRepository_UpdateRejectsStaleVersion(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		id, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
 
-	routeRepo := persistence.NewRouteRepository(db)
+		first, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, first.Version)
+		second := first
 
-	id, err := routeRepo.CreateRoute("Route 1")
+		first.Name = "Zona Norte - Turno Mañana"
+		require.NoError(t, repo.Update(id, first))
 
-	assert.Nil(t, err)
+		second.Name = "Zona Norte - Turno Tarde"
+		err = repo.Update(id, second)
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.True(t, domain.IsConflictError(err))
 
-	assert.Greater(t, id, 0)
+		stored, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, "Zona Norte - Turno Mañana", stored.Name)
+		assert.Equal(t, 2, stored.Version)
 
-	routes, err := routeRepo.GetAllRoutes()
-	assert.Nil(t, err)
-	assert.Contains(t, routes, "Route 1")
+		require.NoError(t, repo.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", stored.CreatedAt)))
+		assert.ErrorIs(t, repo.Update(id, stored), domain.ErrVersionConflict)
+	})
 }
 
-func TestRouteRepository_CreateRoute_EmptyName(t *testing.T) {
+func TestRouteRepository_Delete(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		route := newRoute("Zona Norte")
+		route.Purchases = []domain.Purchase{domain.NewPurchase(1, "Heladera", route.CreatedAt)}
+		id, err := repo.Create(route)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Delete(id))
+
+		_, err = repo.GetByID(id)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.Delete(id), domain.ErrNotFound)
+	})
+}
+
+func TestRouteRepository_ListAndFindByStatus(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		pendingID, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
+
+		started := newRoute("Zona Sur")
+		started.Status = domain.RouteStatusInProgress
+		started.Purchases = []domain.Purchase{domain.NewPurchase(3, "Televisor", started.CreatedAt)}
+		startedID, err := repo.Create(started)
+		require.NoError(t, err)
+
+		routes, err := repo.List()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{pendingID, startedID}, routeIDs(routes))
+
+		inProgress, err := repo.FindByStatus(domain.RouteStatusInProgress)
+		require.NoError(t, err)
+		require.Len(t, inProgress, 1)
+		assert.Equal(t, startedID, inProgress[0].ID)
+		assert.Len(t, inProgress[0].Purchases, 1)
+
+		completed, err := repo.FindByStatus(domain.RouteStatusCompleted)
+		require.NoError(t, err)
+		assert.Empty(t, completed)
+	})
+}
+
+func TestRouteRepository_AssignPurchaseToRoute(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		id, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
+
+		at := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, repo.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", at)))
+		require.NoError(t, repo.AssignPurchaseToRoute(id, domain.NewPurchase(2, "Lavarropas", at)))
+
+		err = repo.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", at))
+		assert.True(t, domain.IsAlreadyExistsError(err))
+		assert.True(t, errors.Is(err, domain.ErrPurchaseAlreadyExists))
+
+		err = repo.AssignPurchaseToRoute(99, domain.NewPurchase(3, "Televisor", at))
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		stored, err := repo.GetByID(id)
+		require.NoError(t, err)
+		require.Len(t, stored.Purchases, 2)
+		assert.Equal(t, 1, stored.Purchases[0].ID)
+		assert.Equal(t, 2, stored.Purchases[1].ID)
+	})
+}
+
+func routeIDs(routes []domain.Route) []int {
+	ids := make([]int, 0, len(routes))
+	for _, route := range routes {
+		ids = append(ids, route.ID)
+	}
+	return ids
+}
+
+func TestRouteRepository_WithinTx(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		transactor, ok := repo.(domain.RouteTransactor)
+		require.True(t, ok, "the repository should support transactions")
+
+		id, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
+		at := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+		rollback := errors.New("rollback")
+		err = transactor.WithinTx(func(tx domain.RouteRepository) error {
+			require.NoError(t, tx.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", at)))
+			_, err := tx.Create(newRoute("Zona Sur"))
+			require.NoError(t, err)
+
+			// Los cambios son visibles dentro de la transacción
+			route, err := tx.GetByID(id)
+			require.NoError(t, err)
+			require.Len(t, route.Purchases, 1)
+			return rollback
+		})
+		assert.ErrorIs(t, err, rollback)
+
+		routes, err := repo.List()
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		assert.Empty(t, routes[0].Purchases)
+
+		err = transactor.WithinTx(func(tx domain.RouteRepository) error {
+			if err := tx.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", at)); err != nil {
+				return err
+			}
+			route, err := tx.GetByID(id)
+			if err != nil {
+				return err
+			}
+			route.Status = domain.RouteStatusInProgress
+			return tx.Update(id, route)
+		})
+		require.NoError(t, err)
+
+		stored, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RouteStatusInProgress, stored.Status)
+		assert.Len(t, stored.Purchases, 1)
+	})
+}
+
+func TestRouteRepository_OutboxCommitsWithTransaction(t *testing.T) {
+	runForEachRepository(t, func(t *testing.T, repo domain.RouteRepository) {
+		outbox, ok := repo.(domain.OutboxStore)
+		require.True(t, ok, "the repository should store the outbox")
+		transactor := repo.(domain.RouteTransactor)
+
+		id, err := repo.Create(newRoute("Zona Norte"))
+		require.NoError(t, err)
+		payload := []byte(`{"tipo":"COMPRA_EN_RUTA","id_compra":1}`)
+
+		rollback := errors.New("rollback")
+		err = transactor.WithinTx(func(tx domain.RouteRepository) error {
+			_, err := tx.(domain.OutboxStore).AddOutboxMessage(payload)
+			require.NoError(t, err)
+			return rollback
+		})
+		assert.ErrorIs(t, err, rollback)
+
+		messages, err := outbox.ListOutboxMessages("")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+
+		err = transactor.WithinTx(func(tx domain.RouteRepository) error {
+			if err := tx.AssignPurchaseToRoute(id, domain.NewPurchase(1, "Heladera", time.Now())); err != nil {
+				return err
+			}
+			_, err := tx.(domain.OutboxStore).AddOutboxMessage(payload)
+			return err
+		})
+		require.NoError(t, err)
+
+		pending, err := outbox.PendingOutboxMessages(time.Now().Add(time.Second), 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.JSONEq(t, string(payload), string(pending[0].Payload))
 
-	db := persistence.NewDatabase()
+		message := pending[0]
+		message.Status = domain.OutboxStatusSent
+		message.Attempts = 1
+		message.Channels = []domain.OutboxChannel{{Channel: "email"}, {Channel: "sms", Error: "invalid number", Permanent: true}}
+		require.NoError(t, outbox.UpdateOutboxMessage(message))
 
-	routeRepo := persistence.NewRouteRepository(db)
+		pending, err = outbox.PendingOutboxMessages(time.Now().Add(time.Second), 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
 
-	_, err := routeRepo.CreateRoute("")
+		sent, err := outbox.ListOutboxMessages(domain.OutboxStatusSent)
+		require.NoError(t, err)
+		require.Len(t, sent, 1)
+		assert.Equal(t, 1, sent[0].Attempts)
+		assert.Equal(t, message.Channels, sent[0].Channels)
 
-	assert.NotNil(t, err)
-	assert.Equal(t, "route name is empty", err.Error())
+		assert.Error(t, outbox.UpdateOutboxMessage(domain.OutboxMessage{ID: 99}))
+	})
 }
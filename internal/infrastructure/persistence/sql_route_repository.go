@@ -0,0 +1,488 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"transport-challenge/internal/domain"
+)
+
+// SQLRouteRepository guarda las rutas con database/sql. Las consultas usan
+// placeholders "?", válidos tanto en MySQL como en SQLite.
+type SQLRouteRepository struct {
+	db *sql.DB
+	tx *sql.Tx // definida en los repositorios que entrega WithinTx
+}
+
+func NewSQLRouteRepository(db *sql.DB) *SQLRouteRepository {
+	return &SQLRouteRepository{db: db}
+}
+
+// querier agrupa los métodos comunes de *sql.DB y *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+const selectRoutes = `SELECT id, version, name, vehicle, driver, status, cancellation_reason, cancelled_at, created_at, updated_at FROM routes`
+
+const selectOutbox = `SELECT id, payload, status, attempts, last_error, channels, next_attempt_at, created_at, updated_at FROM outbox`
+
+func (r *SQLRouteRepository) Create(route domain.Route) (int, error) {
+	// Validar la ruta
+	if err := route.Validate(); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err := r.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			`INSERT INTO routes (version, name, vehicle, driver, status, cancellation_reason, cancelled_at, created_at, updated_at) VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			route.Name, route.Vehicle, route.Driver, route.Status, route.CancellationReason,
+			nullTime(route.CancelledAt), utc(route.CreatedAt), utc(route.UpdatedAt),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert route: %w", err)
+		}
+
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to read route ID: %w", err)
+		}
+		id = int(lastID)
+
+		return insertPurchases(tx, id, route.Purchases, 0)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *SQLRouteRepository) GetByID(id int) (domain.Route, error) {
+	routes, err := loadRoutes(r.querier(), "WHERE id = ?", id)
+	if err != nil {
+		return domain.Route{}, err
+	}
+
+	if len(routes) == 0 {
+		return domain.Route{}, domain.ErrNotFound
+	}
+
+	return routes[0], nil
+}
+
+// Update reemplaza los datos de la ruta y el conjunto completo de sus compras. La
+// condición sobre version hace que el UPDATE solo afecte a la fila si nadie la
+// modificó desde que se leyó route.
+func (r *SQLRouteRepository) Update(id int, route domain.Route) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := ensureRouteExists(tx, id); err != nil {
+			return err
+		}
+
+		if err := route.Validate(); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			`UPDATE routes SET version = version + 1, name = ?, vehicle = ?, driver = ?, status = ?, cancellation_reason = ?, cancelled_at = ?, created_at = ?, updated_at = ? WHERE id = ? AND version = ?`,
+			route.Name, route.Vehicle, route.Driver, route.Status, route.CancellationReason,
+			nullTime(route.CancelledAt), utc(route.CreatedAt), utc(route.UpdatedAt), id, route.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update route: %w", err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to read updated rows: %w", err)
+		}
+		if updated == 0 {
+			var current int
+			if err := tx.QueryRow(`SELECT version FROM routes WHERE id = ?`, id).Scan(&current); err != nil {
+				return fmt.Errorf("failed to read route version: %w", err)
+			}
+			return domain.NewVersionConflictError(id, route.Version, current)
+		}
+
+		if err := deletePurchases(tx, id); err != nil {
+			return err
+		}
+
+		return insertPurchases(tx, id, route.Purchases, 0)
+	})
+}
+
+func (r *SQLRouteRepository) Delete(id int) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := ensureRouteExists(tx, id); err != nil {
+			return err
+		}
+
+		if err := deletePurchases(tx, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM routes WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete route: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *SQLRouteRepository) List() ([]domain.Route, error) {
+	return loadRoutes(r.querier(), "")
+}
+
+func (r *SQLRouteRepository) FindByStatus(status domain.RouteStatus) ([]domain.Route, error) {
+	return loadRoutes(r.querier(), "WHERE status = ?", status)
+}
+
+func (r *SQLRouteRepository) AssignPurchaseToRoute(routeID int, purchase domain.Purchase) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		err := ensureRouteExists(tx, routeID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("route with ID %d not found: %w", routeID, domain.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		var exists int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM purchases WHERE route_id = ? AND id = ?`, routeID, purchase.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check purchase: %w", err)
+		}
+		if exists > 0 {
+			return domain.NewDomainError(
+				domain.ErrorCodes.AlreadyExists,
+				fmt.Sprintf("purchase with ID %d already exists in route", purchase.ID),
+				domain.ErrPurchaseAlreadyExists,
+			)
+		}
+
+		// La compra se agrega al final, conservando el orden de asignación
+		var position int
+		err = tx.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM purchases WHERE route_id = ?`, routeID).Scan(&position)
+		if err != nil {
+			return fmt.Errorf("failed to read purchase position: %w", err)
+		}
+
+		if err := insertPurchases(tx, routeID, []domain.Purchase{purchase}, position); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE routes SET version = version + 1 WHERE id = ?`, routeID); err != nil {
+			return fmt.Errorf("failed to update route version: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// AddOutboxMessage registra un mensaje pendiente en la tabla outbox. Dentro de WithinTx
+// la fila se confirma en la misma transacción que los cambios de las rutas.
+func (r *SQLRouteRepository) AddOutboxMessage(payload []byte) (domain.OutboxMessage, error) {
+	message := newOutboxMessage(0, payload, time.Now().UTC())
+
+	result, err := r.querier().Exec(
+		`INSERT INTO outbox (payload, status, attempts, last_error, channels, next_attempt_at, created_at, updated_at) VALUES (?, ?, 0, '', 'null', ?, ?, ?)`,
+		string(message.Payload), message.Status, message.NextAttemptAt, message.CreatedAt, message.UpdatedAt,
+	)
+	if err != nil {
+		return domain.OutboxMessage{}, fmt.Errorf("failed to insert outbox message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return domain.OutboxMessage{}, fmt.Errorf("failed to read outbox message ID: %w", err)
+	}
+	message.ID = int(id)
+
+	return message, nil
+}
+
+func (r *SQLRouteRepository) PendingOutboxMessages(now time.Time, limit int) ([]domain.OutboxMessage, error) {
+	filter := "WHERE status = ? AND next_attempt_at <= ? ORDER BY id"
+	args := []interface{}{domain.OutboxStatusPending, utc(now)}
+	if limit > 0 {
+		filter += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	return loadOutbox(r.querier(), filter, args...)
+}
+
+func (r *SQLRouteRepository) UpdateOutboxMessage(message domain.OutboxMessage) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM outbox WHERE id = ?`, message.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check outbox message: %w", err)
+		}
+		if exists == 0 {
+			return errOutboxMessageNotFound(message.ID)
+		}
+
+		channels, err := json.Marshal(message.Channels)
+		if err != nil {
+			return fmt.Errorf("failed to encode outbox channels: %w", err)
+		}
+
+		_, err = tx.Exec(
+			`UPDATE outbox SET status = ?, attempts = ?, last_error = ?, channels = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?`,
+			message.Status, message.Attempts, message.LastError, string(channels),
+			utc(message.NextAttemptAt), time.Now().UTC(), message.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update outbox message: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *SQLRouteRepository) ListOutboxMessages(status domain.OutboxStatus) ([]domain.OutboxMessage, error) {
+	if status == "" {
+		return loadOutbox(r.querier(), "ORDER BY id")
+	}
+	return loadOutbox(r.querier(), "WHERE status = ? ORDER BY id", status)
+}
+
+// WithinTx ejecuta fn con un repositorio ligado a una transacción, que se confirma
+// si fn no devuelve error. Un WithinTx anidado participa de la transacción externa.
+func (r *SQLRouteRepository) WithinTx(fn func(repo domain.RouteRepository) error) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		return fn(&SQLRouteRepository{db: r.db, tx: tx})
+	})
+}
+
+// inTx ejecuta fn en una transacción, confirmándola solo si no devuelve error.
+// Si el repositorio ya está ligado a una transacción, fn corre dentro de ella.
+func (r *SQLRouteRepository) inTx(fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLRouteRepository) querier() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+func ensureRouteExists(q querier, id int) error {
+	var exists int
+	if err := q.QueryRow(`SELECT COUNT(*) FROM routes WHERE id = ?`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check route: %w", err)
+	}
+	if exists == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func insertPurchases(q querier, routeID int, purchases []domain.Purchase, firstPosition int) error {
+	for i, purchase := range purchases {
+		_, err := q.Exec(
+			`INSERT INTO purchases (route_id, id, position, description, recipient, recipient_locale, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			routeID, purchase.ID, firstPosition+i, purchase.Description, purchase.Recipient, purchase.RecipientLocale,
+			purchase.Status, utc(purchase.CreatedAt), utc(purchase.UpdatedAt),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert purchase %d: %w", purchase.ID, err)
+		}
+
+		for position, change := range purchase.History {
+			_, err := q.Exec(
+				`INSERT INTO purchase_status_changes (route_id, purchase_id, position, from_status, to_status, changed_at) VALUES (?, ?, ?, ?, ?, ?)`,
+				routeID, purchase.ID, position, change.From, change.To, utc(change.At),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert status history of purchase %d: %w", purchase.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deletePurchases borra las compras de la ruta y su historial sin depender
+// de que el motor tenga habilitado ON DELETE CASCADE
+func deletePurchases(q querier, routeID int) error {
+	if _, err := q.Exec(`DELETE FROM purchase_status_changes WHERE route_id = ?`, routeID); err != nil {
+		return fmt.Errorf("failed to delete purchase history: %w", err)
+	}
+	if _, err := q.Exec(`DELETE FROM purchases WHERE route_id = ?`, routeID); err != nil {
+		return fmt.Errorf("failed to delete purchases: %w", err)
+	}
+	return nil
+}
+
+// loadRoutes recupera las rutas que cumplen el filtro junto con sus compras,
+// ordenadas por ID. El filtro se aplica sobre la tabla routes.
+func loadRoutes(q querier, filter string, args ...interface{}) ([]domain.Route, error) {
+	rows, err := q.Query(selectRoutes+" "+filter+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []domain.Route
+	for rows.Next() {
+		var route domain.Route
+		var cancelledAt sql.NullTime
+		if err := rows.Scan(&route.ID, &route.Version, &route.Name, &route.Vehicle, &route.Driver, &route.Status,
+			&route.CancellationReason, &cancelledAt, &route.CreatedAt, &route.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read route: %w", err)
+		}
+		if cancelledAt.Valid {
+			route.CancelledAt = &cancelledAt.Time
+		}
+		routes = append(routes, route)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+
+	if len(routes) == 0 {
+		return routes, nil
+	}
+
+	purchases, err := loadPurchases(q, filter, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range routes {
+		routes[i].Purchases = purchases[routes[i].ID]
+	}
+
+	return routes, nil
+}
+
+// loadPurchases devuelve las compras de las rutas filtradas agrupadas por ruta
+func loadPurchases(q querier, filter string, args ...interface{}) (map[int][]domain.Purchase, error) {
+	routeIDs := "SELECT id FROM routes " + filter
+
+	rows, err := q.Query(
+		`SELECT route_id, id, description, recipient, recipient_locale, status, created_at, updated_at FROM purchases WHERE route_id IN (`+routeIDs+`) ORDER BY route_id, position`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchases: %w", err)
+	}
+	defer rows.Close()
+
+	purchases := make(map[int][]domain.Purchase)
+	for rows.Next() {
+		var routeID int
+		var purchase domain.Purchase
+		if err := rows.Scan(&routeID, &purchase.ID, &purchase.Description, &purchase.Recipient,
+			&purchase.RecipientLocale, &purchase.Status, &purchase.CreatedAt, &purchase.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read purchase: %w", err)
+		}
+		purchases[routeID] = append(purchases[routeID], purchase)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read purchases: %w", err)
+	}
+
+	history, err := q.Query(
+		`SELECT route_id, purchase_id, from_status, to_status, changed_at FROM purchase_status_changes WHERE route_id IN (`+routeIDs+`) ORDER BY route_id, purchase_id, position`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase history: %w", err)
+	}
+	defer history.Close()
+
+	for history.Next() {
+		var routeID, purchaseID int
+		var change domain.PurchaseStatusChange
+		if err := history.Scan(&routeID, &purchaseID, &change.From, &change.To, &change.At); err != nil {
+			return nil, fmt.Errorf("failed to read purchase history: %w", err)
+		}
+		for i := range purchases[routeID] {
+			if purchases[routeID][i].ID == purchaseID {
+				purchases[routeID][i].History = append(purchases[routeID][i].History, change)
+				break
+			}
+		}
+	}
+	if err := history.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read purchase history: %w", err)
+	}
+
+	return purchases, nil
+}
+
+// loadOutbox recupera los mensajes de la bandeja de salida que cumplen el filtro
+func loadOutbox(q querier, filter string, args ...interface{}) ([]domain.OutboxMessage, error) {
+	rows, err := q.Query(selectOutbox+" "+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []domain.OutboxMessage{}
+	for rows.Next() {
+		var message domain.OutboxMessage
+		var payload, channels string
+		if err := rows.Scan(&message.ID, &payload, &message.Status, &message.Attempts, &message.LastError, &channels,
+			&message.NextAttemptAt, &message.CreatedAt, &message.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read outbox message: %w", err)
+		}
+		message.Payload = []byte(payload)
+		if err := json.Unmarshal([]byte(channels), &message.Channels); err != nil {
+			return nil, fmt.Errorf("failed to decode channels of outbox message %d: %w", message.ID, err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	return messages, nil
+}
+
+// utc normaliza las fechas, ya que MySQL guarda DATETIME sin zona horaria
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+var (
+	_ domain.RouteRepository = &SQLRouteRepository{}
+	_ domain.RouteTransactor = &SQLRouteRepository{}
+	_ domain.OutboxStore     = &SQLRouteRepository{}
+)
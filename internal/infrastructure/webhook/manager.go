@@ -0,0 +1,346 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"transport-challenge/internal/domain"
+)
+
+// Encabezados enviados en cada entrega
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxDrainedResponseBytes limita lo que se lee de la respuesta de un endpoint para reutilizar
+// la conexión; con una respuesta más larga la conexión simplemente se cierra
+const maxDrainedResponseBytes = 64 << 10
+
+// Config define los reintentos y la desactivación de suscripciones
+type Config struct {
+	DefaultMaxAttempts int
+	RetryDelay         time.Duration
+	// DisableAfter desactiva la suscripción tras esa cantidad de eventos consecutivos sin entregar
+	DisableAfter int
+	Timeout      time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DefaultMaxAttempts: 3,
+		RetryDelay:         2 * time.Second,
+		DisableAfter:       5,
+		Timeout:            10 * time.Second,
+	}
+}
+
+// Envelope es el cuerpo JSON enviado a cada suscripción. Sequence crece con cada evento en
+// el orden en que se recibió; como las entregas son concurrentes y se reintentan, pueden
+// llegar desordenadas y el receptor las ordena por Sequence.
+type Envelope struct {
+	ID         string       `json:"id"`
+	Sequence   uint64       `json:"sequence"`
+	Event      string       `json:"event"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Data       domain.Event `json:"data"`
+}
+
+// Manager administra las suscripciones y entrega los eventos de dominio a los endpoints registrados
+type Manager struct {
+	store  Store
+	config Config
+	client *http.Client
+	sleep  func(time.Duration)
+	now    func() time.Time
+
+	mu       sync.Mutex
+	sequence uint64
+	pending  sync.WaitGroup
+}
+
+func NewManager(store Store, config Config) *Manager {
+	return &Manager{
+		store:  store,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		sleep:  time.Sleep,
+		now:    time.Now,
+	}
+}
+
+// Register valida y activa una nueva suscripción; si no se informa un secreto se genera uno
+func (m *Manager) Register(subscription Subscription) (Subscription, error) {
+	if err := subscription.Validate(); err != nil {
+		return Subscription{}, err
+	}
+
+	if subscription.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return Subscription{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		subscription.Secret = secret
+	}
+
+	if subscription.MaxAttempts <= 0 {
+		subscription.MaxAttempts = m.config.DefaultMaxAttempts
+	}
+
+	subscription.Active = true
+	subscription.ConsecutiveFailures = 0
+	subscription.CreatedAt = m.now()
+	subscription.DisabledAt = nil
+
+	return m.store.CreateSubscription(subscription)
+}
+
+func (m *Manager) Get(id int) (Subscription, error) {
+	return m.store.GetSubscription(id)
+}
+
+func (m *Manager) List() ([]Subscription, error) {
+	return m.store.ListSubscriptions()
+}
+
+func (m *Manager) Delete(id int) error {
+	return m.store.DeleteSubscription(id)
+}
+
+// Enable reactiva una suscripción desactivada por fallos
+func (m *Manager) Enable(id int) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscription, err := m.store.GetSubscription(id)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	subscription.Active = true
+	subscription.ConsecutiveFailures = 0
+	subscription.DisabledAt = nil
+
+	if err := m.store.UpdateSubscription(subscription); err != nil {
+		return Subscription{}, err
+	}
+
+	return subscription, nil
+}
+
+// Deliveries devuelve el registro de entregas de una suscripción
+func (m *Manager) Deliveries(id int) ([]Delivery, error) {
+	return m.store.ListDeliveries(id)
+}
+
+// Handle entrega el evento a cada suscripción activa que lo acepta y espera a que terminen
+// las entregas, incluidos los reintentos
+func (m *Manager) Handle(event domain.Event) {
+	envelope, body, ok := m.envelope(event)
+	if ok {
+		m.deliverAll(envelope, body)
+	}
+}
+
+// Enqueue numera el evento en el orden de llamada y lo entrega en segundo plano, de modo que
+// los reintentos no demoren a quien publica. Está pensado para suscribirse al bus de eventos
+// en modo sincrónico, para que Sequence siga el orden de publicación; Wait espera las entregas.
+func (m *Manager) Enqueue(event domain.Event) {
+	envelope, body, ok := m.envelope(event)
+	if !ok {
+		return
+	}
+
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		m.deliverAll(envelope, body)
+	}()
+}
+
+// Wait bloquea hasta que terminen las entregas iniciadas con Enqueue
+func (m *Manager) Wait() {
+	m.pending.Wait()
+}
+
+// envelope arma y serializa el sobre del evento con el próximo número de secuencia
+func (m *Manager) envelope(event domain.Event) (Envelope, []byte, bool) {
+	id, sequence := m.nextEvent()
+	envelope := Envelope{
+		ID:         id,
+		Sequence:   sequence,
+		Event:      event.EventName(),
+		OccurredAt: event.OccurredAt(),
+		Data:       event,
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("failed to encode webhook event %s: %v", event.EventName(), err)
+		return Envelope{}, nil, false
+	}
+
+	return envelope, body, true
+}
+
+// deliverAll entrega el sobre a cada suscripción activa que acepta el evento
+func (m *Manager) deliverAll(envelope Envelope, body []byte) {
+	subscriptions, err := m.store.ListSubscriptions()
+	if err != nil {
+		log.Printf("failed to list webhook subscriptions: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		if !subscription.Active || !subscription.Matches(envelope.Event) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(subscription Subscription) {
+			defer wg.Done()
+			m.deliver(subscription, envelope, body)
+		}(subscription)
+	}
+	wg.Wait()
+}
+
+// deliver envía el evento con reintentos y actualiza el conteo de fallos de la suscripción
+func (m *Manager) deliver(subscription Subscription, envelope Envelope, body []byte) {
+	delay := m.config.RetryDelay
+
+	for attempt := 1; attempt <= subscription.MaxAttempts; attempt++ {
+		delivery := m.send(subscription, envelope, body)
+		delivery.Attempt = attempt
+
+		if err := m.store.AddDelivery(delivery); err != nil {
+			// La suscripción fue eliminada mientras se entregaba el evento
+			return
+		}
+
+		if delivery.Success {
+			m.recordResult(subscription.ID, true)
+			return
+		}
+
+		if attempt < subscription.MaxAttempts {
+			m.sleep(delay)
+			delay *= 2
+		}
+	}
+
+	m.recordResult(subscription.ID, false)
+}
+
+// send realiza un único intento de entrega firmado con HMAC-SHA256
+func (m *Manager) send(subscription Subscription, envelope Envelope, body []byte) Delivery {
+	start := m.now()
+	delivery := Delivery{
+		SubscriptionID: subscription.ID,
+		EventID:        envelope.ID,
+		Event:          envelope.Event,
+		DeliveredAt:    start,
+	}
+
+	req, err := http.NewRequest("POST", subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, envelope.ID)
+	req.Header.Set(HeaderEvent, envelope.Event)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	resp, err := m.client.Do(req)
+	delivery.Duration = m.now().Sub(start).String()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+
+	// Se descarta el cuerpo de la respuesta para que el cliente reutilice la conexión
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedResponseBytes))
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode <= 299
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return delivery
+}
+
+// recordResult actualiza los fallos consecutivos y desactiva la suscripción al superar el límite
+func (m *Manager) recordResult(subscriptionID int, success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscription, err := m.store.GetSubscription(subscriptionID)
+	if err != nil {
+		return
+	}
+
+	if success {
+		subscription.ConsecutiveFailures = 0
+	} else {
+		subscription.ConsecutiveFailures++
+		if m.config.DisableAfter > 0 && subscription.ConsecutiveFailures >= m.config.DisableAfter && subscription.Active {
+			disabledAt := m.now()
+			subscription.Active = false
+			subscription.DisabledAt = &disabledAt
+			log.Printf("webhook subscription %d disabled after %d failed events", subscription.ID, subscription.ConsecutiveFailures)
+		}
+	}
+
+	if err := m.store.UpdateSubscription(subscription); err != nil {
+		log.Printf("failed to update webhook subscription %d: %v", subscription.ID, err)
+	}
+}
+
+func (m *Manager) nextEvent() (string, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sequence++
+	return fmt.Sprintf("evt_%d_%d", m.now().UnixNano(), m.sequence), m.sequence
+}
+
+// Sign calcula la firma enviada en X-Webhook-Signature: HMAC-SHA256 de "<timestamp>.<cuerpo>"
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify comprueba la firma recibida por un endpoint suscripto
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
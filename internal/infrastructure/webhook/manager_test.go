@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"transport-challenge/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestManager(config Config) (*Manager, *[]time.Duration) {
+	manager := NewManager(NewInMemoryStore(), config)
+	var sleeps []time.Duration
+	manager.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return manager, &sleeps
+}
+
+func TestHandleDeliversSignedEvent(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	manager, _ := newTestManager(DefaultConfig())
+	subscription, err := manager.Register(Subscription{URL: server.URL, Events: []string{"purchase.*"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, subscription.Secret)
+	assert.True(t, subscription.Active)
+
+	manager.Handle(domain.RouteCreated{Route: domain.Route{ID: 1}, At: time.Now()})
+	manager.Handle(domain.PurchaseDelivered{RouteID: 1, Purchase: domain.Purchase{ID: 7}, At: time.Now()})
+
+	assert.Len(t, received, 1)
+	req := received[0]
+	assert.Equal(t, domain.EventPurchaseDelivered, req.Header.Get(HeaderEvent))
+	assert.True(t, Verify(subscription.Secret, req.Header.Get(HeaderTimestamp), bodies[0], req.Header.Get(HeaderSignature)))
+	assert.False(t, Verify("otro-secreto", req.Header.Get(HeaderTimestamp), bodies[0], req.Header.Get(HeaderSignature)))
+
+	var envelope struct {
+		ID    string                   `json:"id"`
+		Event string                   `json:"event"`
+		Data  domain.PurchaseDelivered `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(bodies[0], &envelope))
+	assert.Equal(t, req.Header.Get(HeaderID), envelope.ID)
+	assert.Equal(t, 7, envelope.Data.Purchase.ID)
+
+	deliveries, err := manager.Deliveries(subscription.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusAccepted, deliveries[0].StatusCode)
+}
+
+func TestHandleRetriesFailedDeliveries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.RetryDelay = time.Second
+	manager, sleeps := newTestManager(config)
+	subscription, err := manager.Register(Subscription{URL: server.URL})
+	assert.NoError(t, err)
+
+	manager.Handle(domain.RouteCreated{At: time.Now()})
+
+	deliveries, err := manager.Deliveries(subscription.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 3)
+	assert.Equal(t, []int{1, 2, 3}, []int{deliveries[0].Attempt, deliveries[1].Attempt, deliveries[2].Attempt})
+	assert.False(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+	assert.True(t, deliveries[2].Success)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *sleeps)
+
+	subscription, _ = manager.Get(subscription.ID)
+	assert.Equal(t, 0, subscription.ConsecutiveFailures)
+}
+
+func TestSubscriptionDisabledAfterConsecutiveFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.DisableAfter = 2
+	manager, _ := newTestManager(config)
+	subscription, err := manager.Register(Subscription{URL: server.URL, MaxAttempts: 1})
+	assert.NoError(t, err)
+
+	manager.Handle(domain.RouteCreated{At: time.Now()})
+	subscription, _ = manager.Get(subscription.ID)
+	assert.True(t, subscription.Active)
+	assert.Equal(t, 1, subscription.ConsecutiveFailures)
+
+	manager.Handle(domain.RouteCreated{At: time.Now()})
+	subscription, _ = manager.Get(subscription.ID)
+	assert.False(t, subscription.Active)
+	assert.NotNil(t, subscription.DisabledAt)
+
+	// Una suscripción desactivada no recibe más eventos
+	manager.Handle(domain.RouteCreated{At: time.Now()})
+	deliveries, _ := manager.Deliveries(subscription.ID)
+	assert.Len(t, deliveries, 2)
+
+	subscription, err = manager.Enable(subscription.ID)
+	assert.NoError(t, err)
+	assert.True(t, subscription.Active)
+	assert.Equal(t, 0, subscription.ConsecutiveFailures)
+	assert.Nil(t, subscription.DisabledAt)
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription Subscription
+		valid        bool
+	}{
+		{"URL válida sin filtros", Subscription{URL: "https://ejemplo.com/hook"}, true},
+		{"Filtro comodín", Subscription{URL: "https://ejemplo.com/hook", Events: []string{"route.*"}}, true},
+		{"Filtro exacto", Subscription{URL: "http://ejemplo.com", Events: []string{domain.EventPurchaseAssigned}}, true},
+		{"URL relativa", Subscription{URL: "/hook"}, false},
+		{"Esquema inválido", Subscription{URL: "ftp://ejemplo.com"}, false},
+		{"Evento desconocido", Subscription{URL: "https://ejemplo.com", Events: []string{"driver.created"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.subscription.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSubscription)
+			}
+		})
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	subscription := Subscription{Events: []string{"route.*", domain.EventPurchaseDelivered}}
+
+	assert.True(t, subscription.Matches(domain.EventRouteCreated))
+	assert.True(t, subscription.Matches(domain.EventRouteStatusChanged))
+	assert.True(t, subscription.Matches(domain.EventPurchaseDelivered))
+	assert.False(t, subscription.Matches(domain.EventPurchaseAssigned))
+
+	assert.True(t, (&Subscription{}).Matches(domain.EventPurchaseAssigned))
+}
+
+func TestEnqueueNumbersEventsInPublishOrder(t *testing.T) {
+	var mu sync.Mutex
+	var sequences []uint64
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope struct {
+			Sequence uint64 `json:"sequence"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &envelope))
+		mu.Lock()
+		sequences = append(sequences, envelope.Sequence)
+		mu.Unlock()
+		w.Write(bytes.Repeat([]byte(" "), 32<<10))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	manager, _ := newTestManager(DefaultConfig())
+	_, err := manager.Register(Subscription{URL: server.URL})
+	assert.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		manager.Enqueue(domain.RouteCreated{Route: domain.Route{ID: i}, At: time.Now()})
+		manager.Wait()
+	}
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, sequences)
+	assert.Equal(t, 1, connections, "las entregas deben reutilizar la conexión")
+}
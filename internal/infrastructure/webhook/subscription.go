@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"transport-challenge/internal/domain"
+)
+
+// Errores de suscripciones
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+// Subscription representa un endpoint externo suscripto a eventos de dominio
+type Subscription struct {
+	ID                  int        `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"`
+	Events              []string   `json:"events"`
+	MaxAttempts         int        `json:"max_attempts"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Validate verifica la URL y los filtros de eventos de la suscripción
+func (s *Subscription) Validate() error {
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidSubscription)
+	}
+
+	for _, filter := range s.Events {
+		if !isKnownFilter(filter) {
+			return fmt.Errorf("%w: unknown event filter %q", ErrInvalidSubscription, filter)
+		}
+	}
+
+	return nil
+}
+
+// Matches indica si la suscripción debe recibir el evento. Sin filtros recibe todos;
+// un filtro "route.*" recibe todos los eventos de rutas.
+func (s *Subscription) Matches(eventName string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, filter := range s.Events {
+		if filter == "*" || filter == eventName {
+			return true
+		}
+		if strings.HasSuffix(filter, ".*") && strings.HasPrefix(eventName, strings.TrimSuffix(filter, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isKnownFilter(filter string) bool {
+	if filter == "*" {
+		return true
+	}
+
+	for _, name := range domain.EventNames() {
+		if filter == name {
+			return true
+		}
+		if strings.HasSuffix(filter, ".*") && strings.HasPrefix(name, strings.TrimSuffix(filter, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery registra un intento de entrega de un evento a una suscripción
+type Delivery struct {
+	ID             int       `json:"id"`
+	SubscriptionID int       `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	Event          string    `json:"event"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Success        bool      `json:"success"`
+	Duration       string    `json:"duration"`
+	DeliveredAt    time.Time `json:"delivered_at"`
+}
+
+// Store persiste suscripciones y el registro de entregas
+type Store interface {
+	CreateSubscription(subscription Subscription) (Subscription, error)
+	GetSubscription(id int) (Subscription, error)
+	UpdateSubscription(subscription Subscription) error
+	DeleteSubscription(id int) error
+	ListSubscriptions() ([]Subscription, error)
+
+	AddDelivery(delivery Delivery) error
+	ListDeliveries(subscriptionID int) ([]Delivery, error)
+}
+
+// InMemoryStore implementa Store en memoria
+type InMemoryStore struct {
+	mu             sync.RWMutex
+	subscriptions  map[int]Subscription
+	deliveries     map[int][]Delivery
+	nextID         int
+	nextDeliveryID int
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		subscriptions:  make(map[int]Subscription),
+		deliveries:     make(map[int][]Delivery),
+		nextID:         1,
+		nextDeliveryID: 1,
+	}
+}
+
+func (s *InMemoryStore) CreateSubscription(subscription Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription.ID = s.nextID
+	s.subscriptions[subscription.ID] = subscription
+	s.nextID++
+
+	return subscription, nil
+}
+
+func (s *InMemoryStore) GetSubscription(id int) (Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscription, exists := s.subscriptions[id]
+	if !exists {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+
+	return subscription, nil
+}
+
+func (s *InMemoryStore) UpdateSubscription(subscription Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.subscriptions[subscription.ID]; !exists {
+		return ErrSubscriptionNotFound
+	}
+
+	s.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (s *InMemoryStore) DeleteSubscription(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.subscriptions[id]; !exists {
+		return ErrSubscriptionNotFound
+	}
+
+	delete(s.subscriptions, id)
+	delete(s.deliveries, id)
+	return nil
+}
+
+func (s *InMemoryStore) ListSubscriptions() ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := make([]Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+
+	return subscriptions, nil
+}
+
+func (s *InMemoryStore) AddDelivery(delivery Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.subscriptions[delivery.SubscriptionID]; !exists {
+		return ErrSubscriptionNotFound
+	}
+
+	delivery.ID = s.nextDeliveryID
+	s.nextDeliveryID++
+	s.deliveries[delivery.SubscriptionID] = append(s.deliveries[delivery.SubscriptionID], delivery)
+
+	return nil
+}
+
+func (s *InMemoryStore) ListDeliveries(subscriptionID int) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.subscriptions[subscriptionID]; !exists {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	deliveries := make([]Delivery, len(s.deliveries[subscriptionID]))
+	copy(deliveries, s.deliveries[subscriptionID])
+
+	return deliveries, nil
+}
+
+var _ Store = &InMemoryStore{}
@@ -0,0 +1,222 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EstadoEntrada representa el estado de entrega de una notificación en la bandeja de salida
+type EstadoEntrada string
+
+const (
+	EntradaPendiente EstadoEntrada = "PENDIENTE"
+	EntradaEnviada   EstadoEntrada = "ENVIADA"
+	EntradaFallida   EstadoEntrada = "FALLIDA"
+)
+
+// EntradaBandeja es una notificación registrada en la bandeja de salida
+type EntradaBandeja struct {
+	ID             int           `json:"id"`
+	Notificacion   Notificacion  `json:"notificacion"`
+	Estado         EstadoEntrada `json:"estado"`
+	Intentos       int           `json:"intentos"`
+	UltimoError    string        `json:"ultimo_error,omitempty"`
+	Canales        []EnvioCanal  `json:"canales,omitempty"` // Último resultado de cada canal intentado
+	ProximoIntento time.Time     `json:"proximo_intento"`
+	CreadaEn       time.Time     `json:"creada_en"`
+	ActualizadaEn  time.Time     `json:"actualizada_en"`
+}
+
+// EnvioCanal guarda el resultado de un canal para una entrada de la bandeja. Solo los
+// canales con Error que no sea Definitivo se vuelven a intentar.
+type EnvioCanal struct {
+	Canal      string `json:"canal"`
+	Omitido    string `json:"omitido,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Definitivo bool   `json:"definitivo,omitempty"`
+}
+
+// canalesPendientes devuelve los canales que fallaron en el último intento con un error
+// reintentable, o nil si todavía no se registró el resultado de ningún canal
+func (e EntradaBandeja) canalesPendientes() []string {
+	if len(e.Canales) == 0 {
+		return nil
+	}
+
+	pendientes := []string{}
+	for _, envio := range e.Canales {
+		if envio.Error != "" && !envio.Definitivo {
+			pendientes = append(pendientes, envio.Canal)
+		}
+	}
+	return pendientes
+}
+
+// reintentable indica si vale la pena volver a intentar la entrada tras el error del envío.
+// Con resultados por canal se reintenta si algún canal falló por un error transitorio.
+func (e EntradaBandeja) reintentable(err error) bool {
+	var errNotificacion *ErrorNotificacion
+	if errors.As(err, &errNotificacion) && len(e.Canales) > 0 {
+		return len(e.canalesPendientes()) > 0
+	}
+	return esErrorTransitorio(err)
+}
+
+// esErrorTransitorio indica si el error puede resolverse reintentando más tarde: un error
+// reintentable del proveedor o el disyuntor del canal abierto. Un teléfono inválido, un
+// token rechazado o una configuración incompleta no se resuelven reintentando.
+func esErrorTransitorio(err error) bool {
+	return EsReintentable(err) || errors.Is(err, ErrCircuitoAbierto)
+}
+
+// registrarResultados actualiza el resultado de los canales intentados. Un canal pendiente
+// que ya no se intentó, por ejemplo porque se deshabilitó, deja de reintentarse.
+func (e *EntradaBandeja) registrarResultados(pendientes []string, resultados []ResultadoCanal) {
+	porCanal := make(map[string]EnvioCanal, len(resultados))
+	for _, resultado := range resultados {
+		envio := EnvioCanal{Canal: resultado.Canal, Omitido: resultado.Omitido}
+		if resultado.Error != nil {
+			envio.Error = resultado.Error.Error()
+			envio.Definitivo = !esErrorTransitorio(resultado.Error)
+		}
+		porCanal[resultado.Canal] = envio
+	}
+
+	for _, canal := range pendientes {
+		if _, intentado := porCanal[canal]; !intentado {
+			porCanal[canal] = EnvioCanal{Canal: canal, Omitido: "el canal ya no está habilitado"}
+		}
+	}
+
+	for i, envio := range e.Canales {
+		if actualizado, ok := porCanal[envio.Canal]; ok {
+			e.Canales[i] = actualizado
+			delete(porCanal, envio.Canal)
+		}
+	}
+	for _, resultado := range resultados {
+		if envio, ok := porCanal[resultado.Canal]; ok {
+			e.Canales = append(e.Canales, envio)
+		}
+	}
+}
+
+// AlmacenBandeja persiste las entradas de la bandeja de salida (outbox)
+type AlmacenBandeja interface {
+	// Agregar registra una notificación pendiente de envío
+	Agregar(notificacion Notificacion) (EntradaBandeja, error)
+
+	// Pendientes devuelve las entradas listas para reintentar en el momento indicado
+	Pendientes(ahora time.Time, limite int) ([]EntradaBandeja, error)
+
+	// Actualizar guarda el resultado de un intento de envío
+	Actualizar(entrada EntradaBandeja) error
+
+	// Listar devuelve las entradas en el estado indicado, o todas si el estado es vacío
+	Listar(estado EstadoEntrada) ([]EntradaBandeja, error)
+}
+
+// BandejaSalida es una bandeja de salida en memoria. Implementa Notificar para poder
+// inyectarse en lugar del servicio de notificaciones y desacoplar el envío, pero la
+// entrada se agrega después de confirmar el cambio y se pierde al reiniciar. Para no
+// perder notificaciones se usa la bandeja de los repositorios de persistence, que la
+// guardan en la misma transacción que el cambio.
+type BandejaSalida struct {
+	mu       sync.Mutex
+	entradas map[int]EntradaBandeja
+	nextID   int
+	ahora    func() time.Time
+}
+
+func NuevaBandejaSalida() *BandejaSalida {
+	return &BandejaSalida{
+		entradas: make(map[int]EntradaBandeja),
+		nextID:   1,
+		ahora:    time.Now,
+	}
+}
+
+// Notificar encola la notificación para su envío posterior
+func (b *BandejaSalida) Notificar(notificacion Notificacion) error {
+	_, err := b.Agregar(notificacion)
+	return err
+}
+
+func (b *BandejaSalida) Agregar(notificacion Notificacion) (EntradaBandeja, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ahora := b.ahora()
+	entrada := EntradaBandeja{
+		ID:             b.nextID,
+		Notificacion:   notificacion,
+		Estado:         EntradaPendiente,
+		ProximoIntento: ahora,
+		CreadaEn:       ahora,
+		ActualizadaEn:  ahora,
+	}
+	b.entradas[entrada.ID] = entrada
+	b.nextID++
+
+	return entrada, nil
+}
+
+func (b *BandejaSalida) Pendientes(ahora time.Time, limite int) ([]EntradaBandeja, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var pendientes []EntradaBandeja
+	for _, entrada := range b.entradas {
+		if entrada.Estado == EntradaPendiente && !entrada.ProximoIntento.After(ahora) {
+			pendientes = append(pendientes, entrada)
+		}
+	}
+
+	ordenarEntradas(pendientes)
+	if limite > 0 && len(pendientes) > limite {
+		pendientes = pendientes[:limite]
+	}
+
+	return pendientes, nil
+}
+
+func (b *BandejaSalida) Actualizar(entrada EntradaBandeja) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, existe := b.entradas[entrada.ID]; !existe {
+		return fmt.Errorf("entrada %d no encontrada en la bandeja de salida", entrada.ID)
+	}
+
+	entrada.ActualizadaEn = b.ahora()
+	b.entradas[entrada.ID] = entrada
+
+	return nil
+}
+
+func (b *BandejaSalida) Listar(estado EstadoEntrada) ([]EntradaBandeja, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entradas := make([]EntradaBandeja, 0, len(b.entradas))
+	for _, entrada := range b.entradas {
+		if estado == "" || entrada.Estado == estado {
+			entradas = append(entradas, entrada)
+		}
+	}
+
+	ordenarEntradas(entradas)
+
+	return entradas, nil
+}
+
+func ordenarEntradas(entradas []EntradaBandeja) {
+	sort.Slice(entradas, func(i, j int) bool {
+		return entradas[i].ID < entradas[j].ID
+	})
+}
+
+var _ AlmacenBandeja = &BandejaSalida{}
@@ -0,0 +1,230 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type notificadorSimulado struct {
+	enviadas []Notificacion
+	fallos   int
+}
+
+func (n *notificadorSimulado) Notificar(notificacion Notificacion) error {
+	if n.fallos > 0 {
+		n.fallos--
+		return &ErrorReintentable{Err: fmt.Errorf("error simulado de envío")}
+	}
+	n.enviadas = append(n.enviadas, notificacion)
+	return nil
+}
+
+func nuevaNotificacionPrueba(idCompra int) Notificacion {
+	return Notificacion{
+		Tipo:         NotificacionCompraEnRuta,
+		IDCompra:     idCompra,
+		Descripcion:  "Compra en ruta",
+		Destinatario: "cliente@ejemplo.com",
+	}
+}
+
+func TestBandejaSalidaEncolaNotificaciones(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+
+	if err := bandeja.Notificar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("No se esperaba un error al encolar: %v", err)
+	}
+	if err := bandeja.Notificar(nuevaNotificacionPrueba(2)); err != nil {
+		t.Fatalf("No se esperaba un error al encolar: %v", err)
+	}
+
+	pendientes, _ := bandeja.Listar(EntradaPendiente)
+	if len(pendientes) != 2 {
+		t.Fatalf("Se esperaban 2 entradas pendientes, obtenidas: %d", len(pendientes))
+	}
+	if pendientes[0].Notificacion.IDCompra != 1 {
+		t.Errorf("Se esperaba que las entradas se listaran en orden de creación")
+	}
+}
+
+func TestDespachadorEnviaPendientes(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+	notificador := &notificadorSimulado{}
+	despachador := NuevoDespachadorBandeja(bandeja, notificador, ConfigDespachadorPorDefecto())
+
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+	bandeja.Notificar(nuevaNotificacionPrueba(2))
+
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 2 {
+		t.Errorf("Se esperaban 2 notificaciones enviadas, obtenidas: %d", enviadas)
+	}
+
+	enviadas, _ := bandeja.Listar(EntradaEnviada)
+	if len(enviadas) != 2 {
+		t.Errorf("Se esperaban 2 entradas enviadas, obtenidas: %d", len(enviadas))
+	}
+
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 0 {
+		t.Errorf("No se esperaba reenviar entradas ya enviadas")
+	}
+}
+
+func TestDespachadorReintentaConEsperaExponencial(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+	notificador := &notificadorSimulado{fallos: 1}
+	config := ConfigDespachador{TamanoLote: 10, MaxIntentos: 3, RetardoBase: time.Minute}
+	despachador := NuevoDespachadorBandeja(bandeja, notificador, config)
+
+	ahora := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	bandeja.ahora = func() time.Time { return ahora }
+	despachador.ahora = func() time.Time { return ahora }
+
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+
+	despachador.ProcesarPendientes()
+
+	entradas, _ := bandeja.Listar(EntradaPendiente)
+	if len(entradas) != 1 || entradas[0].Intentos != 1 || entradas[0].UltimoError == "" {
+		t.Fatalf("Se esperaba una entrada pendiente con un intento fallido: %+v", entradas)
+	}
+	if !entradas[0].ProximoIntento.Equal(ahora.Add(time.Minute)) {
+		t.Errorf("Próximo intento incorrecto: %v", entradas[0].ProximoIntento)
+	}
+
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 0 {
+		t.Errorf("No se esperaba reintentar antes del próximo intento")
+	}
+
+	ahora = ahora.Add(time.Minute)
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 1 {
+		t.Errorf("Se esperaba enviar la notificación en el segundo intento")
+	}
+}
+
+func TestDespachadorMarcaFallidaTrasMaxIntentos(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+	notificador := &notificadorSimulado{fallos: 10}
+	config := ConfigDespachador{TamanoLote: 10, MaxIntentos: 2, RetardoBase: 0}
+	despachador := NuevoDespachadorBandeja(bandeja, notificador, config)
+
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+
+	despachador.ProcesarPendientes()
+	despachador.ProcesarPendientes()
+
+	fallidas, _ := bandeja.Listar(EntradaFallida)
+	if len(fallidas) != 1 {
+		t.Fatalf("Se esperaba una entrada fallida, obtenidas: %d", len(fallidas))
+	}
+	if fallidas[0].Intentos != 2 {
+		t.Errorf("Se esperaban 2 intentos, obtenidos: %d", fallidas[0].Intentos)
+	}
+
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 0 || len(notificador.enviadas) != 0 {
+		t.Errorf("No se esperaba reintentar entradas fallidas")
+	}
+}
+
+func TestDespachadorCompletaConfiguracionVacia(t *testing.T) {
+	despachador := NuevoDespachadorBandeja(NuevaBandejaSalida(), &notificadorSimulado{}, ConfigDespachador{})
+
+	porDefecto := ConfigDespachadorPorDefecto()
+	if despachador.config.Intervalo != porDefecto.Intervalo || despachador.config.MaxIntentos != porDefecto.MaxIntentos {
+		t.Fatalf("Se esperaban los valores por defecto, obtenidos: %+v", despachador.config)
+	}
+
+	// Iniciar no debe entrar en pánico por un intervalo cero
+	ctx, cancelar := context.WithCancel(context.Background())
+	cancelar()
+	despachador.Iniciar(ctx)
+}
+
+func TestDespachadorReintentaSoloCanalesFallidos(t *testing.T) {
+	fax := &canalSimulado{nombre: "fax", error: &ErrorReintentable{Err: fmt.Errorf("sin papel")}}
+	paloma := &canalSimulado{nombre: "paloma"}
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalPush),
+		ConCanal(fax, true),
+		ConCanal(paloma, true),
+	)
+
+	bandeja := NuevaBandejaSalida()
+	config := ConfigDespachador{TamanoLote: 10, MaxIntentos: 3, RetardoBase: 0}
+	despachador := NuevoDespachadorBandeja(bandeja, servicio, config)
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 0 {
+		t.Fatalf("No se esperaba completar la entrada con un canal fallido")
+	}
+	pendientes, _ := bandeja.Listar(EntradaPendiente)
+	if len(pendientes) != 1 || len(pendientes[0].Canales) != 2 {
+		t.Fatalf("Se esperaba una entrada pendiente con el resultado de los 2 canales: %+v", pendientes)
+	}
+
+	fax.error = nil
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 1 {
+		t.Fatalf("Se esperaba completar la entrada en el reintento")
+	}
+	if fax.enviado != 2 {
+		t.Errorf("Se esperaba reintentar el canal fax, envíos: %d", fax.enviado)
+	}
+	if paloma.enviado != 1 {
+		t.Errorf("No se esperaba reenviar por el canal que ya había entregado, envíos: %d", paloma.enviado)
+	}
+}
+
+func TestDespachadorNoReintentaErroresDefinitivos(t *testing.T) {
+	fax := &canalSimulado{nombre: "fax", error: fmt.Errorf("número de fax inválido")}
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalPush),
+		ConCanal(fax, true),
+	)
+
+	bandeja := NuevaBandejaSalida()
+	config := ConfigDespachador{TamanoLote: 10, MaxIntentos: 5, RetardoBase: 0}
+	despachador := NuevoDespachadorBandeja(bandeja, servicio, config)
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+
+	despachador.ProcesarPendientes()
+	despachador.ProcesarPendientes()
+
+	fallidas, _ := bandeja.Listar(EntradaFallida)
+	if len(fallidas) != 1 || fallidas[0].Intentos != 1 {
+		t.Fatalf("Se esperaba una entrada fallida tras un único intento: %+v", fallidas)
+	}
+	if !fallidas[0].Canales[0].Definitivo {
+		t.Errorf("Se esperaba marcar el error del canal como definitivo: %+v", fallidas[0].Canales)
+	}
+	if fax.enviado != 1 {
+		t.Errorf("No se esperaba reintentar un error definitivo, envíos: %d", fax.enviado)
+	}
+}
+
+func TestDespachadorReintentaConDisyuntorAbierto(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+	notificador := &notificadorFallido{err: fmt.Errorf("canal push: %w", ErrCircuitoAbierto)}
+	config := ConfigDespachador{TamanoLote: 10, MaxIntentos: 3, RetardoBase: 0}
+	despachador := NuevoDespachadorBandeja(bandeja, notificador, config)
+	bandeja.Notificar(nuevaNotificacionPrueba(1))
+
+	despachador.ProcesarPendientes()
+
+	pendientes, _ := bandeja.Listar(EntradaPendiente)
+	if len(pendientes) != 1 || pendientes[0].Intentos != 1 {
+		t.Fatalf("Se esperaba reintentar con el disyuntor abierto: %+v", pendientes)
+	}
+}
+
+type notificadorFallido struct {
+	err error
+}
+
+func (n *notificadorFallido) Notificar(notificacion Notificacion) error {
+	return n.err
+}
@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Nombres de los canales incluidos en el paquete
+const (
+	CanalEmail = "email"
+	CanalPush  = "push"
+)
+
+// Canal es un medio de entrega de notificaciones (email, push, etc.)
+type Canal interface {
+	// Nombre identifica al canal dentro del registro
+	Nombre() string
+
+	// Enviar entrega la notificación por el canal
+	Enviar(notificacion Notificacion) error
+}
+
+type canalRegistrado struct {
+	canal      Canal
+	habilitado bool
+}
+
+// RegistroCanales mantiene los canales disponibles, en orden de registro, con su indicador de habilitación
+type RegistroCanales struct {
+	mu      sync.RWMutex
+	canales []canalRegistrado
+}
+
+func NuevoRegistroCanales() *RegistroCanales {
+	return &RegistroCanales{}
+}
+
+// Registrar agrega un canal nuevo; falla si ya existe uno con el mismo nombre
+func (r *RegistroCanales) Registrar(canal Canal, habilitado bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.indice(canal.Nombre()) >= 0 {
+		return fmt.Errorf("el canal %s ya está registrado", canal.Nombre())
+	}
+
+	r.canales = append(r.canales, canalRegistrado{canal: canal, habilitado: habilitado})
+	return nil
+}
+
+// Reemplazar cambia la implementación de un canal existente conservando su habilitación
+func (r *RegistroCanales) Reemplazar(canal Canal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indice(canal.Nombre())
+	if i < 0 {
+		return fmt.Errorf("el canal %s no está registrado", canal.Nombre())
+	}
+
+	r.canales[i].canal = canal
+	return nil
+}
+
+// Eliminar quita un canal del registro
+func (r *RegistroCanales) Eliminar(nombre string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indice(nombre)
+	if i < 0 {
+		return fmt.Errorf("el canal %s no está registrado", nombre)
+	}
+
+	r.canales = append(r.canales[:i:i], r.canales[i+1:]...)
+	return nil
+}
+
+// Habilitar activa o desactiva el envío por un canal
+func (r *RegistroCanales) Habilitar(nombre string, habilitado bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indice(nombre)
+	if i < 0 {
+		return fmt.Errorf("el canal %s no está registrado", nombre)
+	}
+
+	r.canales[i].habilitado = habilitado
+	return nil
+}
+
+// Obtener devuelve el canal registrado con el nombre indicado
+func (r *RegistroCanales) Obtener(nombre string) (Canal, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.indice(nombre)
+	if i < 0 {
+		return nil, false
+	}
+	return r.canales[i].canal, true
+}
+
+// Habilitados devuelve los canales habilitados en orden de registro
+func (r *RegistroCanales) Habilitados() []Canal {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var canales []Canal
+	for _, registrado := range r.canales {
+		if registrado.habilitado {
+			canales = append(canales, registrado.canal)
+		}
+	}
+	return canales
+}
+
+// Todos devuelve todos los canales registrados en orden de registro
+func (r *RegistroCanales) Todos() []Canal {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	canales := make([]Canal, 0, len(r.canales))
+	for _, registrado := range r.canales {
+		canales = append(canales, registrado.canal)
+	}
+	return canales
+}
+
+func (r *RegistroCanales) indice(nombre string) int {
+	for i, registrado := range r.canales {
+		if registrado.canal.Nombre() == nombre {
+			return i
+		}
+	}
+	return -1
+}
@@ -2,47 +2,146 @@ package notification
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ConfiguracionNotificaciones struct {
 	EmailHabilitado   bool
 	PushHabilitado    bool
+	SMSHabilitado     bool
 	ConfiguracionSMTP ConfigSMTP
 	ConfiguracionPush ConfigPush
+	ConfiguracionSMS  ConfigSMS
+
+	// Reintentos y Disyuntor se aplican a cada canal; si están vacíos se usan los valores por defecto
+	Reintentos *PoliticaReintentos
+	Disyuntor  *ConfigDisyuntor
+
+	// ConcurrenciaPorCanal limita los envíos simultáneos de cada canal; los canales ausentes no tienen límite
+	ConcurrenciaPorCanal map[string]int
+
+	// VentanaDeduplicacion evita reenviar la misma notificación (compra, tipo y destinatario)
+	// por un canal durante ese lapso; cero desactiva la deduplicación
+	VentanaDeduplicacion time.Duration
+
+	// DirectorioPlantillas permite reemplazar las plantillas incluidas sin recompilar
+	DirectorioPlantillas string
 }
 
-// ConfigSMTP contiene la configuración para envío de emails
+// ConfigSMTP contiene la configuración para envío de emails. Sin Puerto se usa el habitual
+// del modo de seguridad; sin Seguridad ni Autenticacion se usan TLS implícito y PLAIN.
 type ConfigSMTP struct {
 	Host      string
 	Puerto    int
 	Usuario   string
 	Clave     string
 	Remitente string
+
+	Seguridad     SeguridadSMTP
+	Autenticacion MecanismoAuthSMTP
+
+	// ArchivoCA es un archivo PEM con autoridades certificantes propias, por ejemplo de un relay interno.
+	// OmitirVerificacion desactiva la verificación del certificado y solo debe usarse en desarrollo.
+	ArchivoCA          string
+	OmitirVerificacion bool
+
+	TimeoutConexion  time.Duration
+	TimeoutOperacion time.Duration
 }
 
-// ConfigPush contiene la configuración para notificaciones push
+// ConfigPush contiene la configuración para notificaciones push. Proveedor define el formato
+// del mensaje (GENERICO por defecto, FCM o APNS); Tema es el bundle ID requerido por APNs.
 type ConfigPush struct {
 	ServidorAPI string
 	ClaveAPI    string
+	Timeout     time.Duration
+
+	Proveedor ProveedorPush
+	Tema      string
+	Prioridad string        // ALTA (por defecto) o NORMAL
+	TTL       time.Duration // Tiempo que el proveedor conserva el mensaje si el dispositivo no está conectado
+}
+
+// ConfigSMS contiene la configuración de la pasarela HTTP de mensajes de texto
+type ConfigSMS struct {
+	URLPasarela          string
+	ClaveAPI             string
+	Remitente            string
+	CodigoPaisPorDefecto string // Se antepone a los números sin prefijo internacional, por ejemplo "54"
+	MaxSegmentos         int
+	Timeout              time.Duration
 }
 
 func CargarConfiguracionDesdeVariablesEntorno() ConfiguracionNotificaciones {
 	return ConfiguracionNotificaciones{
 		EmailHabilitado: os.Getenv("EMAIL_HABILITADO") == "true",
 		PushHabilitado:  os.Getenv("PUSH_HABILITADO") == "true",
+		SMSHabilitado:   os.Getenv("SMS_HABILITADO") == "true",
 		ConfiguracionSMTP: ConfigSMTP{
-			Host:      os.Getenv("SMTP_HOST"),
-			Puerto:    8025, // Puerto por defecto
-			Usuario:   os.Getenv("SMTP_USUARIO"),
-			Clave:     os.Getenv("SMTP_CLAVE"),
-			Remitente: os.Getenv("SMTP_REMITENTE"),
+			Host:               os.Getenv("SMTP_HOST"),
+			Puerto:             enteroDesdeEntorno("SMTP_PUERTO"),
+			Usuario:            os.Getenv("SMTP_USUARIO"),
+			Clave:              os.Getenv("SMTP_CLAVE"),
+			Remitente:          os.Getenv("SMTP_REMITENTE"),
+			Seguridad:          SeguridadSMTP(strings.ToUpper(os.Getenv("SMTP_SEGURIDAD"))),
+			Autenticacion:      MecanismoAuthSMTP(strings.ToUpper(os.Getenv("SMTP_AUTENTICACION"))),
+			ArchivoCA:          os.Getenv("SMTP_ARCHIVO_CA"),
+			OmitirVerificacion: os.Getenv("SMTP_OMITIR_VERIFICACION") == "true",
+			TimeoutConexion:    duracionDesdeEntorno("SMTP_TIMEOUT_CONEXION"),
+			TimeoutOperacion:   duracionDesdeEntorno("SMTP_TIMEOUT_OPERACION"),
 		},
 		ConfiguracionPush: ConfigPush{
 			ServidorAPI: os.Getenv("PUSH_SERVIDOR_API"),
 			ClaveAPI:    os.Getenv("PUSH_CLAVE_API"),
+			Proveedor:   ProveedorPush(strings.ToUpper(os.Getenv("PUSH_PROVEEDOR"))),
+			Tema:        os.Getenv("PUSH_TEMA"),
+			Prioridad:   strings.ToUpper(os.Getenv("PUSH_PRIORIDAD")),
+			TTL:         duracionDesdeEntorno("PUSH_TTL"),
 		},
+		ConfiguracionSMS: ConfigSMS{
+			URLPasarela:          os.Getenv("SMS_URL_PASARELA"),
+			ClaveAPI:             os.Getenv("SMS_CLAVE_API"),
+			Remitente:            os.Getenv("SMS_REMITENTE"),
+			CodigoPaisPorDefecto: os.Getenv("SMS_CODIGO_PAIS"),
+		},
+		VentanaDeduplicacion: duracionDesdeEntorno("NOTIFICACIONES_VENTANA_DEDUPLICACION"),
+		DirectorioPlantillas: os.Getenv("NOTIFICACIONES_DIR_PLANTILLAS"),
+	}
+}
+
+// enteroDesdeEntorno interpreta la variable como entero; si está vacía o es inválida devuelve cero
+func enteroDesdeEntorno(variable string) int {
+	valor := os.Getenv(variable)
+	if valor == "" {
+		return 0
+	}
+
+	entero, err := strconv.Atoi(valor)
+	if err != nil {
+		log.Printf("Valor inválido para %s: %q", variable, valor)
+		return 0
 	}
+	return entero
+}
+
+// duracionDesdeEntorno interpreta la variable con el formato de time.ParseDuration, por ejemplo "30m".
+// Si está vacía o es inválida devuelve cero.
+func duracionDesdeEntorno(variable string) time.Duration {
+	valor := os.Getenv(variable)
+	if valor == "" {
+		return 0
+	}
+
+	duracion, err := time.ParseDuration(valor)
+	if err != nil {
+		log.Printf("Valor inválido para %s: %q", variable, valor)
+		return 0
+	}
+	return duracion
 }
 
 func (c *ConfiguracionNotificaciones) Validar() error {
@@ -50,16 +149,65 @@ func (c *ConfiguracionNotificaciones) Validar() error {
 		if c.ConfiguracionSMTP.Host == "" {
 			return fmt.Errorf("host SMTP es requerido para notificaciones por email")
 		}
-		if c.ConfiguracionSMTP.Usuario == "" {
+		if c.ConfiguracionSMTP.Usuario == "" && c.ConfiguracionSMTP.Autenticacion != AuthNinguna {
 			return fmt.Errorf("usuario SMTP es requerido para notificaciones por email")
 		}
+		if err := c.ConfiguracionSMTP.Validar(); err != nil {
+			return err
+		}
 	}
 
 	if c.PushHabilitado {
 		if c.ConfiguracionPush.ServidorAPI == "" {
 			return fmt.Errorf("servidor API es requerido para notificaciones push")
 		}
+		if _, err := nuevoAdaptadorPush(c.ConfiguracionPush.Proveedor); err != nil {
+			return err
+		}
+		if c.ConfiguracionPush.Proveedor == ProveedorAPNs && c.ConfiguracionPush.Tema == "" {
+			return fmt.Errorf("tema (bundle ID) es requerido para notificaciones push por APNs")
+		}
+	}
+
+	if c.SMSHabilitado {
+		if c.ConfiguracionSMS.URLPasarela == "" {
+			return fmt.Errorf("URL de la pasarela es requerida para notificaciones SMS")
+		}
+	}
+
+	if c.DirectorioPlantillas != "" {
+		if _, err := CargarMotorPlantillas(c.DirectorioPlantillas); err != nil {
+			return fmt.Errorf("plantillas de notificación inválidas: %w", err)
+		}
 	}
 
 	return nil
 }
+
+// motorPlantillas carga las plantillas del directorio configurado; si falla se usan las incluidas
+func (c *ConfiguracionNotificaciones) motorPlantillas() *MotorPlantillas {
+	if c.DirectorioPlantillas == "" {
+		return NuevoMotorPlantillas()
+	}
+
+	motor, err := CargarMotorPlantillas(c.DirectorioPlantillas)
+	if err != nil {
+		log.Printf("Error al cargar plantillas, se usan las incluidas: %v", err)
+		// Se conserva el directorio para que una recarga posterior tome las plantillas corregidas
+		motor = NuevoMotorPlantillas()
+		motor.directorio = c.DirectorioPlantillas
+	}
+	return motor
+}
+
+// opcionesEnvio traduce la configuración de resiliencia a opciones de los canales
+func (c *ConfiguracionNotificaciones) opcionesEnvio() []OpcionEnvio {
+	var opciones []OpcionEnvio
+	if c.Reintentos != nil {
+		opciones = append(opciones, ConReintentos(*c.Reintentos))
+	}
+	if c.Disyuntor != nil {
+		opciones = append(opciones, ConDisyuntor(*c.Disyuntor))
+	}
+	return opciones
+}
@@ -13,6 +13,8 @@ func TestCargarConfiguracionDesdeVariablesEntorno(t *testing.T) {
 	os.Setenv("SMTP_USUARIO", "usuario_prueba")
 	os.Setenv("SMTP_CLAVE", "clave_prueba")
 	os.Setenv("SMTP_REMITENTE", "remitente@ejemplo.com")
+	os.Setenv("SMTP_PUERTO", "2525")
+	os.Setenv("SMTP_SEGURIDAD", "starttls")
 	os.Setenv("PUSH_SERVIDOR_API", "https://api-push.ejemplo.com")
 	os.Setenv("PUSH_CLAVE_API", "token_push")
 	defer func() {
@@ -22,6 +24,8 @@ func TestCargarConfiguracionDesdeVariablesEntorno(t *testing.T) {
 		os.Unsetenv("SMTP_USUARIO")
 		os.Unsetenv("SMTP_CLAVE")
 		os.Unsetenv("SMTP_REMITENTE")
+		os.Unsetenv("SMTP_PUERTO")
+		os.Unsetenv("SMTP_SEGURIDAD")
 		os.Unsetenv("PUSH_SERVIDOR_API")
 		os.Unsetenv("PUSH_CLAVE_API")
 	}()
@@ -44,6 +48,10 @@ func TestCargarConfiguracionDesdeVariablesEntorno(t *testing.T) {
 		t.Errorf("Usuario SMTP incorrecto. Esperado: usuario_prueba, Obtenido: %s", configuracion.ConfiguracionSMTP.Usuario)
 	}
 
+	if configuracion.ConfiguracionSMTP.Puerto != 2525 || configuracion.ConfiguracionSMTP.Seguridad != SeguridadSTARTTLS {
+		t.Errorf("Puerto o seguridad SMTP incorrectos: %d %s", configuracion.ConfiguracionSMTP.Puerto, configuracion.ConfiguracionSMTP.Seguridad)
+	}
+
 	if configuracion.ConfiguracionPush.ServidorAPI != "https://api-push.ejemplo.com" {
 		t.Errorf("Servidor Push incorrecto. Esperado: https://api-push.ejemplo.com, Obtenido: %s", configuracion.ConfiguracionPush.ServidorAPI)
 	}
@@ -108,6 +116,14 @@ func TestValidarConfiguracion(t *testing.T) {
 			esperaError:  true,
 			mensajeError: "servidor API es requerido para notificaciones push",
 		},
+		{
+			nombre: "SMS Habilitado Sin Pasarela",
+			configuracion: ConfiguracionNotificaciones{
+				SMSHabilitado: true,
+			},
+			esperaError:  true,
+			mensajeError: "URL de la pasarela es requerida para notificaciones SMS",
+		},
 	}
 
 	for _, tc := range testCases {
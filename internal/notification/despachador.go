@@ -0,0 +1,165 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Notificador envía una notificación por los canales configurados.
+// ServicioNotificaciones satisface esta interfaz.
+type Notificador interface {
+	Notificar(notificacion Notificacion) error
+}
+
+// NotificadorPorCanal además envía por un subconjunto de canales e informa el resultado
+// de cada uno, para que el despachador reintente solo los canales que fallaron.
+// ServicioNotificaciones satisface esta interfaz.
+type NotificadorPorCanal interface {
+	Notificador
+	NotificarPorCanales(notificacion Notificacion, canales []string) ([]ResultadoCanal, error)
+}
+
+// ConfigDespachador define cómo se drena la bandeja de salida
+type ConfigDespachador struct {
+	Intervalo   time.Duration
+	TamanoLote  int
+	MaxIntentos int
+	RetardoBase time.Duration
+}
+
+// ConfigDespachadorPorDefecto devuelve valores razonables para el despachador
+func ConfigDespachadorPorDefecto() ConfigDespachador {
+	return ConfigDespachador{
+		Intervalo:   5 * time.Second,
+		TamanoLote:  50,
+		MaxIntentos: 5,
+		RetardoBase: 10 * time.Second,
+	}
+}
+
+// DespachadorBandeja envía en segundo plano las notificaciones pendientes de la bandeja de salida
+type DespachadorBandeja struct {
+	bandeja     AlmacenBandeja
+	notificador Notificador
+	config      ConfigDespachador
+	ahora       func() time.Time
+}
+
+// NuevoDespachadorBandeja crea el despachador. Un Intervalo o MaxIntentos cero o negativo
+// toma el valor de ConfigDespachadorPorDefecto; un TamanoLote cero procesa todas las pendientes.
+func NuevoDespachadorBandeja(bandeja AlmacenBandeja, notificador Notificador, config ConfigDespachador) *DespachadorBandeja {
+	porDefecto := ConfigDespachadorPorDefecto()
+	if config.Intervalo <= 0 {
+		config.Intervalo = porDefecto.Intervalo
+	}
+	if config.MaxIntentos <= 0 {
+		config.MaxIntentos = porDefecto.MaxIntentos
+	}
+
+	return &DespachadorBandeja{
+		bandeja:     bandeja,
+		notificador: notificador,
+		config:      config,
+		ahora:       time.Now,
+	}
+}
+
+// Iniciar procesa la bandeja periódicamente hasta que se cancele el contexto
+func (d *DespachadorBandeja) Iniciar(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Intervalo)
+	defer ticker.Stop()
+
+	for {
+		d.ProcesarPendientes()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcesarPendientes intenta enviar las entradas pendientes y devuelve cuántas se enviaron
+func (d *DespachadorBandeja) ProcesarPendientes() int {
+	pendientes, err := d.bandeja.Pendientes(d.ahora(), d.config.TamanoLote)
+	if err != nil {
+		log.Printf("Error al leer la bandeja de salida: %v", err)
+		return 0
+	}
+
+	enviadas := 0
+	for _, entrada := range pendientes {
+		err := d.enviar(&entrada)
+
+		// El horario de silencio del destinatario posterga el envío sin consumir intentos
+		var silencio *ErrorHorarioSilencio
+		if errors.As(err, &silencio) {
+			entrada.ProximoIntento = silencio.Hasta
+			if err := d.bandeja.Actualizar(entrada); err != nil {
+				log.Printf("Error al actualizar la entrada %d de la bandeja de salida: %v", entrada.ID, err)
+			}
+			continue
+		}
+
+		entrada.Intentos++
+		if err != nil {
+			entrada.UltimoError = err.Error()
+			if !entrada.reintentable(err) {
+				entrada.Estado = EntradaFallida
+				log.Printf("Notificación %d descartada por un error que no se reintenta: %v", entrada.ID, err)
+			} else if entrada.Intentos >= d.config.MaxIntentos {
+				entrada.Estado = EntradaFallida
+				log.Printf("Notificación %d descartada tras %d intentos: %v", entrada.ID, entrada.Intentos, err)
+			} else {
+				entrada.ProximoIntento = d.ahora().Add(d.retardo(entrada.Intentos))
+			}
+		} else {
+			entrada.Estado = EntradaEnviada
+			entrada.UltimoError = ""
+			enviadas++
+		}
+
+		if err := d.bandeja.Actualizar(entrada); err != nil {
+			log.Printf("Error al actualizar la entrada %d de la bandeja de salida: %v", entrada.ID, err)
+		}
+	}
+
+	return enviadas
+}
+
+// enviar notifica la entrada. Si el notificador informa el resultado por canal, se
+// guarda en la entrada y en los reintentos solo se envía por los canales que fallaron,
+// para no repetir el mensaje en los que ya lo entregaron.
+func (d *DespachadorBandeja) enviar(entrada *EntradaBandeja) error {
+	porCanal, ok := d.notificador.(NotificadorPorCanal)
+	if !ok {
+		return d.notificador.Notificar(entrada.Notificacion)
+	}
+
+	pendientes := entrada.canalesPendientes()
+	resultados, err := porCanal.NotificarPorCanales(entrada.Notificacion, pendientes)
+	if err != nil {
+		return err
+	}
+
+	entrada.registrarResultados(pendientes, resultados)
+	for _, resultado := range resultados {
+		if resultado.Error != nil {
+			return &ErrorNotificacion{Resultados: resultados}
+		}
+	}
+
+	return nil
+}
+
+// retardo calcula la espera exponencial antes del próximo intento
+func (d *DespachadorBandeja) retardo(intentos int) time.Duration {
+	retardo := d.config.RetardoBase
+	for i := 1; i < intentos; i++ {
+		retardo *= 2
+	}
+	return retardo
+}
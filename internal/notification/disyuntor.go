@@ -0,0 +1,126 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitoAbierto indica que el canal dejó de enviar porque el proveedor falla de forma reiterada
+var ErrCircuitoAbierto = errors.New("circuito abierto: proveedor no disponible")
+
+// EstadoDisyuntor representa el estado del disyuntor (circuit breaker) de un canal
+type EstadoDisyuntor string
+
+const (
+	DisyuntorCerrado     EstadoDisyuntor = "CERRADO"
+	DisyuntorAbierto     EstadoDisyuntor = "ABIERTO"
+	DisyuntorSemiabierto EstadoDisyuntor = "SEMIABIERTO"
+)
+
+// ConfigDisyuntor define cuándo se abre el circuito y cuánto tiempo permanece abierto
+type ConfigDisyuntor struct {
+	UmbralFallos   int
+	TiempoApertura time.Duration
+}
+
+// ConfigDisyuntorPorDefecto abre el circuito tras 5 fallos consecutivos durante 30 segundos
+func ConfigDisyuntorPorDefecto() ConfigDisyuntor {
+	return ConfigDisyuntor{
+		UmbralFallos:   5,
+		TiempoApertura: 30 * time.Second,
+	}
+}
+
+// InstantaneaDisyuntor expone el estado del disyuntor para monitoreo
+type InstantaneaDisyuntor struct {
+	Estado             EstadoDisyuntor `json:"estado"`
+	FallosConsecutivos int             `json:"fallos_consecutivos"`
+	AbiertoDesde       *time.Time      `json:"abierto_desde,omitempty"`
+}
+
+// Disyuntor corta los envíos a un proveedor caído y permite un intento de prueba
+// una vez transcurrido el tiempo de apertura
+type Disyuntor struct {
+	mu                 sync.Mutex
+	config             ConfigDisyuntor
+	estado             EstadoDisyuntor
+	fallosConsecutivos int
+	abiertoDesde       time.Time
+	pruebaEnCurso      bool
+	ahora              func() time.Time
+}
+
+func NuevoDisyuntor(config ConfigDisyuntor) *Disyuntor {
+	return &Disyuntor{
+		config: config,
+		estado: DisyuntorCerrado,
+		ahora:  time.Now,
+	}
+}
+
+// Permitir indica si se puede intentar un envío
+func (d *Disyuntor) Permitir() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.estado {
+	case DisyuntorAbierto:
+		if d.ahora().Sub(d.abiertoDesde) < d.config.TiempoApertura {
+			return false
+		}
+		d.estado = DisyuntorSemiabierto
+		d.pruebaEnCurso = true
+		return true
+	case DisyuntorSemiabierto:
+		if d.pruebaEnCurso {
+			return false
+		}
+		d.pruebaEnCurso = true
+		return true
+	}
+
+	return true
+}
+
+// RegistrarExito cierra el circuito y reinicia el conteo de fallos
+func (d *Disyuntor) RegistrarExito() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.estado = DisyuntorCerrado
+	d.fallosConsecutivos = 0
+	d.pruebaEnCurso = false
+}
+
+// RegistrarFallo suma un fallo y abre el circuito al alcanzar el umbral o si falla el intento de prueba
+func (d *Disyuntor) RegistrarFallo() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fallosConsecutivos++
+	d.pruebaEnCurso = false
+
+	if d.estado == DisyuntorSemiabierto || (d.config.UmbralFallos > 0 && d.fallosConsecutivos >= d.config.UmbralFallos) {
+		d.estado = DisyuntorAbierto
+		d.abiertoDesde = d.ahora()
+	}
+}
+
+// Instantanea devuelve el estado actual del disyuntor
+func (d *Disyuntor) Instantanea() InstantaneaDisyuntor {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	instantanea := InstantaneaDisyuntor{
+		Estado:             d.estado,
+		FallosConsecutivos: d.fallosConsecutivos,
+	}
+
+	if d.estado != DisyuntorCerrado {
+		abiertoDesde := d.abiertoDesde
+		instantanea.AbiertoDesde = &abiertoDesde
+	}
+
+	return instantanea
+}
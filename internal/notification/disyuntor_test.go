@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDisyuntorSeAbreTrasUmbral(t *testing.T) {
+	disyuntor := NuevoDisyuntor(ConfigDisyuntor{UmbralFallos: 2, TiempoApertura: time.Minute})
+	ahora := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	disyuntor.ahora = func() time.Time { return ahora }
+
+	disyuntor.RegistrarFallo()
+	if disyuntor.Instantanea().Estado != DisyuntorCerrado {
+		t.Fatalf("Se esperaba el circuito cerrado tras un fallo")
+	}
+
+	disyuntor.RegistrarFallo()
+	if disyuntor.Instantanea().Estado != DisyuntorAbierto {
+		t.Fatalf("Se esperaba el circuito abierto tras alcanzar el umbral")
+	}
+	if disyuntor.Permitir() {
+		t.Errorf("No se esperaba permitir envíos con el circuito abierto")
+	}
+
+	ahora = ahora.Add(time.Minute)
+	if !disyuntor.Permitir() {
+		t.Fatalf("Se esperaba permitir un intento de prueba tras el tiempo de apertura")
+	}
+	if disyuntor.Permitir() {
+		t.Errorf("Solo se esperaba un intento de prueba simultáneo")
+	}
+
+	disyuntor.RegistrarFallo()
+	if disyuntor.Instantanea().Estado != DisyuntorAbierto {
+		t.Fatalf("Se esperaba reabrir el circuito si falla el intento de prueba")
+	}
+
+	ahora = ahora.Add(time.Minute)
+	disyuntor.Permitir()
+	disyuntor.RegistrarExito()
+	instantanea := disyuntor.Instantanea()
+	if instantanea.Estado != DisyuntorCerrado || instantanea.FallosConsecutivos != 0 {
+		t.Errorf("Se esperaba cerrar el circuito tras un intento exitoso: %+v", instantanea)
+	}
+}
+
+func TestServicioPushDejaDeLlamarConCircuitoAbierto(t *testing.T) {
+	var llamadas int32
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&llamadas, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioPush(
+		ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "token"},
+		ConReintentos(PoliticaReintentos{MaxIntentos: 2, CodigosReintentables: []int{http.StatusServiceUnavailable}}),
+		ConDisyuntor(ConfigDisyuntor{UmbralFallos: 2, TiempoApertura: time.Hour}),
+	)
+	servicio.dormir = func(time.Duration) {}
+
+	if err := servicio.Enviar(nuevaNotificacionPrueba(1)); err == nil {
+		t.Fatal("Se esperaba un error con el proveedor caído")
+	}
+
+	err := servicio.Enviar(nuevaNotificacionPrueba(2))
+	if !errors.Is(err, ErrCircuitoAbierto) {
+		t.Errorf("Se esperaba ErrCircuitoAbierto, obtenido: %v", err)
+	}
+	if llamadas != 2 {
+		t.Errorf("Se esperaban 2 llamadas al proveedor, obtenidas: %d", llamadas)
+	}
+	if servicio.EstadoDisyuntor().Estado != DisyuntorAbierto {
+		t.Errorf("Se esperaba exponer el circuito abierto")
+	}
+}
@@ -1,91 +1,170 @@
 package notification
 
 import (
-	"crypto/tls"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
 )
 
 // ServicioEmail maneja el envío de notificaciones por correo electrónico
 type ServicioEmail struct {
-	config ConfigSMTP
+	config     ConfigSMTP
+	reintentos PoliticaReintentos
+	disyuntor  *Disyuntor
+	dormir     func(time.Duration)
+	plantillas *MotorPlantillas
+	transporte *transporteSMTP
 }
 
-func NuevoServicioEmail(config ConfigSMTP) *ServicioEmail {
+func NuevoServicioEmail(config ConfigSMTP, opciones ...OpcionEnvio) *ServicioEmail {
+	o := nuevasOpcionesEnvio(opciones)
+
 	return &ServicioEmail{
-		config: config,
+		config:     config,
+		reintentos: o.reintentos,
+		disyuntor:  NuevoDisyuntor(o.disyuntor),
+		dormir:     o.dormir,
+		plantillas: o.plantillas,
+		transporte: nuevoTransporteSMTP(config),
 	}
 }
 
+// Cerrar termina las conexiones SMTP que quedaron abiertas para reutilizar
+func (s *ServicioEmail) Cerrar() {
+	s.transporte.cerrar()
+}
+
+// Nombre identifica al canal en el registro
+func (s *ServicioEmail) Nombre() string {
+	return CanalEmail
+}
+
+// EstadoDisyuntor devuelve el estado del disyuntor del canal email
+func (s *ServicioEmail) EstadoDisyuntor() InstantaneaDisyuntor {
+	return s.disyuntor.Instantanea()
+}
+
 func (s *ServicioEmail) Enviar(notificacion Notificacion) error {
+	_, err := s.EnviarConDetalle(notificacion)
+	return err
+}
 
-	if s.config.Host == "" || s.config.Usuario == "" || s.config.Clave == "" {
-		return fmt.Errorf("configuración de email incompleta")
-	}
+// EnviarConDetalle envía el email e informa los intentos realizados y el servidor que lo aceptó
+func (s *ServicioEmail) EnviarConDetalle(notificacion Notificacion) (DetalleEnvio, error) {
 
-	// Se construye el mensaje
-	asunto := fmt.Sprintf("Notificación de Compra #%d", notificacion.IDCompra)
-	cuerpo := fmt.Sprintf(`
-		Tipo de Notificación: %s
-		ID de Compra: %d
-		Descripción: %s
-	`, notificacion.Tipo, notificacion.IDCompra, notificacion.Descripcion)
-
-	mensaje := fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"\r\n"+
-		"%s", s.config.Remitente, notificacion.Destinatario, asunto, cuerpo)
-
-	auth := smtp.PlainAuth("", s.config.Usuario, s.config.Clave, s.config.Host)
-
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         s.config.Host,
+	credenciales := s.config.Autenticacion == AuthNinguna || (s.config.Usuario != "" && s.config.Clave != "")
+	if s.config.Host == "" || !credenciales {
+		return DetalleEnvio{}, fmt.Errorf("configuración de email incompleta")
 	}
 
-	conn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", s.config.Host, s.config.Puerto), tlsConfig)
+	// Se construye el mensaje a partir de las plantillas del tipo de notificación
+	renderizado, err := s.plantillas.Renderizar(notificacion)
 	if err != nil {
-		return fmt.Errorf("error al establecer conexión TLS: %w", err)
+		return DetalleEnvio{}, err
 	}
 
-	cliente, err := smtp.NewClient(conn, s.config.Host)
+	mensaje, err := construirMensajeMIME(s.config.Remitente, notificacion.Destinatario, renderizado)
 	if err != nil {
-		return fmt.Errorf("error al crear cliente SMTP: %w", err)
+		return DetalleEnvio{}, fmt.Errorf("error al construir mensaje: %w", err)
 	}
-	defer cliente.Close()
 
-	if err = cliente.Auth(auth); err != nil {
-		return fmt.Errorf("error de autenticación: %w", err)
-	}
+	intentos, err := ejecutarContandoIntentos(s.reintentos, s.disyuntor, s.dormir, func() error {
+		return s.transporte.enviar(s.config.Remitente, notificacion.Destinatario, mensaje)
+	})
 
-	if err = cliente.Mail(s.config.Remitente); err != nil {
-		return fmt.Errorf("error al configurar remitente: %w", err)
+	detalle := DetalleEnvio{Intentos: intentos}
+	if err == nil {
+		detalle.Respuesta = fmt.Sprintf("aceptado por %s", s.config.direccion())
 	}
+	return detalle, err
+}
 
-	if err = cliente.Rcpt(notificacion.Destinatario); err != nil {
-		return fmt.Errorf("error al configurar destinatario: %w", err)
+// construirMensajeMIME arma un mensaje multipart/alternative con las versiones de texto y HTML
+func construirMensajeMIME(remitente, destinatario string, renderizado MensajeRenderizado) (string, error) {
+	var cuerpo bytes.Buffer
+	partes := multipart.NewWriter(&cuerpo)
+
+	for _, parte := range []struct {
+		tipo      string
+		contenido string
+	}{
+		{"text/plain; charset=UTF-8", renderizado.Texto},
+		{"text/html; charset=UTF-8", renderizado.HTML},
+	} {
+		encabezado := textproto.MIMEHeader{}
+		encabezado.Set("Content-Type", parte.tipo)
+		encabezado.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		w, err := partes.CreatePart(encabezado)
+		if err != nil {
+			return "", err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(parte.contenido)); err != nil {
+			return "", err
+		}
+		if err := qp.Close(); err != nil {
+			return "", err
+		}
 	}
 
-	w, err := cliente.Data()
-	if err != nil {
-		return fmt.Errorf("error al preparar datos: %w", err)
+	if err := partes.Close(); err != nil {
+		return "", err
 	}
 
-	_, err = w.Write([]byte(mensaje))
-	if err != nil {
-		return fmt.Errorf("error al escribir mensaje: %w", err)
+	var mensaje bytes.Buffer
+	fmt.Fprintf(&mensaje, "From: %s\r\n", remitente)
+	fmt.Fprintf(&mensaje, "To: %s\r\n", destinatario)
+	fmt.Fprintf(&mensaje, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", renderizado.Asunto))
+	fmt.Fprintf(&mensaje, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&mensaje, "Message-ID: %s\r\n", nuevoMessageID(remitente))
+	mensaje.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&mensaje, "Content-Type: multipart/alternative; boundary=%q\r\n", partes.Boundary())
+	mensaje.WriteString("\r\n")
+	mensaje.Write(cuerpo.Bytes())
+
+	return mensaje.String(), nil
+}
+
+// nuevoMessageID genera un identificador único usando el dominio del remitente
+func nuevoMessageID(remitente string) string {
+	dominio := "localhost"
+	if i := strings.LastIndex(remitente, "@"); i >= 0 && i < len(remitente)-1 {
+		dominio = strings.TrimSuffix(remitente[i+1:], ">")
 	}
 
-	err = w.Close()
-	if err != nil {
-		return fmt.Errorf("error al cerrar escritura: %w", err)
+	aleatorio := make([]byte, 12)
+	rand.Read(aleatorio)
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(aleatorio), dominio)
+}
+
+// clasificarErrorSMTP marca como reintentables los errores de red y las respuestas SMTP 4xx (temporales)
+func clasificarErrorSMTP(err error) error {
+	var errProtocolo *textproto.Error
+	if errors.As(err, &errProtocolo) {
+		if errProtocolo.Code >= 400 && errProtocolo.Code < 500 {
+			return &ErrorReintentable{Err: err}
+		}
+		return err
 	}
 
-	err = cliente.Quit()
-	if err != nil {
-		return fmt.Errorf("error al cerrar conexión SMTP: %w", err)
+	var errRed net.Error
+	if errors.As(err, &errRed) {
+		return &ErrorReintentable{Err: err}
 	}
 
-	return nil
+	return err
 }
+
+var _ CanalDetallado = &ServicioEmail{}
@@ -1,7 +1,11 @@
 package notification
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	"transport-challenge/internal/notification/notificationtest"
 )
 
 func TestNuevoServicioEmail(t *testing.T) {
@@ -25,6 +29,16 @@ func TestNuevoServicioEmail(t *testing.T) {
 }
 
 func TestEnviarEmail(t *testing.T) {
+	servidor, err := notificationtest.IniciarServidorSMTP(notificationtest.ConfigServidorSMTP{
+		TLS:     true,
+		Usuario: "usuario_prueba",
+		Clave:   "clave_prueba",
+	})
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor SMTP simulado: %v", err)
+	}
+	defer servidor.Cerrar()
+
 	testCases := []struct {
 		nombre            string
 		configuracionSMTP ConfigSMTP
@@ -34,11 +48,12 @@ func TestEnviarEmail(t *testing.T) {
 		{
 			nombre: "Configuración SMTP Válida",
 			configuracionSMTP: ConfigSMTP{
-				Host:      "smtp.ejemplo.com",
-				Puerto:    587,
+				Host:      servidor.Host(),
+				Puerto:    servidor.Puerto(),
 				Usuario:   "usuario_prueba",
 				Clave:     "clave_prueba",
 				Remitente: "remitente@ejemplo.com",
+				ArchivoCA: servidor.ArchivoCA(),
 			},
 			notificacion: Notificacion{
 				Tipo:         NotificacionCompraCreada,
@@ -85,6 +100,7 @@ func TestEnviarEmail(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.nombre, func(t *testing.T) {
 			servicio := NuevoServicioEmail(tc.configuracionSMTP)
+			defer servicio.Cerrar()
 			err := servicio.Enviar(tc.notificacion)
 
 			if tc.esperaError && err == nil {
@@ -96,4 +112,84 @@ func TestEnviarEmail(t *testing.T) {
 			}
 		})
 	}
+
+	mensajes, err := servidor.EsperarMensajes(1, time.Second)
+	if err != nil {
+		t.Fatalf("No se recibió el email en el servidor simulado: %v", err)
+	}
+	if mensajes[0].Destinatarios[0] != "cliente@ejemplo.com" || mensajes[0].Usuario != "usuario_prueba" || !mensajes[0].TLS {
+		t.Errorf("Mensaje capturado inesperado: %+v", mensajes[0])
+	}
+	if !strings.Contains(mensajes[0].Asunto(), "123") {
+		t.Errorf("Se esperaba el número de compra en el asunto, obtenido %q", mensajes[0].Asunto())
+	}
+}
+
+func TestEnviarEmailModosDeSeguridad(t *testing.T) {
+	testCases := []struct {
+		nombre        string
+		servidor      notificationtest.ConfigServidorSMTP
+		seguridad     SeguridadSMTP
+		autenticacion MecanismoAuthSMTP
+	}{
+		{"STARTTLS con LOGIN", notificationtest.ConfigServidorSMTP{STARTTLS: true, Usuario: "usuario", Clave: "clave"}, SeguridadSTARTTLS, AuthLogin},
+		{"TLS con CRAM-MD5", notificationtest.ConfigServidorSMTP{TLS: true, Usuario: "usuario", Clave: "clave"}, SeguridadTLS, AuthCRAMMD5},
+		{"Relay sin cifrar ni autenticación", notificationtest.ConfigServidorSMTP{}, SeguridadNinguna, AuthNinguna},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.nombre, func(t *testing.T) {
+			servidor, err := notificationtest.IniciarServidorSMTP(tc.servidor)
+			if err != nil {
+				t.Fatalf("No se esperaba un error al iniciar el servidor SMTP simulado: %v", err)
+			}
+			defer servidor.Cerrar()
+
+			servicio := NuevoServicioEmail(ConfigSMTP{
+				Host:          servidor.Host(),
+				Puerto:        servidor.Puerto(),
+				Usuario:       tc.servidor.Usuario,
+				Clave:         tc.servidor.Clave,
+				Remitente:     "remitente@ejemplo.com",
+				Seguridad:     tc.seguridad,
+				Autenticacion: tc.autenticacion,
+				ArchivoCA:     servidor.ArchivoCA(),
+			})
+			defer servicio.Cerrar()
+
+			if err := servicio.Enviar(nuevaNotificacionPrueba(1)); err != nil {
+				t.Fatalf("No se esperaba un error: %v", err)
+			}
+			if mensajes := servidor.Mensajes(); len(mensajes) != 1 || mensajes[0].TLS != (tc.seguridad != SeguridadNinguna) {
+				t.Errorf("Mensaje capturado inesperado: %+v", mensajes)
+			}
+		})
+	}
+}
+
+func TestEnviarEmailReintentaErrorTemporal(t *testing.T) {
+	servidor, err := notificationtest.IniciarServidorSMTP(notificationtest.ConfigServidorSMTP{})
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor SMTP simulado: %v", err)
+	}
+	defer servidor.Cerrar()
+	servidor.ProgramarFalla(notificationtest.FallaSMTP{Comando: "DATA", Codigo: 451, Veces: 1})
+
+	servicio := NuevoServicioEmail(ConfigSMTP{
+		Host:          servidor.Host(),
+		Puerto:        servidor.Puerto(),
+		Remitente:     "remitente@ejemplo.com",
+		Seguridad:     SeguridadNinguna,
+		Autenticacion: AuthNinguna,
+	})
+	servicio.dormir = func(time.Duration) {}
+	defer servicio.Cerrar()
+
+	detalle, err := servicio.EnviarConDetalle(nuevaNotificacionPrueba(1))
+	if err != nil {
+		t.Fatalf("No se esperaba un error tras el reintento: %v", err)
+	}
+	if detalle.Intentos != 2 || len(servidor.Mensajes()) != 1 {
+		t.Errorf("Se esperaban 2 intentos y 1 mensaje, obtenidos %d y %d", detalle.Intentos, len(servidor.Mensajes()))
+	}
 }
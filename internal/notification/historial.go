@@ -0,0 +1,140 @@
+package notification
+
+import (
+	"sync"
+	"time"
+)
+
+// EstadoEnvio es el resultado de un intento de envío por un canal
+type EstadoEnvio string
+
+const (
+	EnvioExitoso EstadoEnvio = "ENVIADO"
+	EnvioFallido EstadoEnvio = "FALLIDO"
+	EnvioOmitido EstadoEnvio = "OMITIDO"
+)
+
+// EsValido indica si el estado es uno de los definidos
+func (e EstadoEnvio) EsValido() bool {
+	return e == EnvioExitoso || e == EnvioFallido || e == EnvioOmitido
+}
+
+// DetalleEnvio describe cómo resultó la entrega en el proveedor del canal
+type DetalleEnvio struct {
+	Intentos  int
+	Respuesta string
+}
+
+// CanalDetallado es implementado por los canales que informan los intentos realizados
+// y la respuesta del proveedor además del error
+type CanalDetallado interface {
+	Canal
+	EnviarConDetalle(notificacion Notificacion) (DetalleEnvio, error)
+}
+
+// RegistroEnvio es una entrada del historial de envíos
+type RegistroEnvio struct {
+	ID           int              `json:"id"`
+	IDCompra     int              `json:"id_compra"`
+	Tipo         TipoNotificacion `json:"tipo"`
+	Canal        string           `json:"canal"`
+	Destinatario string           `json:"destinatario"`
+	Estado       EstadoEnvio      `json:"estado"`
+	Intentos     int              `json:"intentos"`
+	Respuesta    string           `json:"respuesta,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	Motivo       string           `json:"motivo,omitempty"`
+	EnResumen    bool             `json:"en_resumen,omitempty"` // La compra se notificó dentro de un RESUMEN
+	Fecha        time.Time        `json:"fecha"`
+}
+
+// FiltroHistorial selecciona entradas del historial; los campos vacíos no filtran
+type FiltroHistorial struct {
+	IDCompra     int
+	Tipo         TipoNotificacion
+	Canal        string
+	Destinatario string
+	Estado       EstadoEnvio
+	Desde        time.Time
+	Hasta        time.Time
+	Limite       int
+}
+
+// Coincide indica si el registro cumple el filtro
+func (f FiltroHistorial) Coincide(registro RegistroEnvio) bool {
+	switch {
+	case f.IDCompra != 0 && registro.IDCompra != f.IDCompra:
+		return false
+	case f.Tipo != "" && registro.Tipo != f.Tipo:
+		return false
+	case f.Canal != "" && registro.Canal != f.Canal:
+		return false
+	case f.Destinatario != "" && registro.Destinatario != f.Destinatario:
+		return false
+	case f.Estado != "" && registro.Estado != f.Estado:
+		return false
+	case !f.Desde.IsZero() && registro.Fecha.Before(f.Desde):
+		return false
+	case !f.Hasta.IsZero() && registro.Fecha.After(f.Hasta):
+		return false
+	}
+	return true
+}
+
+// AlmacenHistorial persiste el historial de envíos
+type AlmacenHistorial interface {
+	Registrar(registro RegistroEnvio) (RegistroEnvio, error)
+	// Buscar devuelve los registros que cumplen el filtro, del más reciente al más antiguo
+	Buscar(filtro FiltroHistorial) ([]RegistroEnvio, error)
+}
+
+// HistorialMemoria implementa AlmacenHistorial en memoria. Con capacidad positiva
+// conserva solo los registros más recientes.
+type HistorialMemoria struct {
+	mu        sync.RWMutex
+	registros []RegistroEnvio
+	capacidad int
+	proximoID int
+}
+
+func NuevoHistorialMemoria(capacidad int) *HistorialMemoria {
+	return &HistorialMemoria{
+		capacidad: capacidad,
+		proximoID: 1,
+	}
+}
+
+func (h *HistorialMemoria) Registrar(registro RegistroEnvio) (RegistroEnvio, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	registro.ID = h.proximoID
+	h.proximoID++
+	h.registros = append(h.registros, registro)
+
+	if h.capacidad > 0 && len(h.registros) > h.capacidad {
+		h.registros = append([]RegistroEnvio(nil), h.registros[len(h.registros)-h.capacidad:]...)
+	}
+
+	return registro, nil
+}
+
+func (h *HistorialMemoria) Buscar(filtro FiltroHistorial) ([]RegistroEnvio, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	registros := []RegistroEnvio{}
+	for i := len(h.registros) - 1; i >= 0; i-- {
+		if !filtro.Coincide(h.registros[i]) {
+			continue
+		}
+		registros = append(registros, h.registros[i])
+		if filtro.Limite > 0 && len(registros) == filtro.Limite {
+			break
+		}
+	}
+
+	return registros, nil
+}
+
+var _ AlmacenHistorial = &HistorialMemoria{}
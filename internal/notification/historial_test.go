@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServicioRegistraHistorialDeEnvios(t *testing.T) {
+	intentos := 0
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		intentos++
+		if intentos == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer servidor.Close()
+
+	historial := NuevoHistorialMemoria(0)
+	politica := PoliticaReintentosPorDefecto()
+	push := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "clave"}, ConReintentos(politica))
+	push.dormir = func(time.Duration) {}
+	fax := &canalSimulado{nombre: "fax", error: fmt.Errorf("sin papel")}
+
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalSMS),
+		ConCanal(push, true),
+		ConCanal(fax, true),
+		ConHistorial(historial),
+	)
+	servicio.Notificar(nuevaNotificacionPrueba(123))
+	servicio.Notificar(nuevaNotificacionPrueba(456))
+
+	registros, _ := historial.Buscar(FiltroHistorial{IDCompra: 123})
+	if len(registros) != 2 {
+		t.Fatalf("Se esperaban 2 registros para la compra 123, obtenidos: %d", len(registros))
+	}
+
+	// Los registros se devuelven del más reciente al más antiguo
+	registroFax, registroPush := registros[0], registros[1]
+	if registroPush.Canal != CanalPush || registroPush.Estado != EnvioExitoso || registroPush.Intentos != 2 || registroPush.Respuesta != "200 OK" {
+		t.Errorf("Registro push inesperado: %+v", registroPush)
+	}
+	if registroFax.Estado != EnvioFallido || registroFax.Error != "sin papel" || registroFax.Intentos != 1 {
+		t.Errorf("Registro fax inesperado: %+v", registroFax)
+	}
+	if registroPush.Destinatario != "cliente@ejemplo.com" || registroPush.Tipo != NotificacionCompraEnRuta {
+		t.Errorf("Datos de la notificación no registrados: %+v", registroPush)
+	}
+
+	fallidos, _ := historial.Buscar(FiltroHistorial{Estado: EnvioFallido})
+	if len(fallidos) != 2 {
+		t.Errorf("Se esperaban 2 envíos fallidos, obtenidos: %d", len(fallidos))
+	}
+
+	ultimos, _ := historial.Buscar(FiltroHistorial{Canal: CanalPush, Limite: 1})
+	if len(ultimos) != 1 || ultimos[0].IDCompra != 456 {
+		t.Errorf("Se esperaba el último envío push: %+v", ultimos)
+	}
+}
+
+func TestServicioRegistraEnviosOmitidos(t *testing.T) {
+	almacen := NuevoAlmacenContactosMemoria()
+	almacen.Guardar(Contacto{ID: "cliente@ejemplo.com", Email: "cliente@ejemplo.com", Canales: map[string]bool{CanalEmail: false}})
+
+	historial := NuevoHistorialMemoria(0)
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		ConCanal(&canalSimulado{nombre: CanalEmail}, true),
+		ConContactos(almacen),
+		ConHistorial(historial),
+	)
+	servicio.Notificar(nuevaNotificacionPrueba(1))
+
+	registros, _ := historial.Buscar(FiltroHistorial{})
+	if len(registros) != 1 || registros[0].Estado != EnvioOmitido || registros[0].Motivo == "" {
+		t.Errorf("Se esperaba un envío omitido con motivo: %+v", registros)
+	}
+}
+
+func TestServicioRegistraCadaCompraDeUnResumen(t *testing.T) {
+	historial := NuevoHistorialMemoria(0)
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalPush),
+		ConCanal(&canalSimulado{nombre: CanalEmail}, true),
+		ConHistorial(historial),
+	)
+	servicio.Notificar(Notificacion{
+		Tipo:         NotificacionResumen,
+		Destinatario: "despacho@ejemplo.com",
+		Resumen: []ElementoResumen{
+			{IDCompra: 123, Tipo: NotificacionCompraEntregada},
+			{IDCompra: 456, Tipo: NotificacionCompraEnRuta},
+		},
+	})
+
+	registros, _ := historial.Buscar(FiltroHistorial{IDCompra: 123})
+	if len(registros) != 1 {
+		t.Fatalf("Se esperaba un registro para la compra 123, obtenidos: %d", len(registros))
+	}
+	if registros[0].Tipo != NotificacionCompraEntregada || !registros[0].EnResumen || registros[0].Estado != EnvioExitoso {
+		t.Errorf("Registro de la compra incluida en el resumen inesperado: %+v", registros[0])
+	}
+
+	todos, _ := historial.Buscar(FiltroHistorial{})
+	if len(todos) != 2 {
+		t.Errorf("Se esperaba un registro por compra del resumen, obtenidos: %d", len(todos))
+	}
+}
+
+func TestHistorialMemoriaConservaLosMasRecientes(t *testing.T) {
+	historial := NuevoHistorialMemoria(2)
+	for i := 1; i <= 3; i++ {
+		historial.Registrar(RegistroEnvio{IDCompra: i})
+	}
+
+	registros, _ := historial.Buscar(FiltroHistorial{})
+	if len(registros) != 2 || registros[0].IDCompra != 3 || registros[1].IDCompra != 2 {
+		t.Errorf("Registros inesperados: %+v", registros)
+	}
+}
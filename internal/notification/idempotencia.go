@@ -0,0 +1,105 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ventanaDeduplicacionPorDefecto se usa cuando la configuración no define una ventana
+const ventanaDeduplicacionPorDefecto = 24 * time.Hour
+
+// ClaveIdempotencia identifica a la notificación por compra, tipo y destinatario.
+// Dos notificaciones con la misma clave se consideran el mismo mensaje. Los resúmenes
+// agregan las compras y tipos agrupados, para que solo se deduplique el mismo resumen.
+func (n Notificacion) ClaveIdempotencia() string {
+	clave := fmt.Sprintf("%d:%s:%s", n.IDCompra, n.Tipo, n.Destinatario)
+	if len(n.Resumen) == 0 {
+		return clave
+	}
+
+	elementos := make([]string, 0, len(n.Resumen))
+	for _, elemento := range n.Resumen {
+		elementos = append(elementos, fmt.Sprintf("%d/%s", elemento.IDCompra, elemento.Tipo))
+	}
+	return clave + ":" + strings.Join(elementos, ",")
+}
+
+// claveIdempotenciaCanal agrega el canal a la clave, para que un reintento
+// solo reenvíe por los canales que fallaron
+func claveIdempotenciaCanal(notificacion Notificacion, canal string) string {
+	return notificacion.ClaveIdempotencia() + ":" + canal
+}
+
+// AlmacenIdempotencia registra las claves ya enviadas durante la ventana de deduplicación.
+// Reservar marca la clave como en curso y devuelve false si ya fue enviada o la reservó otro envío;
+// Confirmar la fija hasta el vencimiento y Liberar la descarta cuando el envío falla.
+type AlmacenIdempotencia interface {
+	Reservar(clave string, hasta time.Time) (bool, error)
+	Confirmar(clave string, hasta time.Time) error
+	Liberar(clave string) error
+}
+
+type entradaIdempotencia struct {
+	confirmada bool
+	hasta      time.Time
+}
+
+// AlmacenIdempotenciaMemoria implementa AlmacenIdempotencia en memoria
+type AlmacenIdempotenciaMemoria struct {
+	mu     sync.Mutex
+	claves map[string]entradaIdempotencia
+	ahora  func() time.Time
+}
+
+func NuevoAlmacenIdempotenciaMemoria() *AlmacenIdempotenciaMemoria {
+	return &AlmacenIdempotenciaMemoria{
+		claves: make(map[string]entradaIdempotencia),
+		ahora:  time.Now,
+	}
+}
+
+func (a *AlmacenIdempotenciaMemoria) Reservar(clave string, hasta time.Time) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.purgar()
+
+	if _, existe := a.claves[clave]; existe {
+		return false, nil
+	}
+
+	a.claves[clave] = entradaIdempotencia{hasta: hasta}
+	return true, nil
+}
+
+func (a *AlmacenIdempotenciaMemoria) Confirmar(clave string, hasta time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.claves[clave] = entradaIdempotencia{confirmada: true, hasta: hasta}
+	return nil
+}
+
+func (a *AlmacenIdempotenciaMemoria) Liberar(clave string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if entrada, existe := a.claves[clave]; existe && !entrada.confirmada {
+		delete(a.claves, clave)
+	}
+	return nil
+}
+
+// purgar elimina las claves vencidas
+func (a *AlmacenIdempotenciaMemoria) purgar() {
+	ahora := a.ahora()
+	for clave, entrada := range a.claves {
+		if !entrada.hasta.After(ahora) {
+			delete(a.claves, clave)
+		}
+	}
+}
+
+var _ AlmacenIdempotencia = &AlmacenIdempotenciaMemoria{}
@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func nuevoServicioDeduplicado(ventana time.Duration, canales ...*canalSimulado) (*ServicioNotificaciones, *AlmacenIdempotenciaMemoria, *time.Time) {
+	ahora := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	almacen := NuevoAlmacenIdempotenciaMemoria()
+	almacen.ahora = func() time.Time { return ahora }
+
+	opciones := []OpcionServicio{SinCanal(CanalEmail), SinCanal(CanalPush), SinCanal(CanalSMS), ConDeduplicacion(almacen, ventana)}
+	for _, canal := range canales {
+		opciones = append(opciones, ConCanal(canal, true))
+	}
+
+	servicio := NuevoServicioNotificaciones(ConfiguracionNotificaciones{}, opciones...)
+	servicio.ahora = func() time.Time { return ahora }
+	return servicio, almacen, &ahora
+}
+
+func TestClaveIdempotencia(t *testing.T) {
+	notificacion := nuevaNotificacionPrueba(42)
+	otra := notificacion
+	otra.Descripcion = "Otra descripción"
+
+	if notificacion.ClaveIdempotencia() != otra.ClaveIdempotencia() {
+		t.Error("La descripción no debería cambiar la clave de idempotencia")
+	}
+
+	otra.Tipo = NotificacionCompraEnError
+	if notificacion.ClaveIdempotencia() == otra.ClaveIdempotencia() {
+		t.Error("Se esperaba una clave distinta para otro tipo de notificación")
+	}
+}
+
+func TestNotificarNoDuplicaDentroDeLaVentana(t *testing.T) {
+	fax := &canalSimulado{nombre: "fax"}
+	historial := NuevoHistorialMemoria(0)
+	servicio, _, ahora := nuevoServicioDeduplicado(time.Hour, fax)
+	ConHistorial(historial)(servicio)
+
+	notificacion := nuevaNotificacionPrueba(1)
+	for i := 0; i < 3; i++ {
+		if err := servicio.NotificarCambioEstadoCompra(notificacion.IDCompra, notificacion.Tipo, notificacion.Descripcion, notificacion.Destinatario); err != nil {
+			t.Fatalf("No se esperaba un error: %v", err)
+		}
+	}
+
+	if fax.enviado != 1 {
+		t.Errorf("Se esperaba 1 envío, obtenido %d", fax.enviado)
+	}
+
+	omitidos, _ := historial.Buscar(FiltroHistorial{Estado: EnvioOmitido})
+	if len(omitidos) != 2 {
+		t.Errorf("Se esperaban 2 envíos omitidos en el historial, obtenidos %d", len(omitidos))
+	}
+
+	*ahora = ahora.Add(time.Hour)
+	if err := servicio.Notificar(notificacion); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if fax.enviado != 2 {
+		t.Errorf("Se esperaba un nuevo envío al vencer la ventana, obtenidos %d", fax.enviado)
+	}
+}
+
+func TestNotificarReintentaSoloCanalesFallidos(t *testing.T) {
+	fax := &canalSimulado{nombre: "fax", error: fmt.Errorf("sin papel")}
+	paloma := &canalSimulado{nombre: "paloma"}
+	servicio, _, _ := nuevoServicioDeduplicado(time.Hour, fax, paloma)
+
+	notificacion := nuevaNotificacionPrueba(7)
+	if err := servicio.Notificar(notificacion); err == nil {
+		t.Fatal("Se esperaba un error por el canal fax")
+	}
+
+	fax.error = nil
+	if err := servicio.Notificar(notificacion); err != nil {
+		t.Fatalf("No se esperaba un error en el reintento: %v", err)
+	}
+
+	if fax.enviado != 2 {
+		t.Errorf("Se esperaba que el reintento enviara por fax, envíos: %d", fax.enviado)
+	}
+	if paloma.enviado != 1 {
+		t.Errorf("No se esperaba reenviar por paloma, envíos: %d", paloma.enviado)
+	}
+}
+
+func TestAlmacenIdempotenciaReservaUnaVez(t *testing.T) {
+	almacen := NuevoAlmacenIdempotenciaMemoria()
+	hasta := time.Now().Add(time.Minute)
+
+	if reservada, _ := almacen.Reservar("clave", hasta); !reservada {
+		t.Fatal("Se esperaba reservar la clave")
+	}
+	if reservada, _ := almacen.Reservar("clave", hasta); reservada {
+		t.Error("No se esperaba reservar una clave en curso")
+	}
+
+	almacen.Liberar("clave")
+	if reservada, _ := almacen.Reservar("clave", hasta); !reservada {
+		t.Error("Se esperaba reservar la clave liberada")
+	}
+
+	almacen.Confirmar("clave", hasta)
+	almacen.Liberar("clave")
+	if reservada, _ := almacen.Reservar("clave", hasta); reservada {
+		t.Error("Liberar no debería descartar una clave confirmada")
+	}
+}
@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// IdiomaPorDefecto es el último idioma de la cadena de respaldo
+const IdiomaPorDefecto = "es"
+
+// Idiomas con catálogo de mensajes incluido
+const (
+	IdiomaEspanol   = "es"
+	IdiomaPortugues = "pt"
+	IdiomaIngles    = "en"
+)
+
+// NormalizarIdioma lleva una etiqueta de idioma a minúsculas con guion, por ejemplo "pt_BR" a "pt-br"
+func NormalizarIdioma(idioma string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(idioma), "_", "-"))
+}
+
+// CadenaIdiomas devuelve los idiomas a consultar en orden: la etiqueta completa,
+// sus prefijos más generales y por último el idioma por defecto. "pt-BR" da ["pt-br", "pt", "es"].
+func CadenaIdiomas(idioma string) []string {
+	var cadena []string
+	for etiqueta := NormalizarIdioma(idioma); etiqueta != ""; {
+		cadena = append(cadena, etiqueta)
+		i := strings.LastIndex(etiqueta, "-")
+		if i < 0 {
+			break
+		}
+		etiqueta = etiqueta[:i]
+	}
+
+	for _, etiqueta := range cadena {
+		if etiqueta == IdiomaPorDefecto {
+			return cadena
+		}
+	}
+	return append(cadena, IdiomaPorDefecto)
+}
+
+// Catalogo contiene los mensajes de cada idioma indexados por clave
+type Catalogo struct {
+	mensajes map[string]map[string]string
+}
+
+// Traducir busca la clave siguiendo la cadena de respaldo del idioma y aplica los argumentos
+// con el formato de fmt. Si ningún idioma define la clave se devuelve la clave misma.
+func (c *Catalogo) Traducir(idioma, clave string, args ...interface{}) string {
+	for _, etiqueta := range CadenaIdiomas(idioma) {
+		if mensaje, ok := c.mensajes[etiqueta][clave]; ok {
+			if len(args) == 0 {
+				return mensaje
+			}
+			return fmt.Sprintf(mensaje, args...)
+		}
+	}
+	return clave
+}
+
+// Idiomas devuelve los idiomas con mensajes en el catálogo
+func (c *Catalogo) Idiomas() []string {
+	idiomas := make([]string, 0, len(c.mensajes))
+	for idioma := range c.mensajes {
+		idiomas = append(idiomas, idioma)
+	}
+	return idiomas
+}
+
+// resolverIdioma devuelve el primer idioma de la cadena con catálogo propio
+func (c *Catalogo) resolverIdioma(idioma string) string {
+	for _, etiqueta := range CadenaIdiomas(idioma) {
+		if _, ok := c.mensajes[etiqueta]; ok {
+			return etiqueta
+		}
+	}
+	return IdiomaPorDefecto
+}
+
+// leerCatalogo agrupa los archivos mensajes.<idioma>.json de un directorio por idioma
+func leerCatalogo(sistema fs.FS, raiz string) (map[string]map[string]string, error) {
+	archivos, err := fs.Glob(sistema, path.Join(raiz, "mensajes.*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	mensajes := make(map[string]map[string]string)
+	for _, archivo := range archivos {
+		idioma := NormalizarIdioma(strings.TrimSuffix(strings.TrimPrefix(path.Base(archivo), "mensajes."), ".json"))
+
+		contenido, err := fs.ReadFile(sistema, archivo)
+		if err != nil {
+			return nil, err
+		}
+
+		var claves map[string]string
+		if err := json.Unmarshal(contenido, &claves); err != nil {
+			return nil, fmt.Errorf("catálogo %s inválido: %w", path.Base(archivo), err)
+		}
+		mensajes[idioma] = claves
+	}
+
+	return mensajes, nil
+}
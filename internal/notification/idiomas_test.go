@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCadenaIdiomas(t *testing.T) {
+	casos := map[string][]string{
+		"":      {"es"},
+		"es":    {"es"},
+		"en":    {"en", "es"},
+		"pt_BR": {"pt-br", "pt", "es"},
+		"es-AR": {"es-ar", "es"},
+	}
+
+	for idioma, esperado := range casos {
+		if obtenido := CadenaIdiomas(idioma); !reflect.DeepEqual(obtenido, esperado) {
+			t.Errorf("CadenaIdiomas(%q) = %v, se esperaba %v", idioma, obtenido, esperado)
+		}
+	}
+}
+
+func TestTraducirUsaCadenaDeRespaldo(t *testing.T) {
+	catalogo := &Catalogo{mensajes: map[string]map[string]string{
+		"es":    {"saludo": "Hola,", "despedida": "Saludos"},
+		"pt":    {"saludo": "Olá,"},
+		"pt-br": {"saludo": "Oi,"},
+	}}
+
+	casos := []struct {
+		idioma, clave, esperado string
+	}{
+		{"pt-BR", "saludo", "Oi,"},
+		{"pt-PT", "saludo", "Olá,"},
+		{"pt-BR", "despedida", "Saludos"},
+		{"fr", "saludo", "Hola,"},
+		{"en", "inexistente", "inexistente"},
+	}
+
+	for _, c := range casos {
+		if obtenido := catalogo.Traducir(c.idioma, c.clave); obtenido != c.esperado {
+			t.Errorf("Traducir(%q, %q) = %q, se esperaba %q", c.idioma, c.clave, obtenido, c.esperado)
+		}
+	}
+}
+
+func TestRenderizarEnIdiomaDelDestinatario(t *testing.T) {
+	motor := NuevoMotorPlantillas()
+	notificacion := nuevaNotificacionConRuta()
+
+	casos := []struct {
+		idioma, asunto, saludo string
+	}{
+		{"", "Tu compra #42 está en camino", "Hola,"},
+		{"pt-BR", "Sua compra #42 está a caminho", "Olá,"},
+		{"en", "Your order #42 is on its way", "Hello,"},
+		{"fr", "Tu compra #42 está en camino", "Hola,"},
+	}
+
+	for _, c := range casos {
+		notificacion.Idioma = c.idioma
+		renderizado, err := motor.Renderizar(notificacion)
+		if err != nil {
+			t.Fatalf("No se esperaba un error para %q: %v", c.idioma, err)
+		}
+		if renderizado.Asunto != c.asunto {
+			t.Errorf("Asunto en %q = %q, se esperaba %q", c.idioma, renderizado.Asunto, c.asunto)
+		}
+		if !strings.HasPrefix(renderizado.Texto, c.saludo) || !strings.Contains(renderizado.HTML, c.saludo) {
+			t.Errorf("Saludo en %q incorrecto: %q", c.idioma, renderizado.Texto)
+		}
+	}
+}
+
+func TestCatalogoDesdeDirectorio(t *testing.T) {
+	directorio := t.TempDir()
+	contenido := `{"asunto.COMPRA_EN_RUTA": "Sua encomenda #%d saiu para entrega"}`
+	if err := os.WriteFile(filepath.Join(directorio, "mensajes.pt-BR.json"), []byte(contenido), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	motor, err := CargarMotorPlantillas(directorio)
+	if err != nil {
+		t.Fatalf("No se esperaba un error al cargar: %v", err)
+	}
+
+	notificacion := nuevaNotificacionConRuta()
+	notificacion.Idioma = "pt-BR"
+	renderizado, _ := motor.Renderizar(notificacion)
+	if renderizado.Asunto != "Sua encomenda #42 saiu para entrega" {
+		t.Errorf("Asunto inesperado: %q", renderizado.Asunto)
+	}
+	// Las claves que el catálogo regional no define se toman de "pt"
+	if !strings.HasPrefix(renderizado.Texto, "Olá,") {
+		t.Errorf("Se esperaba el saludo en portugués: %q", renderizado.Texto)
+	}
+
+	if err := os.WriteFile(filepath.Join(directorio, "mensajes.en.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := motor.Recargar(); err == nil {
+		t.Error("Se esperaba un error al recargar un catálogo inválido")
+	}
+}
@@ -0,0 +1,104 @@
+package notificationtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// NuevoManejadorAdministracion expone los servidores simulados por HTTP para usarlos
+// fuera de los tests, por ejemplo desde el comando proveedores-prueba:
+//
+//	GET    /smtp/mensajes     mensajes capturados
+//	DELETE /smtp/mensajes     descarta mensajes, fallas y retardo
+//	POST   /smtp/fallas       programa una FallaSMTP
+//	PUT    /smtp/retardo?duracion=2s
+//	GET    /push/solicitudes  solicitudes capturadas
+//	DELETE /push/solicitudes  descarta solicitudes, fallas y retardo
+//	POST   /push/fallas       programa una FallaPush
+//	PUT    /push/retardo?duracion=2s
+func NuevoManejadorAdministracion(smtp *ServidorSMTP, push *ServidorPush) http.Handler {
+	mux := http.NewServeMux()
+
+	if smtp != nil {
+		mux.HandleFunc("/smtp/mensajes", func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				escribirJSON(w, http.StatusOK, smtp.Mensajes())
+			case http.MethodDelete:
+				smtp.Limpiar()
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		})
+		mux.HandleFunc("/smtp/fallas", func(w http.ResponseWriter, r *http.Request) {
+			var falla FallaSMTP
+			if !leerJSON(w, r, &falla) {
+				return
+			}
+			smtp.ProgramarFalla(falla)
+			w.WriteHeader(http.StatusNoContent)
+		})
+		mux.HandleFunc("/smtp/retardo", manejarRetardo(smtp.FijarRetardo))
+	}
+
+	if push != nil {
+		mux.HandleFunc("/push/solicitudes", func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				escribirJSON(w, http.StatusOK, push.Solicitudes())
+			case http.MethodDelete:
+				push.Limpiar()
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		})
+		mux.HandleFunc("/push/fallas", func(w http.ResponseWriter, r *http.Request) {
+			var falla FallaPush
+			if !leerJSON(w, r, &falla) {
+				return
+			}
+			push.ProgramarFalla(falla)
+			w.WriteHeader(http.StatusNoContent)
+		})
+		mux.HandleFunc("/push/retardo", manejarRetardo(push.FijarRetardo))
+	}
+
+	return mux
+}
+
+func manejarRetardo(fijar func(time.Duration)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		retardo, err := time.ParseDuration(r.URL.Query().Get("duracion"))
+		if err != nil {
+			escribirJSON(w, http.StatusBadRequest, map[string]string{"error": "duración inválida"})
+			return
+		}
+		fijar(retardo)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func leerJSON(w http.ResponseWriter, r *http.Request, destino interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(destino); err != nil {
+		escribirJSON(w, http.StatusBadRequest, map[string]string{"error": "cuerpo inválido"})
+		return false
+	}
+	return true
+}
+
+func escribirJSON(w http.ResponseWriter, estado int, valor interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(valor)
+}
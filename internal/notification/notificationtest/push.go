@@ -0,0 +1,220 @@
+package notificationtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SolicitudPush es una solicitud recibida por el servidor push simulado
+type SolicitudPush struct {
+	Metodo      string      `json:"metodo"`
+	Ruta        string      `json:"ruta"`
+	Encabezados http.Header `json:"encabezados"`
+	Cuerpo      string      `json:"cuerpo"`
+	Fecha       time.Time   `json:"fecha"`
+}
+
+// Decodificar interpreta el cuerpo JSON de la solicitud
+func (s SolicitudPush) Decodificar(destino interface{}) error {
+	return json.Unmarshal([]byte(s.Cuerpo), destino)
+}
+
+// FallaPush programa una respuesta de error. Veces cero falla hasta que se limpien las fallas;
+// RetryAfter se envía en el encabezado del mismo nombre, en segundos.
+type FallaPush struct {
+	Codigo     int    `json:"codigo"`
+	Cuerpo     string `json:"cuerpo"`
+	RetryAfter int    `json:"retry_after"`
+	Veces      int    `json:"veces"`
+}
+
+// ServidorPush es una API push simulada que acepta solicitudes POST en cualquier ruta,
+// las guarda en memoria y responde 200 con un cuerpo JSON vacío salvo que se programe una falla
+type ServidorPush struct {
+	claveAPI string
+	servidor *http.Server
+	listener net.Listener
+
+	mu          sync.Mutex
+	solicitudes []SolicitudPush
+	fallas      []FallaPush
+	retardo     time.Duration
+	recibida    chan struct{}
+}
+
+// IniciarServidorPush escucha en la dirección indicada, o en un puerto libre de 127.0.0.1
+// si está vacía. Con claveAPI se exige el encabezado "Authorization: Bearer <clave>";
+// las fallas programadas se aplican después de verificar la clave.
+func IniciarServidorPush(direccion, claveAPI string) (*ServidorPush, error) {
+	if direccion == "" {
+		direccion = "127.0.0.1:0"
+	}
+
+	listener, err := net.Listen("tcp", direccion)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ServidorPush{
+		claveAPI: claveAPI,
+		listener: listener,
+		recibida: make(chan struct{}, 1),
+	}
+	s.servidor = &http.Server{Handler: s}
+	go s.servidor.Serve(listener)
+
+	return s, nil
+}
+
+// URL devuelve la URL base del servidor
+func (s *ServidorPush) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// Solicitudes devuelve una copia de las solicitudes recibidas, incluidas las respondidas con error
+func (s *ServidorPush) Solicitudes() []SolicitudPush {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SolicitudPush(nil), s.solicitudes...)
+}
+
+// EsperarSolicitudes espera hasta tener al menos n solicitudes o hasta que venza el timeout
+func (s *ServidorPush) EsperarSolicitudes(n int, timeout time.Duration) ([]SolicitudPush, error) {
+	limite := time.After(timeout)
+	for {
+		if solicitudes := s.Solicitudes(); len(solicitudes) >= n {
+			return solicitudes, nil
+		}
+		select {
+		case <-s.recibida:
+		case <-limite:
+			solicitudes := s.Solicitudes()
+			return solicitudes, fmt.Errorf("se esperaban %d solicitudes, recibidas %d", n, len(solicitudes))
+		}
+	}
+}
+
+// ProgramarFalla agrega una respuesta de error para las próximas solicitudes
+func (s *ServidorPush) ProgramarFalla(falla FallaPush) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallas = append(s.fallas, falla)
+}
+
+// FijarRetardo demora cada respuesta, para simular un proveedor lento o probar timeouts
+func (s *ServidorPush) FijarRetardo(retardo time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retardo = retardo
+}
+
+// Limpiar descarta las solicitudes capturadas, las fallas programadas y el retardo
+func (s *ServidorPush) Limpiar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.solicitudes = nil
+	s.fallas = nil
+	s.retardo = 0
+}
+
+// Cerrar detiene el servidor
+func (s *ServidorPush) Cerrar() error {
+	return s.servidor.Close()
+}
+
+func (s *ServidorPush) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	cuerpo, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	valida := s.claveAPI == "" || autorizado(r.Header.Get("Authorization"), s.claveAPI)
+
+	s.mu.Lock()
+	s.solicitudes = append(s.solicitudes, SolicitudPush{
+		Metodo:      r.Method,
+		Ruta:        r.URL.Path,
+		Encabezados: r.Header.Clone(),
+		Cuerpo:      string(cuerpo),
+		Fecha:       time.Now(),
+	})
+	retardo := s.retardo
+	var falla *FallaPush
+	if valida {
+		falla = s.tomarFalla()
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.recibida <- struct{}{}:
+	default:
+	}
+
+	if retardo > 0 {
+		select {
+		case <-time.After(retardo):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !valida {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":"clave de API inválida"}`)
+		return
+	}
+
+	if falla != nil {
+		if falla.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(falla.RetryAfter))
+		}
+		w.WriteHeader(falla.Codigo)
+		io.WriteString(w, falla.Cuerpo)
+		return
+	}
+
+	io.WriteString(w, "{}")
+}
+
+// autorizado compara el token Bearer sin distinguir mayúsculas en el esquema, como lo envía APNs
+func autorizado(encabezado, clave string) bool {
+	esquema, token, ok := strings.Cut(encabezado, " ")
+	return ok && strings.EqualFold(esquema, "Bearer") && token == clave
+}
+
+// tomarFalla devuelve la próxima falla programada y descuenta su uso; requiere s.mu tomado
+func (s *ServidorPush) tomarFalla() *FallaPush {
+	if len(s.fallas) == 0 {
+		return nil
+	}
+
+	falla := s.fallas[0]
+	if falla.Veces > 0 {
+		s.fallas[0].Veces--
+		if s.fallas[0].Veces == 0 {
+			s.fallas = s.fallas[1:]
+		}
+	}
+	if falla.Codigo == 0 {
+		falla.Codigo = http.StatusInternalServerError
+	}
+	if strings.TrimSpace(falla.Cuerpo) == "" {
+		falla.Cuerpo = `{"error":"falla simulada"}`
+	}
+	return &falla
+}
@@ -0,0 +1,528 @@
+// Package notificationtest provee servidores SMTP y push simulados que capturan los mensajes
+// recibidos y pueden programarse para fallar o demorar, para probar los canales de
+// notificación de punta a punta sin proveedores reales.
+package notificationtest
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigServidorSMTP define cómo escucha el servidor SMTP simulado. Con Usuario se exige
+// autenticación (PLAIN, LOGIN o CRAM-MD5) antes de aceptar mensajes.
+type ConfigServidorSMTP struct {
+	// Direccion por defecto es 127.0.0.1 en un puerto libre
+	Direccion string
+	// TLS acepta conexiones directamente sobre TLS; STARTTLS ofrece pasar a TLS tras el saludo
+	TLS      bool
+	STARTTLS bool
+	Usuario  string
+	Clave    string
+}
+
+// MensajeSMTP es un mensaje aceptado por el servidor simulado
+type MensajeSMTP struct {
+	Remitente     string    `json:"remitente"`
+	Destinatarios []string  `json:"destinatarios"`
+	Datos         string    `json:"datos"`
+	Usuario       string    `json:"usuario,omitempty"`
+	TLS           bool      `json:"tls"`
+	Fecha         time.Time `json:"fecha"`
+}
+
+// Encabezados interpreta los encabezados del mensaje
+func (m MensajeSMTP) Encabezados() (mail.Header, error) {
+	mensaje, err := mail.ReadMessage(strings.NewReader(m.Datos))
+	if err != nil {
+		return nil, err
+	}
+	return mensaje.Header, nil
+}
+
+// Asunto devuelve el asunto decodificado
+func (m MensajeSMTP) Asunto() string {
+	encabezados, err := m.Encabezados()
+	if err != nil {
+		return ""
+	}
+	asunto, err := new(mime.WordDecoder).DecodeHeader(encabezados.Get("Subject"))
+	if err != nil {
+		return encabezados.Get("Subject")
+	}
+	return asunto
+}
+
+// FallaSMTP programa una respuesta de error para un comando. Comando "DATA" responde al
+// terminar el contenido del mensaje; Veces cero falla hasta que se limpien las fallas.
+type FallaSMTP struct {
+	Comando string `json:"comando"`
+	Codigo  int    `json:"codigo"`
+	Mensaje string `json:"mensaje"`
+	Veces   int    `json:"veces"`
+}
+
+// ServidorSMTP es un servidor SMTP simulado que guarda los mensajes en memoria
+type ServidorSMTP struct {
+	config      ConfigServidorSMTP
+	listener    net.Listener
+	certificado tls.Certificate
+	pemCA       []byte
+	archivoCA   string
+
+	mu         sync.Mutex
+	mensajes   []MensajeSMTP
+	fallas     []FallaSMTP
+	retardo    time.Duration
+	conexiones int
+	activas    map[net.Conn]struct{}
+	recibido   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// IniciarServidorSMTP escucha en la dirección configurada y atiende conexiones en segundo plano
+func IniciarServidorSMTP(config ConfigServidorSMTP) (*ServidorSMTP, error) {
+	if config.Direccion == "" {
+		config.Direccion = "127.0.0.1:0"
+	}
+
+	certificado, pemCA, err := certificadoAutofirmado()
+	if err != nil {
+		return nil, fmt.Errorf("error al generar el certificado: %w", err)
+	}
+
+	directorio, err := os.MkdirTemp("", "notificationtest")
+	if err != nil {
+		return nil, err
+	}
+	archivoCA := filepath.Join(directorio, "ca.pem")
+	if err := os.WriteFile(archivoCA, pemCA, 0o600); err != nil {
+		os.RemoveAll(directorio)
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", config.Direccion)
+	if err != nil {
+		os.RemoveAll(directorio)
+		return nil, err
+	}
+	if config.TLS {
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificado}})
+	}
+
+	s := &ServidorSMTP{
+		config:      config,
+		listener:    listener,
+		certificado: certificado,
+		pemCA:       pemCA,
+		archivoCA:   archivoCA,
+		activas:     make(map[net.Conn]struct{}),
+		recibido:    make(chan struct{}, 1),
+	}
+	s.wg.Add(1)
+	go s.aceptar()
+
+	return s, nil
+}
+
+// Host devuelve la dirección IP en la que escucha el servidor
+func (s *ServidorSMTP) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Puerto devuelve el puerto en el que escucha el servidor
+func (s *ServidorSMTP) Puerto() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Direccion devuelve host:puerto
+func (s *ServidorSMTP) Direccion() string {
+	return s.listener.Addr().String()
+}
+
+// CertificadoPEM devuelve el certificado autofirmado del servidor, para confiar en él como CA
+func (s *ServidorSMTP) CertificadoPEM() []byte {
+	return s.pemCA
+}
+
+// ArchivoCA devuelve la ruta de un archivo PEM con el certificado del servidor
+func (s *ServidorSMTP) ArchivoCA() string {
+	return s.archivoCA
+}
+
+// Mensajes devuelve una copia de los mensajes recibidos
+func (s *ServidorSMTP) Mensajes() []MensajeSMTP {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MensajeSMTP(nil), s.mensajes...)
+}
+
+// EsperarMensajes espera hasta tener al menos n mensajes o hasta que venza el timeout
+func (s *ServidorSMTP) EsperarMensajes(n int, timeout time.Duration) ([]MensajeSMTP, error) {
+	limite := time.After(timeout)
+	for {
+		if mensajes := s.Mensajes(); len(mensajes) >= n {
+			return mensajes, nil
+		}
+		select {
+		case <-s.recibido:
+		case <-limite:
+			mensajes := s.Mensajes()
+			return mensajes, fmt.Errorf("se esperaban %d mensajes, recibidos %d", n, len(mensajes))
+		}
+	}
+}
+
+// Conexiones devuelve la cantidad de conexiones aceptadas
+func (s *ServidorSMTP) Conexiones() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conexiones
+}
+
+// ProgramarFalla agrega una respuesta de error para el comando indicado
+func (s *ServidorSMTP) ProgramarFalla(falla FallaSMTP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	falla.Comando = strings.ToUpper(falla.Comando)
+	if falla.Mensaje == "" {
+		falla.Mensaje = "falla simulada"
+	}
+	s.fallas = append(s.fallas, falla)
+}
+
+// FijarRetardo demora la respuesta a cada mensaje recibido, para simular un servidor lento
+func (s *ServidorSMTP) FijarRetardo(retardo time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retardo = retardo
+}
+
+// Limpiar descarta los mensajes capturados, las fallas programadas y el retardo
+func (s *ServidorSMTP) Limpiar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mensajes = nil
+	s.fallas = nil
+	s.retardo = 0
+}
+
+// Cerrar deja de aceptar conexiones, corta las abiertas y elimina el archivo de la CA
+func (s *ServidorSMTP) Cerrar() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.activas {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	os.RemoveAll(filepath.Dir(s.archivoCA))
+	return err
+}
+
+func (s *ServidorSMTP) aceptar() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conexiones++
+		s.activas[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.atender(conn)
+
+			s.mu.Lock()
+			delete(s.activas, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// tomarFalla devuelve la falla programada para el comando y descuenta su uso
+func (s *ServidorSMTP) tomarFalla(comando string) *FallaSMTP {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, falla := range s.fallas {
+		if falla.Comando != comando {
+			continue
+		}
+		if falla.Veces > 0 {
+			s.fallas[i].Veces--
+			if s.fallas[i].Veces == 0 {
+				s.fallas = append(s.fallas[:i], s.fallas[i+1:]...)
+			}
+		}
+		return &falla
+	}
+	return nil
+}
+
+func (s *ServidorSMTP) registrar(mensaje MensajeSMTP) {
+	s.mu.Lock()
+	s.mensajes = append(s.mensajes, mensaje)
+	s.mu.Unlock()
+
+	select {
+	case s.recibido <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ServidorSMTP) retardoActual() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retardo
+}
+
+// sesionSMTP es el estado de una conexión
+type sesionSMTP struct {
+	conn        net.Conn
+	lector      *bufio.Reader
+	tls         bool
+	usuario     string
+	autenticado bool
+	transaccion *MensajeSMTP
+}
+
+func (ses *sesionSMTP) responder(codigo int, lineas ...string) {
+	if len(lineas) == 0 {
+		lineas = []string{"ok"}
+	}
+	for i, linea := range lineas {
+		separador := " "
+		if i < len(lineas)-1 {
+			separador = "-"
+		}
+		fmt.Fprintf(ses.conn, "%d%s%s\r\n", codigo, separador, linea)
+	}
+}
+
+func (ses *sesionSMTP) leerLinea() (string, error) {
+	linea, err := ses.lector.ReadString('\n')
+	return strings.TrimRight(linea, "\r\n"), err
+}
+
+func (s *ServidorSMTP) atender(conn net.Conn) {
+	ses := &sesionSMTP{conn: conn, lector: bufio.NewReader(conn), tls: s.config.TLS}
+	defer func() { ses.conn.Close() }()
+
+	ses.responder(220, "localhost ESMTP notificationtest")
+
+	for {
+		linea, err := ses.leerLinea()
+		if err != nil {
+			return
+		}
+
+		comando, argumento := linea, ""
+		if i := strings.IndexByte(linea, ' '); i >= 0 {
+			comando, argumento = linea[:i], strings.TrimSpace(linea[i+1:])
+		}
+		comando = strings.ToUpper(comando)
+
+		if comando != "DATA" {
+			if falla := s.tomarFalla(comando); falla != nil {
+				ses.responder(falla.Codigo, falla.Mensaje)
+				continue
+			}
+		}
+
+		switch comando {
+		case "EHLO", "HELO":
+			ses.transaccion = nil
+			ses.responder(250, s.extensiones(ses)...)
+		case "STARTTLS":
+			if !s.config.STARTTLS || ses.tls {
+				ses.responder(502, "STARTTLS no disponible")
+				continue
+			}
+			ses.responder(220, "listo para TLS")
+			conexionTLS := tls.Server(ses.conn, &tls.Config{Certificates: []tls.Certificate{s.certificado}})
+			if err := conexionTLS.Handshake(); err != nil {
+				return
+			}
+			ses.conn, ses.lector, ses.tls = conexionTLS, bufio.NewReader(conexionTLS), true
+			ses.transaccion, ses.autenticado, ses.usuario = nil, false, ""
+		case "AUTH":
+			s.autenticar(ses, argumento)
+		case "MAIL":
+			if s.config.Usuario != "" && !ses.autenticado {
+				ses.responder(530, "autenticación requerida")
+				continue
+			}
+			ses.transaccion = &MensajeSMTP{Remitente: direccionComando(argumento), Usuario: ses.usuario, TLS: ses.tls}
+			ses.responder(250)
+		case "RCPT":
+			if ses.transaccion == nil {
+				ses.responder(503, "falta MAIL")
+				continue
+			}
+			ses.transaccion.Destinatarios = append(ses.transaccion.Destinatarios, direccionComando(argumento))
+			ses.responder(250)
+		case "DATA":
+			if ses.transaccion == nil || len(ses.transaccion.Destinatarios) == 0 {
+				ses.responder(503, "falta RCPT")
+				continue
+			}
+			ses.responder(354, "terminar con <CRLF>.<CRLF>")
+			datos, err := leerDatos(ses)
+			if err != nil {
+				return
+			}
+			if retardo := s.retardoActual(); retardo > 0 {
+				time.Sleep(retardo)
+			}
+			if falla := s.tomarFalla("DATA"); falla != nil {
+				ses.transaccion = nil
+				ses.responder(falla.Codigo, falla.Mensaje)
+				continue
+			}
+			ses.transaccion.Datos = datos
+			ses.transaccion.Fecha = time.Now()
+			s.registrar(*ses.transaccion)
+			ses.transaccion = nil
+			ses.responder(250, "mensaje aceptado")
+		case "RSET":
+			ses.transaccion = nil
+			ses.responder(250)
+		case "NOOP":
+			ses.responder(250)
+		case "QUIT":
+			ses.responder(221, "adiós")
+			return
+		default:
+			ses.responder(502, "comando no implementado")
+		}
+	}
+}
+
+func (s *ServidorSMTP) extensiones(ses *sesionSMTP) []string {
+	extensiones := []string{"localhost", "8BITMIME"}
+	if s.config.STARTTLS && !ses.tls {
+		extensiones = append(extensiones, "STARTTLS")
+	}
+	if s.config.Usuario != "" {
+		extensiones = append(extensiones, "AUTH PLAIN LOGIN CRAM-MD5")
+	}
+	return extensiones
+}
+
+// autenticar resuelve los mecanismos PLAIN, LOGIN y CRAM-MD5
+func (s *ServidorSMTP) autenticar(ses *sesionSMTP, argumento string) {
+	partes := strings.Fields(argumento)
+	if len(partes) == 0 {
+		ses.responder(501, "falta el mecanismo")
+		return
+	}
+
+	var usuario, clave string
+	var valido bool
+
+	switch strings.ToUpper(partes[0]) {
+	case "PLAIN":
+		respuesta := ""
+		if len(partes) > 1 {
+			respuesta = partes[1]
+		} else {
+			ses.responder(334, "")
+			respuesta, _ = ses.leerLinea()
+		}
+		decodificada, err := base64.StdEncoding.DecodeString(respuesta)
+		campos := strings.Split(string(decodificada), "\x00")
+		if err != nil || len(campos) != 3 {
+			ses.responder(501, "credenciales mal formadas")
+			return
+		}
+		usuario, clave = campos[1], campos[2]
+		valido = usuario == s.config.Usuario && clave == s.config.Clave
+
+	case "LOGIN":
+		ses.responder(334, base64.StdEncoding.EncodeToString([]byte("Username:")))
+		usuario, _ = leerBase64(ses)
+		ses.responder(334, base64.StdEncoding.EncodeToString([]byte("Password:")))
+		clave, _ = leerBase64(ses)
+		valido = usuario == s.config.Usuario && clave == s.config.Clave
+
+	case "CRAM-MD5":
+		desafio := fmt.Sprintf("<%d@localhost>", time.Now().UnixNano())
+		ses.responder(334, base64.StdEncoding.EncodeToString([]byte(desafio)))
+		respuesta, _ := leerBase64(ses)
+		campos := strings.Fields(respuesta)
+		if len(campos) != 2 {
+			ses.responder(501, "respuesta mal formada")
+			return
+		}
+		usuario = campos[0]
+		firma := hmac.New(md5.New, []byte(s.config.Clave))
+		firma.Write([]byte(desafio))
+		valido = usuario == s.config.Usuario && hmac.Equal([]byte(campos[1]), []byte(hex.EncodeToString(firma.Sum(nil))))
+
+	default:
+		ses.responder(504, "mecanismo no soportado")
+		return
+	}
+
+	if !valido {
+		ses.responder(535, "credenciales inválidas")
+		return
+	}
+	ses.usuario, ses.autenticado = usuario, true
+	ses.responder(235, "autenticado")
+}
+
+func leerBase64(ses *sesionSMTP) (string, error) {
+	linea, err := ses.leerLinea()
+	if err != nil {
+		return "", err
+	}
+	decodificada, err := base64.StdEncoding.DecodeString(linea)
+	return string(decodificada), err
+}
+
+// leerDatos lee el contenido hasta la línea con un punto y quita el relleno de puntos
+func leerDatos(ses *sesionSMTP) (string, error) {
+	var datos strings.Builder
+	for {
+		linea, err := ses.leerLinea()
+		if err != nil {
+			return "", err
+		}
+		if linea == "." {
+			return datos.String(), nil
+		}
+		datos.WriteString(strings.TrimPrefix(linea, "."))
+		datos.WriteString("\r\n")
+	}
+}
+
+// direccionComando extrae la dirección de "FROM:<x>" o "TO:<x>"
+func direccionComando(argumento string) string {
+	if i := strings.IndexByte(argumento, ':'); i >= 0 {
+		argumento = argumento[i+1:]
+	}
+	if i := strings.IndexByte(argumento, ' '); i >= 0 {
+		argumento = argumento[:i]
+	}
+	return strings.Trim(argumento, "<>")
+}
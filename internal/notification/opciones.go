@@ -0,0 +1,53 @@
+package notification
+
+import "time"
+
+// opcionesEnvio agrupa la configuración de resiliencia y de contenido de un canal
+type opcionesEnvio struct {
+	reintentos PoliticaReintentos
+	disyuntor  ConfigDisyuntor
+	dormir     func(time.Duration)
+	plantillas *MotorPlantillas
+}
+
+// OpcionEnvio configura la política de reintentos, el disyuntor y las plantillas de un canal
+type OpcionEnvio func(*opcionesEnvio)
+
+// ConReintentos reemplaza la política de reintentos por defecto
+func ConReintentos(politica PoliticaReintentos) OpcionEnvio {
+	return func(o *opcionesEnvio) {
+		o.reintentos = politica
+	}
+}
+
+// ConDisyuntor reemplaza la configuración del disyuntor por defecto
+func ConDisyuntor(config ConfigDisyuntor) OpcionEnvio {
+	return func(o *opcionesEnvio) {
+		o.disyuntor = config
+	}
+}
+
+// ConPlantillas define el motor de plantillas usado para construir los mensajes
+func ConPlantillas(motor *MotorPlantillas) OpcionEnvio {
+	return func(o *opcionesEnvio) {
+		o.plantillas = motor
+	}
+}
+
+func nuevasOpcionesEnvio(opciones []OpcionEnvio) opcionesEnvio {
+	o := opcionesEnvio{
+		reintentos: PoliticaReintentosPorDefecto(),
+		disyuntor:  ConfigDisyuntorPorDefecto(),
+		dormir:     time.Sleep,
+	}
+
+	for _, opcion := range opciones {
+		opcion(&o)
+	}
+
+	if o.plantillas == nil {
+		o.plantillas = NuevoMotorPlantillas()
+	}
+
+	return o
+}
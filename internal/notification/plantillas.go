@@ -0,0 +1,252 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// plantillasPorDefecto contiene las plantillas y los catálogos de mensajes incluidos en el binario
+//
+//go:embed plantillas/*.tmpl plantillas/*.json
+var plantillasPorDefecto embed.FS
+
+// Variantes de cada plantilla. Los archivos se nombran <TIPO>.<variante>.tmpl,
+// por ejemplo COMPRA_ENTREGADA.html.tmpl; "default" se usa para los tipos sin plantilla propia.
+const (
+	varianteAsunto     = "asunto"
+	varianteTexto      = "txt"
+	varianteHTML       = "html"
+	plantillaGeneral   = "default"
+	extensionPlantilla = ".tmpl"
+)
+
+// MensajeRenderizado es el resultado de aplicar las plantillas a una notificación
+type MensajeRenderizado struct {
+	Asunto string
+	Texto  string
+	HTML   string
+}
+
+// datosPlantilla es el valor que reciben las plantillas: la notificación, el idioma resuelto
+// y el método T para traducir claves del catálogo, por ejemplo {{.T "saludo"}}
+type datosPlantilla struct {
+	Notificacion
+	Idioma   string
+	catalogo *Catalogo
+}
+
+func (d datosPlantilla) T(clave string, args ...interface{}) string {
+	return d.catalogo.Traducir(d.Idioma, clave, args...)
+}
+
+type plantillaTipo struct {
+	asunto *texttemplate.Template
+	texto  *texttemplate.Template
+	html   *htmltemplate.Template
+}
+
+// MotorPlantillas renderiza el asunto y los cuerpos de texto y HTML de cada tipo de notificación.
+// Las plantillas y catálogos de un directorio reemplazan a los incluidos por defecto y pueden recargarse en caliente.
+type MotorPlantillas struct {
+	mu         sync.RWMutex
+	directorio string
+	plantillas map[string]plantillaTipo
+	catalogo   *Catalogo
+}
+
+// NuevoMotorPlantillas crea un motor con las plantillas incluidas por defecto
+func NuevoMotorPlantillas() *MotorPlantillas {
+	plantillas, catalogo, err := compilarPlantillas("")
+	if err != nil {
+		// Las plantillas incluidas se validan en las pruebas; un error aquí es un defecto de compilación
+		panic(fmt.Sprintf("plantillas por defecto inválidas: %v", err))
+	}
+
+	return &MotorPlantillas{plantillas: plantillas, catalogo: catalogo}
+}
+
+// CargarMotorPlantillas crea un motor que toma las plantillas del directorio indicado,
+// usando las incluidas por defecto para los archivos que no existan en él
+func CargarMotorPlantillas(directorio string) (*MotorPlantillas, error) {
+	plantillas, catalogo, err := compilarPlantillas(directorio)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MotorPlantillas{directorio: directorio, plantillas: plantillas, catalogo: catalogo}, nil
+}
+
+// Recargar vuelve a leer el directorio de plantillas. Si alguna plantilla es inválida
+// se devuelve el error y se conservan las plantillas anteriores.
+func (m *MotorPlantillas) Recargar() error {
+	m.mu.RLock()
+	directorio := m.directorio
+	m.mu.RUnlock()
+
+	plantillas, catalogo, err := compilarPlantillas(directorio)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.plantillas = plantillas
+	m.catalogo = catalogo
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Traducir devuelve el mensaje del catálogo para el idioma indicado
+func (m *MotorPlantillas) Traducir(idioma, clave string, args ...interface{}) string {
+	m.mu.RLock()
+	catalogo := m.catalogo
+	m.mu.RUnlock()
+
+	return catalogo.Traducir(idioma, clave, args...)
+}
+
+// Renderizar aplica las plantillas del tipo de la notificación en el idioma del destinatario
+func (m *MotorPlantillas) Renderizar(notificacion Notificacion) (MensajeRenderizado, error) {
+	m.mu.RLock()
+	plantilla, ok := m.plantillas[string(notificacion.Tipo)]
+	if !ok {
+		plantilla = m.plantillas[plantillaGeneral]
+	}
+	catalogo := m.catalogo
+	m.mu.RUnlock()
+
+	datos := datosPlantilla{
+		Notificacion: notificacion,
+		Idioma:       catalogo.resolverIdioma(notificacion.Idioma),
+		catalogo:     catalogo,
+	}
+
+	var asunto, texto, html bytes.Buffer
+	if err := plantilla.asunto.Execute(&asunto, datos); err != nil {
+		return MensajeRenderizado{}, fmt.Errorf("error al renderizar asunto de %s: %w", notificacion.Tipo, err)
+	}
+	if err := plantilla.texto.Execute(&texto, datos); err != nil {
+		return MensajeRenderizado{}, fmt.Errorf("error al renderizar cuerpo de texto de %s: %w", notificacion.Tipo, err)
+	}
+	if err := plantilla.html.Execute(&html, datos); err != nil {
+		return MensajeRenderizado{}, fmt.Errorf("error al renderizar cuerpo HTML de %s: %w", notificacion.Tipo, err)
+	}
+
+	return MensajeRenderizado{
+		// El asunto es una sola línea
+		Asunto: strings.Join(strings.Fields(asunto.String()), " "),
+		Texto:  texto.String(),
+		HTML:   html.String(),
+	}, nil
+}
+
+// compilarPlantillas combina las plantillas y catálogos por defecto con los del directorio
+// y compila las plantillas por tipo
+func compilarPlantillas(directorio string) (map[string]plantillaTipo, *Catalogo, error) {
+	fuentes, err := leerPlantillas(plantillasPorDefecto, "plantillas")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mensajes, err := leerCatalogo(plantillasPorDefecto, "plantillas")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if directorio != "" {
+		if info, err := os.Stat(directorio); err != nil || !info.IsDir() {
+			return nil, nil, fmt.Errorf("el directorio de plantillas %s no existe", directorio)
+		}
+
+		propias, err := leerPlantillas(os.DirFS(directorio), ".")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error al leer plantillas de %s: %w", directorio, err)
+		}
+		combinar(fuentes, propias)
+
+		mensajesPropios, err := leerCatalogo(os.DirFS(directorio), ".")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error al leer catálogos de %s: %w", directorio, err)
+		}
+		combinar(mensajes, mensajesPropios)
+	}
+
+	general := fuentes[plantillaGeneral]
+	plantillas := make(map[string]plantillaTipo, len(fuentes))
+	for tipo, variantes := range fuentes {
+		fuente := func(variante string) string {
+			if contenido, ok := variantes[variante]; ok {
+				return contenido
+			}
+			return general[variante]
+		}
+
+		var plantilla plantillaTipo
+		if plantilla.asunto, err = texttemplate.New(tipo + ".asunto").Option("missingkey=error").Parse(fuente(varianteAsunto)); err != nil {
+			return nil, nil, fmt.Errorf("plantilla de asunto %s inválida: %w", tipo, err)
+		}
+		if plantilla.texto, err = texttemplate.New(tipo + ".txt").Option("missingkey=error").Parse(fuente(varianteTexto)); err != nil {
+			return nil, nil, fmt.Errorf("plantilla de texto %s inválida: %w", tipo, err)
+		}
+		if plantilla.html, err = htmltemplate.New(tipo + ".html").Option("missingkey=error").Parse(fuente(varianteHTML)); err != nil {
+			return nil, nil, fmt.Errorf("plantilla HTML %s inválida: %w", tipo, err)
+		}
+		plantillas[tipo] = plantilla
+	}
+
+	return plantillas, &Catalogo{mensajes: mensajes}, nil
+}
+
+// combinar agrega a destino las entradas de origen, reemplazando las claves repetidas
+func combinar(destino, origen map[string]map[string]string) {
+	for grupo, valores := range origen {
+		if destino[grupo] == nil {
+			destino[grupo] = make(map[string]string)
+		}
+		for clave, valor := range valores {
+			destino[grupo][clave] = valor
+		}
+	}
+}
+
+// leerPlantillas agrupa los archivos <TIPO>.<variante>.tmpl de un directorio por tipo y variante
+func leerPlantillas(sistema fs.FS, raiz string) (map[string]map[string]string, error) {
+	archivos, err := fs.Glob(sistema, path.Join(raiz, "*"+extensionPlantilla))
+	if err != nil {
+		return nil, err
+	}
+
+	fuentes := make(map[string]map[string]string)
+	for _, archivo := range archivos {
+		nombre := strings.TrimSuffix(path.Base(archivo), extensionPlantilla)
+		separador := strings.LastIndex(nombre, ".")
+		if separador <= 0 {
+			continue
+		}
+
+		tipo, variante := nombre[:separador], nombre[separador+1:]
+		if variante != varianteAsunto && variante != varianteTexto && variante != varianteHTML {
+			continue
+		}
+
+		contenido, err := fs.ReadFile(sistema, archivo)
+		if err != nil {
+			return nil, err
+		}
+
+		if fuentes[tipo] == nil {
+			fuentes[tipo] = make(map[string]string)
+		}
+		fuentes[tipo][variante] = string(contenido)
+	}
+
+	return fuentes, nil
+}
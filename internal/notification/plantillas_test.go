@@ -0,0 +1,149 @@
+package notification
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func nuevaNotificacionConRuta() Notificacion {
+	notificacion := nuevaNotificacionPrueba(42)
+	notificacion.Descripcion = "Tu compra salió <hoy>"
+	notificacion.Ruta = &DatosRuta{ID: 3, Nombre: "Centro", Conductor: "Julián", Vehiculo: "AB123CD"}
+	return notificacion
+}
+
+func TestRenderizarPlantillasPorDefecto(t *testing.T) {
+	motor := NuevoMotorPlantillas()
+
+	renderizado, err := motor.Renderizar(nuevaNotificacionConRuta())
+	if err != nil {
+		t.Fatalf("No se esperaba un error al renderizar: %v", err)
+	}
+
+	if renderizado.Asunto != "Tu compra #42 está en camino" {
+		t.Errorf("Asunto inesperado: %q", renderizado.Asunto)
+	}
+	for _, esperado := range []string{"Julián", "AB123CD", "Centro", "<hoy>"} {
+		if !strings.Contains(renderizado.Texto, esperado) {
+			t.Errorf("El cuerpo de texto no contiene %q: %s", esperado, renderizado.Texto)
+		}
+	}
+	if !strings.Contains(renderizado.HTML, "&lt;hoy&gt;") {
+		t.Errorf("Se esperaba que el HTML escapara la descripción: %s", renderizado.HTML)
+	}
+
+	// Los tipos sin plantilla propia usan la plantilla general
+	notificacion := nuevaNotificacionPrueba(7)
+	notificacion.Tipo = "OTRO_TIPO"
+	renderizado, err = motor.Renderizar(notificacion)
+	if err != nil {
+		t.Fatalf("No se esperaba un error al renderizar: %v", err)
+	}
+	if renderizado.Asunto != "Notificación de Compra #7" {
+		t.Errorf("Asunto inesperado para la plantilla general: %q", renderizado.Asunto)
+	}
+}
+
+func TestCargarYRecargarPlantillasDesdeDirectorio(t *testing.T) {
+	directorio := t.TempDir()
+	escribir := func(nombre, contenido string) {
+		if err := os.WriteFile(filepath.Join(directorio, nombre), []byte(contenido), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	escribir("COMPRA_ENTREGADA.asunto.tmpl", "¡Entregada! #{{.IDCompra}}")
+	motor, err := CargarMotorPlantillas(directorio)
+	if err != nil {
+		t.Fatalf("No se esperaba un error al cargar: %v", err)
+	}
+
+	notificacion := nuevaNotificacionPrueba(5)
+	notificacion.Tipo = NotificacionCompraEntregada
+	renderizado, _ := motor.Renderizar(notificacion)
+	if renderizado.Asunto != "¡Entregada! #5" {
+		t.Errorf("Asunto inesperado: %q", renderizado.Asunto)
+	}
+	if renderizado.Texto == "" || renderizado.HTML == "" {
+		t.Error("Las variantes sin archivo propio deben usar las plantillas incluidas")
+	}
+
+	escribir("COMPRA_ENTREGADA.asunto.tmpl", "Compra {{.IDCompra}} entregada")
+	if err := motor.Recargar(); err != nil {
+		t.Fatalf("No se esperaba un error al recargar: %v", err)
+	}
+	renderizado, _ = motor.Renderizar(notificacion)
+	if renderizado.Asunto != "Compra 5 entregada" {
+		t.Errorf("La recarga no tomó la plantilla nueva: %q", renderizado.Asunto)
+	}
+
+	// Una plantilla inválida no reemplaza a las vigentes
+	escribir("COMPRA_ENTREGADA.asunto.tmpl", "{{.IDCompra")
+	if err := motor.Recargar(); err == nil {
+		t.Error("Se esperaba un error al recargar una plantilla inválida")
+	}
+	renderizado, _ = motor.Renderizar(notificacion)
+	if renderizado.Asunto != "Compra 5 entregada" {
+		t.Errorf("Se esperaba conservar la plantilla anterior: %q", renderizado.Asunto)
+	}
+
+	if _, err := CargarMotorPlantillas(filepath.Join(directorio, "no-existe")); err == nil {
+		t.Error("Se esperaba un error para un directorio inexistente")
+	}
+}
+
+func TestConstruirMensajeMIME(t *testing.T) {
+	renderizado := MensajeRenderizado{
+		Asunto: "Tu compra está en camino",
+		Texto:  "Hola, tu compra está en camino",
+		HTML:   "<p>Hola, tu compra está en camino</p>",
+	}
+
+	crudo, err := construirMensajeMIME("envios@ejemplo.com", "cliente@ejemplo.com", renderizado)
+	if err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+
+	mensaje, err := mail.ReadMessage(strings.NewReader(crudo))
+	if err != nil {
+		t.Fatalf("Mensaje inválido: %v", err)
+	}
+
+	asunto, _ := new(mime.WordDecoder).DecodeHeader(mensaje.Header.Get("Subject"))
+	if asunto != renderizado.Asunto {
+		t.Errorf("Asunto inesperado: %q", asunto)
+	}
+
+	tipo, parametros, err := mime.ParseMediaType(mensaje.Header.Get("Content-Type"))
+	if err != nil || tipo != "multipart/alternative" {
+		t.Fatalf("Se esperaba multipart/alternative, se obtuvo %q (%v)", tipo, err)
+	}
+
+	lector := multipart.NewReader(mensaje.Body, parametros["boundary"])
+	var tipos, contenidos []string
+	for {
+		parte, err := lector.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contenido, _ := io.ReadAll(parte)
+		tipos = append(tipos, parte.Header.Get("Content-Type"))
+		contenidos = append(contenidos, string(contenido))
+	}
+
+	if len(tipos) != 2 || !strings.HasPrefix(tipos[0], "text/plain") || !strings.HasPrefix(tipos[1], "text/html") {
+		t.Fatalf("Partes inesperadas: %v", tipos)
+	}
+	if contenidos[0] != renderizado.Texto || contenidos[1] != renderizado.HTML {
+		t.Errorf("Contenido inesperado: %q", contenidos)
+	}
+}
@@ -0,0 +1,253 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Errores del pool de envío asíncrono
+var (
+	ErrColaLlena    = errors.New("cola de notificaciones llena")
+	ErrPoolDetenido = errors.New("pool de notificaciones detenido")
+)
+
+// PoliticaContrapresion define qué hacer cuando la cola está llena
+type PoliticaContrapresion string
+
+const (
+	// ContrapresionBloquear espera a que haya lugar en la cola
+	ContrapresionBloquear PoliticaContrapresion = "BLOQUEAR"
+	// ContrapresionDescartarAntigua descarta la notificación más antigua de la cola
+	ContrapresionDescartarAntigua PoliticaContrapresion = "DESCARTAR_ANTIGUA"
+	// ContrapresionRechazar devuelve ErrColaLlena sin encolar
+	ContrapresionRechazar PoliticaContrapresion = "RECHAZAR"
+)
+
+// ConfigPool define el tamaño de la cola, la cantidad de trabajadores y la política de contrapresión
+type ConfigPool struct {
+	Trabajadores  int
+	CapacidadCola int
+	Contrapresion PoliticaContrapresion
+}
+
+// ConfigPoolPorDefecto devuelve valores razonables para el pool
+func ConfigPoolPorDefecto() ConfigPool {
+	return ConfigPool{
+		Trabajadores:  4,
+		CapacidadCola: 100,
+		Contrapresion: ContrapresionBloquear,
+	}
+}
+
+// EstadisticasPool resume la actividad del pool para monitoreo
+type EstadisticasPool struct {
+	EnCola      int    `json:"en_cola"`
+	Enviadas    uint64 `json:"enviadas"`
+	Fallidas    uint64 `json:"fallidas"`
+	Descartadas uint64 `json:"descartadas"`
+	Rechazadas  uint64 `json:"rechazadas"`
+	Postergadas uint64 `json:"postergadas"`
+}
+
+// PoolNotificaciones envía notificaciones en segundo plano con una cola acotada,
+// de modo que un canal lento no bloquee a quien notifica
+type PoolNotificaciones struct {
+	// Los contadores van primero para mantener la alineación de 64 bits que requiere sync/atomic
+	enviadas    uint64
+	fallidas    uint64
+	descartadas uint64
+	rechazadas  uint64
+	postergadas uint64
+
+	notificador Notificador
+	config      ConfigPool
+	cola        chan Notificacion
+
+	mu       sync.RWMutex
+	detenido bool
+	detener  chan struct{}
+	cierre   sync.Once
+	wg       sync.WaitGroup
+
+	// esperando guarda las notificaciones postergadas por el horario de silencio del destinatario
+	muEspera  sync.Mutex
+	esperando map[*time.Timer]Notificacion
+}
+
+// NuevoPoolNotificaciones crea el pool e inicia sus trabajadores
+func NuevoPoolNotificaciones(notificador Notificador, config ConfigPool) *PoolNotificaciones {
+	if config.Trabajadores <= 0 {
+		config.Trabajadores = 1
+	}
+	if config.CapacidadCola <= 0 {
+		config.CapacidadCola = 1
+	}
+	if config.Contrapresion == "" {
+		config.Contrapresion = ContrapresionBloquear
+	}
+
+	p := &PoolNotificaciones{
+		notificador: notificador,
+		config:      config,
+		cola:        make(chan Notificacion, config.CapacidadCola),
+		detener:     make(chan struct{}),
+		esperando:   make(map[*time.Timer]Notificacion),
+	}
+
+	for i := 0; i < config.Trabajadores; i++ {
+		p.wg.Add(1)
+		go p.trabajar()
+	}
+
+	return p
+}
+
+// Notificar encola la notificación según la política de contrapresión. El resultado del
+// envío no se informa al llamador; los errores se registran en el log.
+func (p *PoolNotificaciones) Notificar(notificacion Notificacion) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.detenido {
+		return ErrPoolDetenido
+	}
+
+	switch p.config.Contrapresion {
+	case ContrapresionRechazar:
+		select {
+		case p.cola <- notificacion:
+			return nil
+		default:
+			atomic.AddUint64(&p.rechazadas, 1)
+			return ErrColaLlena
+		}
+
+	case ContrapresionDescartarAntigua:
+		for {
+			select {
+			case p.cola <- notificacion:
+				return nil
+			default:
+			}
+
+			select {
+			case descartada := <-p.cola:
+				atomic.AddUint64(&p.descartadas, 1)
+				log.Printf("Cola de notificaciones llena, se descarta la notificación de la compra %d", descartada.IDCompra)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case p.cola <- notificacion:
+			return nil
+		case <-p.detener:
+			return ErrPoolDetenido
+		}
+	}
+}
+
+// Detener deja de aceptar notificaciones y espera a que se envíen las encoladas.
+// Si el contexto vence antes, devuelve su error y los trabajadores terminan en segundo plano.
+// Las notificaciones postergadas por horario de silencio se descartan, porque el pool no
+// las persiste; la bandeja de salida es la alternativa durable.
+func (p *PoolNotificaciones) Detener(ctx context.Context) error {
+	p.cierre.Do(func() {
+		// Se liberan primero los llamadores bloqueados esperando lugar en la cola
+		close(p.detener)
+
+		p.mu.Lock()
+		p.detenido = true
+		close(p.cola)
+		p.mu.Unlock()
+
+		p.muEspera.Lock()
+		for temporizador, notificacion := range p.esperando {
+			temporizador.Stop()
+			log.Printf("Pool detenido, se descarta la notificación postergada de la compra %d", notificacion.IDCompra)
+		}
+		p.esperando = nil
+		p.muEspera.Unlock()
+	})
+
+	terminado := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(terminado)
+	}()
+
+	select {
+	case <-terminado:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Estadisticas devuelve los contadores del pool
+func (p *PoolNotificaciones) Estadisticas() EstadisticasPool {
+	return EstadisticasPool{
+		EnCola:      len(p.cola),
+		Enviadas:    atomic.LoadUint64(&p.enviadas),
+		Fallidas:    atomic.LoadUint64(&p.fallidas),
+		Descartadas: atomic.LoadUint64(&p.descartadas),
+		Rechazadas:  atomic.LoadUint64(&p.rechazadas),
+		Postergadas: atomic.LoadUint64(&p.postergadas),
+	}
+}
+
+func (p *PoolNotificaciones) trabajar() {
+	defer p.wg.Done()
+
+	for notificacion := range p.cola {
+		err := p.notificador.Notificar(notificacion)
+
+		// El horario de silencio no es una falla: la notificación vuelve a la cola cuando termina
+		var silencio *ErrorHorarioSilencio
+		if errors.As(err, &silencio) {
+			p.postergar(notificacion, silencio.Hasta)
+			continue
+		}
+
+		if err != nil {
+			atomic.AddUint64(&p.fallidas, 1)
+			log.Printf("Error al enviar la notificación de la compra %d: %v", notificacion.IDCompra, err)
+			continue
+		}
+		atomic.AddUint64(&p.enviadas, 1)
+	}
+}
+
+// postergar vuelve a encolar la notificación cuando termina el horario de silencio
+func (p *PoolNotificaciones) postergar(notificacion Notificacion, hasta time.Time) {
+	p.muEspera.Lock()
+	defer p.muEspera.Unlock()
+
+	if p.esperando == nil {
+		log.Printf("Pool detenido, se descarta la notificación postergada de la compra %d", notificacion.IDCompra)
+		return
+	}
+
+	atomic.AddUint64(&p.postergadas, 1)
+
+	var temporizador *time.Timer
+	temporizador = time.AfterFunc(time.Until(hasta), func() {
+		p.muEspera.Lock()
+		_, pendiente := p.esperando[temporizador]
+		delete(p.esperando, temporizador)
+		p.muEspera.Unlock()
+
+		if !pendiente {
+			return
+		}
+		if err := p.Notificar(notificacion); err != nil {
+			log.Printf("Error al reencolar la notificación postergada de la compra %d: %v", notificacion.IDCompra, err)
+		}
+	})
+	p.esperando[temporizador] = notificacion
+}
@@ -0,0 +1,263 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// notificadorBloqueado retiene los envíos hasta que se libera el canal
+type notificadorBloqueado struct {
+	mu       sync.Mutex
+	liberar  chan struct{}
+	enviadas []int
+}
+
+func (n *notificadorBloqueado) Notificar(notificacion Notificacion) error {
+	<-n.liberar
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.enviadas = append(n.enviadas, notificacion.IDCompra)
+	return nil
+}
+
+func TestPoolEnviaYDrenaAlDetener(t *testing.T) {
+	notificador := &notificadorBloqueado{liberar: make(chan struct{})}
+	close(notificador.liberar)
+
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 2, CapacidadCola: 10})
+	for i := 1; i <= 5; i++ {
+		if err := pool.Notificar(nuevaNotificacionPrueba(i)); err != nil {
+			t.Fatalf("No se esperaba un error al encolar: %v", err)
+		}
+	}
+
+	if err := pool.Detener(context.Background()); err != nil {
+		t.Fatalf("No se esperaba un error al detener: %v", err)
+	}
+	if len(notificador.enviadas) != 5 {
+		t.Errorf("Se esperaban 5 envíos tras drenar la cola, obtenidos: %d", len(notificador.enviadas))
+	}
+	if err := pool.Notificar(nuevaNotificacionPrueba(6)); !errors.Is(err, ErrPoolDetenido) {
+		t.Errorf("Se esperaba ErrPoolDetenido, obtenido: %v", err)
+	}
+	if estadisticas := pool.Estadisticas(); estadisticas.Enviadas != 5 {
+		t.Errorf("Estadísticas inesperadas: %+v", estadisticas)
+	}
+}
+
+// notificadorEnSilencio responde con el horario de silencio hasta el instante indicado
+type notificadorEnSilencio struct {
+	hasta    time.Time
+	intentos int32
+	enviada  chan Notificacion
+}
+
+func (n *notificadorEnSilencio) Notificar(notificacion Notificacion) error {
+	atomic.AddInt32(&n.intentos, 1)
+	if time.Now().Before(n.hasta) {
+		return &ErrorHorarioSilencio{Destinatario: notificacion.Destinatario, Hasta: n.hasta}
+	}
+	n.enviada <- notificacion
+	return nil
+}
+
+func TestPoolPostergaDuranteHorarioSilencio(t *testing.T) {
+	notificador := &notificadorEnSilencio{hasta: time.Now().Add(50 * time.Millisecond), enviada: make(chan Notificacion, 1)}
+
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 10})
+	if err := pool.Notificar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("No se esperaba un error al encolar: %v", err)
+	}
+
+	select {
+	case notificacion := <-notificador.enviada:
+		if notificacion.IDCompra != 1 {
+			t.Errorf("Se esperaba la compra 1, obtenida: %d", notificacion.IDCompra)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Se esperaba el envío al terminar el horario de silencio")
+	}
+
+	if err := pool.Detener(context.Background()); err != nil {
+		t.Fatalf("No se esperaba un error al detener: %v", err)
+	}
+	if atomic.LoadInt32(&notificador.intentos) != 2 {
+		t.Errorf("Se esperaban 2 intentos, obtenidos: %d", notificador.intentos)
+	}
+	if estadisticas := pool.Estadisticas(); estadisticas.Fallidas != 0 || estadisticas.Postergadas != 1 || estadisticas.Enviadas != 1 {
+		t.Errorf("Estadísticas inesperadas: %+v", estadisticas)
+	}
+}
+
+func TestPoolDetenerDescartaLasPostergadas(t *testing.T) {
+	notificador := &notificadorEnSilencio{hasta: time.Now().Add(time.Hour), enviada: make(chan Notificacion, 1)}
+
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 10})
+	if err := pool.Notificar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("No se esperaba un error al encolar: %v", err)
+	}
+
+	for pool.Estadisticas().Postergadas == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Detener(ctx); err != nil {
+		t.Fatalf("Se esperaba que Detener no espere el fin del horario de silencio: %v", err)
+	}
+	if estadisticas := pool.Estadisticas(); estadisticas.Fallidas != 0 {
+		t.Errorf("Estadísticas inesperadas: %+v", estadisticas)
+	}
+}
+
+func TestPoolRechazaConColaLlena(t *testing.T) {
+	notificador := &notificadorBloqueado{liberar: make(chan struct{})}
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 1, Contrapresion: ContrapresionRechazar})
+
+	// El trabajador toma la primera y queda bloqueado; la segunda ocupa la cola
+	pool.Notificar(nuevaNotificacionPrueba(1))
+	esperarCola(t, pool, 0)
+	pool.Notificar(nuevaNotificacionPrueba(2))
+
+	if err := pool.Notificar(nuevaNotificacionPrueba(3)); !errors.Is(err, ErrColaLlena) {
+		t.Errorf("Se esperaba ErrColaLlena, obtenido: %v", err)
+	}
+
+	close(notificador.liberar)
+	pool.Detener(context.Background())
+
+	if len(notificador.enviadas) != 2 || pool.Estadisticas().Rechazadas != 1 {
+		t.Errorf("Envíos inesperados: %v, %+v", notificador.enviadas, pool.Estadisticas())
+	}
+}
+
+func TestPoolDescartaLaMasAntigua(t *testing.T) {
+	notificador := &notificadorBloqueado{liberar: make(chan struct{})}
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 2, Contrapresion: ContrapresionDescartarAntigua})
+
+	pool.Notificar(nuevaNotificacionPrueba(1))
+	esperarCola(t, pool, 0)
+	for i := 2; i <= 5; i++ {
+		if err := pool.Notificar(nuevaNotificacionPrueba(i)); err != nil {
+			t.Fatalf("No se esperaba un error: %v", err)
+		}
+	}
+
+	close(notificador.liberar)
+	pool.Detener(context.Background())
+
+	// Se descartan la 2 y la 3 y se conservan las más recientes
+	if len(notificador.enviadas) != 3 || notificador.enviadas[1] != 4 || notificador.enviadas[2] != 5 {
+		t.Errorf("Envíos inesperados: %v", notificador.enviadas)
+	}
+	if pool.Estadisticas().Descartadas != 2 {
+		t.Errorf("Se esperaban 2 descartadas: %+v", pool.Estadisticas())
+	}
+}
+
+func TestPoolBloqueaHastaQueHayaLugar(t *testing.T) {
+	notificador := &notificadorBloqueado{liberar: make(chan struct{})}
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 1})
+
+	pool.Notificar(nuevaNotificacionPrueba(1))
+	esperarCola(t, pool, 0)
+	pool.Notificar(nuevaNotificacionPrueba(2))
+
+	encolada := make(chan error)
+	go func() {
+		encolada <- pool.Notificar(nuevaNotificacionPrueba(3))
+	}()
+
+	select {
+	case <-encolada:
+		t.Fatal("Se esperaba que Notificar se bloqueara con la cola llena")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(notificador.liberar)
+	if err := <-encolada; err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	pool.Detener(context.Background())
+
+	if len(notificador.enviadas) != 3 {
+		t.Errorf("Se esperaban 3 envíos, obtenidos: %v", notificador.enviadas)
+	}
+}
+
+func TestPoolDetenerRespetaContexto(t *testing.T) {
+	notificador := &notificadorBloqueado{liberar: make(chan struct{})}
+	pool := NuevoPoolNotificaciones(notificador, ConfigPool{Trabajadores: 1, CapacidadCola: 1})
+	pool.Notificar(nuevaNotificacionPrueba(1))
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelar()
+
+	if err := pool.Detener(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Se esperaba DeadlineExceeded, obtenido: %v", err)
+	}
+	close(notificador.liberar)
+}
+
+// canalLento mide cuántos envíos simultáneos recibe
+type canalLento struct {
+	nombre  string
+	activos int32
+	maximo  int32
+}
+
+func (c *canalLento) Nombre() string {
+	return c.nombre
+}
+
+func (c *canalLento) Enviar(notificacion Notificacion) error {
+	activos := atomic.AddInt32(&c.activos, 1)
+	for {
+		maximo := atomic.LoadInt32(&c.maximo)
+		if activos <= maximo || atomic.CompareAndSwapInt32(&c.maximo, maximo, activos) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt32(&c.activos, -1)
+	return nil
+}
+
+func TestLimiteConcurrenciaPorCanal(t *testing.T) {
+	canal := &canalLento{nombre: CanalEmail}
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		ConCanal(canal, true),
+		ConLimiteConcurrencia(CanalEmail, 2),
+	)
+
+	pool := NuevoPoolNotificaciones(servicio, ConfigPool{Trabajadores: 8, CapacidadCola: 20})
+	for i := 1; i <= 20; i++ {
+		pool.Notificar(nuevaNotificacionPrueba(i))
+	}
+	pool.Detener(context.Background())
+
+	if maximo := atomic.LoadInt32(&canal.maximo); maximo > 2 {
+		t.Errorf("Se esperaban como máximo 2 envíos simultáneos, obtenidos: %d", maximo)
+	}
+	if pool.Estadisticas().Enviadas != 20 {
+		t.Errorf("Estadísticas inesperadas: %+v", pool.Estadisticas())
+	}
+}
+
+// esperarCola espera a que los trabajadores tomen las notificaciones encoladas
+func esperarCola(t *testing.T, pool *PoolNotificaciones, enCola int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if pool.Estadisticas().EnCola == enCola {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("La cola no llegó a %d elementos", enCola)
+}
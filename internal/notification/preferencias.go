@@ -0,0 +1,248 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrContactoNoEncontrado indica que el destinatario no tiene preferencias registradas
+var ErrContactoNoEncontrado = errors.New("contacto no encontrado")
+
+// ErrPreferenciasInvalidas indica que las preferencias enviadas no son válidas
+var ErrPreferenciasInvalidas = errors.New("preferencias de notificación inválidas")
+
+// Contacto reúne las direcciones de un destinatario por canal y sus preferencias de notificación.
+// ID coincide con Notificacion.Destinatario.
+type Contacto struct {
+	ID               string `json:"id"`
+	Email            string `json:"email,omitempty"`
+	TokenDispositivo string `json:"token_dispositivo,omitempty"`
+	Telefono         string `json:"telefono,omitempty"`
+	Idioma           string `json:"idioma,omitempty"`
+
+	// TokenInvalido lo marca el servicio cuando el proveedor push rechaza el token; no se
+	// vuelve a enviar push hasta que se registre un token nuevo
+	TokenInvalido bool `json:"token_invalido,omitempty"`
+
+	// Canales y Tipos indican la suscripción explícita; los ausentes se consideran suscriptos
+	Canales map[string]bool           `json:"canales,omitempty"`
+	Tipos   map[TipoNotificacion]bool `json:"tipos,omitempty"`
+
+	HorarioSilencio *HorarioSilencio `json:"horario_silencio,omitempty"`
+	ActualizadoEn   time.Time        `json:"actualizado_en"`
+}
+
+// Validar verifica el horario de silencio y los tipos de notificación
+func (c *Contacto) Validar() error {
+	if strings.TrimSpace(c.ID) == "" {
+		return fmt.Errorf("%w: el identificador es requerido", ErrPreferenciasInvalidas)
+	}
+
+	for tipo := range c.Tipos {
+		if !tipo.EsValido() {
+			return fmt.Errorf("%w: tipo de notificación desconocido %q", ErrPreferenciasInvalidas, tipo)
+		}
+	}
+
+	if c.HorarioSilencio != nil {
+		if err := c.HorarioSilencio.Validar(); err != nil {
+			return fmt.Errorf("%w: %v", ErrPreferenciasInvalidas, err)
+		}
+	}
+
+	return nil
+}
+
+// AceptaCanal indica si el destinatario no se dio de baja del canal
+func (c *Contacto) AceptaCanal(canal string) bool {
+	suscripto, ok := c.Canales[canal]
+	return !ok || suscripto
+}
+
+// AceptaTipo indica si el destinatario no se dio de baja del tipo de notificación
+func (c *Contacto) AceptaTipo(tipo TipoNotificacion) bool {
+	suscripto, ok := c.Tipos[tipo]
+	return !ok || suscripto
+}
+
+// direccionar adapta la notificación a la dirección del contacto para el canal.
+// Devuelve false si el contacto no tiene dirección para ese canal.
+func (c *Contacto) direccionar(canal string, notificacion Notificacion) (Notificacion, bool) {
+	if notificacion.Idioma == "" {
+		notificacion.Idioma = c.Idioma
+	}
+
+	switch canal {
+	case CanalEmail:
+		if c.Email == "" {
+			return notificacion, false
+		}
+		notificacion.Destinatario = c.Email
+	case CanalPush:
+		if c.TokenDispositivo == "" || c.TokenInvalido {
+			return notificacion, false
+		}
+		notificacion.Destinatario = c.TokenDispositivo
+	case CanalSMS:
+		if c.Telefono == "" {
+			return notificacion, false
+		}
+		notificacion.Telefono = c.Telefono
+	}
+
+	return notificacion, true
+}
+
+// EsValido indica si el tipo de notificación es uno de los definidos
+func (t TipoNotificacion) EsValido() bool {
+	switch t {
+	case NotificacionCompraCreada, NotificacionCompraEnRuta, NotificacionCompraEntregada, NotificacionCompraEnError, NotificacionResumen:
+		return true
+	}
+	return false
+}
+
+// HorarioSilencio es la franja diaria en la que no se envían notificaciones. Inicio y Fin
+// usan el formato "HH:MM" en la zona horaria indicada (UTC si está vacía); la franja
+// puede cruzar la medianoche, por ejemplo de "22:00" a "07:00".
+type HorarioSilencio struct {
+	Inicio      string `json:"inicio"`
+	Fin         string `json:"fin"`
+	ZonaHoraria string `json:"zona_horaria,omitempty"`
+}
+
+func (h *HorarioSilencio) Validar() error {
+	if _, err := time.Parse("15:04", h.Inicio); err != nil {
+		return fmt.Errorf("inicio del horario de silencio inválido: %q", h.Inicio)
+	}
+	if _, err := time.Parse("15:04", h.Fin); err != nil {
+		return fmt.Errorf("fin del horario de silencio inválido: %q", h.Fin)
+	}
+	if _, err := time.LoadLocation(h.ZonaHoraria); err != nil {
+		return fmt.Errorf("zona horaria desconocida: %q", h.ZonaHoraria)
+	}
+	return nil
+}
+
+// Activo indica si el instante cae dentro del horario de silencio y, en ese caso, cuándo termina
+func (h *HorarioSilencio) Activo(instante time.Time) (bool, time.Time) {
+	zona, err := time.LoadLocation(h.ZonaHoraria)
+	if err != nil {
+		return false, time.Time{}
+	}
+	inicio, errInicio := time.Parse("15:04", h.Inicio)
+	fin, errFin := time.Parse("15:04", h.Fin)
+	if errInicio != nil || errFin != nil || h.Inicio == h.Fin {
+		return false, time.Time{}
+	}
+
+	local := instante.In(zona)
+	minutos := local.Hour()*60 + local.Minute()
+	minutosInicio := inicio.Hour()*60 + inicio.Minute()
+	minutosFin := fin.Hour()*60 + fin.Minute()
+
+	var activo bool
+	if minutosInicio < minutosFin {
+		activo = minutos >= minutosInicio && minutos < minutosFin
+	} else {
+		activo = minutos >= minutosInicio || minutos < minutosFin
+	}
+	if !activo {
+		return false, time.Time{}
+	}
+
+	hasta := time.Date(local.Year(), local.Month(), local.Day(), fin.Hour(), fin.Minute(), 0, 0, zona)
+	if !hasta.After(local) {
+		hasta = hasta.AddDate(0, 0, 1)
+	}
+	return true, hasta
+}
+
+// ErrorHorarioSilencio indica que el destinatario está en su horario de silencio.
+// El despachador de la bandeja reprograma la notificación para Hasta sin contar el intento.
+type ErrorHorarioSilencio struct {
+	Destinatario string
+	Hasta        time.Time
+}
+
+func (e *ErrorHorarioSilencio) Error() string {
+	return fmt.Sprintf("destinatario %s en horario de silencio hasta %s", e.Destinatario, e.Hasta.Format(time.RFC3339))
+}
+
+// AlmacenContactos persiste los contactos y sus preferencias
+type AlmacenContactos interface {
+	Obtener(id string) (Contacto, error)
+	Guardar(contacto Contacto) (Contacto, error)
+	Eliminar(id string) error
+	Listar() ([]Contacto, error)
+}
+
+// AlmacenContactosMemoria implementa AlmacenContactos en memoria
+type AlmacenContactosMemoria struct {
+	mu        sync.RWMutex
+	contactos map[string]Contacto
+	ahora     func() time.Time
+}
+
+func NuevoAlmacenContactosMemoria() *AlmacenContactosMemoria {
+	return &AlmacenContactosMemoria{
+		contactos: make(map[string]Contacto),
+		ahora:     time.Now,
+	}
+}
+
+func (a *AlmacenContactosMemoria) Obtener(id string) (Contacto, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	contacto, ok := a.contactos[id]
+	if !ok {
+		return Contacto{}, ErrContactoNoEncontrado
+	}
+	return contacto, nil
+}
+
+// Guardar valida y crea o reemplaza las preferencias del contacto
+func (a *AlmacenContactosMemoria) Guardar(contacto Contacto) (Contacto, error) {
+	if err := contacto.Validar(); err != nil {
+		return Contacto{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	contacto.ActualizadoEn = a.ahora()
+	a.contactos[contacto.ID] = contacto
+	return contacto, nil
+}
+
+func (a *AlmacenContactosMemoria) Eliminar(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.contactos[id]; !ok {
+		return ErrContactoNoEncontrado
+	}
+	delete(a.contactos, id)
+	return nil
+}
+
+func (a *AlmacenContactosMemoria) Listar() ([]Contacto, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	contactos := make([]Contacto, 0, len(a.contactos))
+	for _, contacto := range a.contactos {
+		contactos = append(contactos, contacto)
+	}
+	sort.Slice(contactos, func(i, j int) bool {
+		return contactos[i].ID < contactos[j].ID
+	})
+	return contactos, nil
+}
+
+var _ AlmacenContactos = &AlmacenContactosMemoria{}
@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// canalRegistrador guarda las notificaciones recibidas para verificar el direccionamiento
+type canalRegistrador struct {
+	nombre    string
+	recibidas []Notificacion
+}
+
+func (c *canalRegistrador) Nombre() string {
+	return c.nombre
+}
+
+func (c *canalRegistrador) Enviar(notificacion Notificacion) error {
+	c.recibidas = append(c.recibidas, notificacion)
+	return nil
+}
+
+func nuevoServicioConContactos(t *testing.T, contacto Contacto) (*ServicioNotificaciones, map[string]*canalRegistrador) {
+	almacen := NuevoAlmacenContactosMemoria()
+	if _, err := almacen.Guardar(contacto); err != nil {
+		t.Fatalf("No se esperaba un error al guardar el contacto: %v", err)
+	}
+
+	canales := map[string]*canalRegistrador{
+		CanalEmail: {nombre: CanalEmail},
+		CanalPush:  {nombre: CanalPush},
+		CanalSMS:   {nombre: CanalSMS},
+	}
+
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		ConCanal(canales[CanalEmail], true),
+		ConCanal(canales[CanalPush], true),
+		ConCanal(canales[CanalSMS], true),
+		ConContactos(almacen),
+	)
+	return servicio, canales
+}
+
+func TestNotificarDireccionaPorCanal(t *testing.T) {
+	servicio, canales := nuevoServicioConContactos(t, Contacto{
+		ID:               "cliente-1",
+		Email:            "cliente@ejemplo.com",
+		TokenDispositivo: "token-123",
+		Telefono:         "+5491112345678",
+		Idioma:           "pt",
+		Canales:          map[string]bool{CanalSMS: false},
+	})
+
+	notificacion := nuevaNotificacionPrueba(1)
+	notificacion.Destinatario = "cliente-1"
+	resultados := servicio.NotificarConResultados(notificacion)
+
+	if len(canales[CanalEmail].recibidas) != 1 || canales[CanalEmail].recibidas[0].Destinatario != "cliente@ejemplo.com" {
+		t.Errorf("Email mal direccionado: %+v", canales[CanalEmail].recibidas)
+	}
+	if len(canales[CanalPush].recibidas) != 1 || canales[CanalPush].recibidas[0].Destinatario != "token-123" {
+		t.Errorf("Push mal direccionado: %+v", canales[CanalPush].recibidas)
+	}
+	if canales[CanalEmail].recibidas[0].Idioma != "pt" {
+		t.Errorf("Se esperaba el idioma del contacto, obtenido %q", canales[CanalEmail].recibidas[0].Idioma)
+	}
+	if len(canales[CanalSMS].recibidas) != 0 {
+		t.Error("No se esperaba envío por SMS tras la baja del canal")
+	}
+	if resultados[2].Canal != CanalSMS || resultados[2].Omitido == "" {
+		t.Errorf("Se esperaba el canal SMS omitido: %+v", resultados[2])
+	}
+}
+
+func TestNotificarRespetaBajaDeTipo(t *testing.T) {
+	servicio, canales := nuevoServicioConContactos(t, Contacto{
+		ID:    "cliente@ejemplo.com",
+		Email: "cliente@ejemplo.com",
+		Tipos: map[TipoNotificacion]bool{NotificacionCompraEnRuta: false},
+	})
+
+	if err := servicio.Notificar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("La baja de un tipo no debe informarse como error: %v", err)
+	}
+	for nombre, canal := range canales {
+		if len(canal.recibidas) != 0 {
+			t.Errorf("No se esperaba envío por %s", nombre)
+		}
+	}
+
+	entregada := nuevaNotificacionPrueba(1)
+	entregada.Tipo = NotificacionCompraEntregada
+	if err := servicio.Notificar(entregada); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if len(canales[CanalEmail].recibidas) != 1 {
+		t.Error("Se esperaba el envío de los tipos suscriptos")
+	}
+}
+
+func TestNotificarEnHorarioDeSilencio(t *testing.T) {
+	servicio, canales := nuevoServicioConContactos(t, Contacto{
+		ID:              "cliente@ejemplo.com",
+		Email:           "cliente@ejemplo.com",
+		HorarioSilencio: &HorarioSilencio{Inicio: "22:00", Fin: "07:00", ZonaHoraria: "America/Sao_Paulo"},
+	})
+
+	zona, _ := time.LoadLocation("America/Sao_Paulo")
+	servicio.ahora = func() time.Time { return time.Date(2024, 3, 10, 23, 30, 0, 0, zona) }
+
+	err := servicio.Notificar(nuevaNotificacionPrueba(1))
+
+	var silencio *ErrorHorarioSilencio
+	if !errors.As(err, &silencio) {
+		t.Fatalf("Se esperaba un *ErrorHorarioSilencio, obtenido: %v", err)
+	}
+	if !silencio.Hasta.Equal(time.Date(2024, 3, 11, 7, 0, 0, 0, zona)) {
+		t.Errorf("Fin del silencio inesperado: %v", silencio.Hasta)
+	}
+	if len(canales[CanalEmail].recibidas) != 0 {
+		t.Error("No se esperaba envío durante el horario de silencio")
+	}
+
+	servicio.ahora = func() time.Time { return time.Date(2024, 3, 11, 9, 0, 0, 0, zona) }
+	if err := servicio.Notificar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("No se esperaba un error fuera del horario de silencio: %v", err)
+	}
+}
+
+func TestDespachadorPostergaPorHorarioDeSilencio(t *testing.T) {
+	bandeja := NuevaBandejaSalida()
+	bandeja.Agregar(nuevaNotificacionPrueba(1))
+
+	ahora := time.Now()
+	hasta := ahora.Add(8 * time.Hour)
+	notificador := notificadorFunc(func(Notificacion) error {
+		return &ErrorHorarioSilencio{Destinatario: "cliente@ejemplo.com", Hasta: hasta}
+	})
+
+	despachador := NuevoDespachadorBandeja(bandeja, notificador, ConfigDespachadorPorDefecto())
+	despachador.ahora = func() time.Time { return ahora }
+	despachador.ProcesarPendientes()
+
+	entradas, _ := bandeja.Listar(EntradaPendiente)
+	if len(entradas) != 1 || entradas[0].Intentos != 0 || !entradas[0].ProximoIntento.Equal(hasta) {
+		t.Errorf("Se esperaba postergar la entrada sin consumir intentos: %+v", entradas)
+	}
+}
+
+type notificadorFunc func(Notificacion) error
+
+func (f notificadorFunc) Notificar(notificacion Notificacion) error {
+	return f(notificacion)
+}
+
+func TestContactoValidar(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		contacto Contacto
+		valido   bool
+	}{
+		{"Válido", Contacto{ID: "c1", HorarioSilencio: &HorarioSilencio{Inicio: "21:30", Fin: "08:00", ZonaHoraria: "UTC"}}, true},
+		{"Sin identificador", Contacto{}, false},
+		{"Tipo desconocido", Contacto{ID: "c1", Tipos: map[TipoNotificacion]bool{"OTRO": false}}, false},
+		{"Hora inválida", Contacto{ID: "c1", HorarioSilencio: &HorarioSilencio{Inicio: "25:00", Fin: "08:00"}}, false},
+		{"Zona horaria inválida", Contacto{ID: "c1", HorarioSilencio: &HorarioSilencio{Inicio: "21:00", Fin: "08:00", ZonaHoraria: "Marte/Olimpo"}}, false},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			err := c.contacto.Validar()
+			if c.valido && err != nil {
+				t.Errorf("No se esperaba un error: %v", err)
+			}
+			if !c.valido && !errors.Is(err, ErrPreferenciasInvalidas) {
+				t.Errorf("Se esperaba ErrPreferenciasInvalidas, obtenido: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProveedorPush identifica el formato de la API push
+type ProveedorPush string
+
+const (
+	// ProveedorGenerico envía el Payload propio del servicio con autenticación Bearer
+	ProveedorGenerico ProveedorPush = "GENERICO"
+	// ProveedorFCM usa el formato de la API HTTP v1 de Firebase Cloud Messaging
+	ProveedorFCM ProveedorPush = "FCM"
+	// ProveedorAPNs usa el formato de Apple Push Notification service sobre HTTP/2
+	ProveedorAPNs ProveedorPush = "APNS"
+)
+
+// Prioridades de entrega de los mensajes push
+const (
+	PrioridadAlta   = "ALTA"
+	PrioridadNormal = "NORMAL"
+)
+
+// ErrTokenInvalido indica que el proveedor rechazó el token de dispositivo por inválido o dado de baja
+var ErrTokenInvalido = errors.New("token de dispositivo inválido")
+
+// ErrorProveedorPush es una respuesta de error de la API push interpretada según el proveedor
+type ErrorProveedorPush struct {
+	Proveedor     ProveedorPush
+	Estado        int
+	Codigo        string
+	Mensaje       string
+	TokenInvalido bool
+}
+
+func (e *ErrorProveedorPush) Error() string {
+	detalle := e.Codigo
+	if e.Mensaje != "" {
+		detalle = strings.TrimSpace(detalle + " " + e.Mensaje)
+	}
+	if detalle == "" {
+		return fmt.Sprintf("error en respuesta push de %s: código de estado %d", e.Proveedor, e.Estado)
+	}
+	return fmt.Sprintf("error en respuesta push de %s: código de estado %d (%s)", e.Proveedor, e.Estado, detalle)
+}
+
+// Is permite reconocer los tokens inválidos con errors.Is(err, ErrTokenInvalido)
+func (e *ErrorProveedorPush) Is(objetivo error) bool {
+	return objetivo == ErrTokenInvalido && e.TokenInvalido
+}
+
+// MensajePush es el contenido independiente del proveedor a enviar a un dispositivo
+type MensajePush struct {
+	Token        string
+	Titulo       string
+	Cuerpo       string
+	Datos        map[string]string
+	Prioridad    string
+	TTL          time.Duration
+	ClaveColapso string
+}
+
+// adaptadorPush traduce el mensaje al formato de cada proveedor e interpreta sus errores
+type adaptadorPush interface {
+	solicitud(config ConfigPush, mensaje MensajePush) (*http.Request, error)
+	interpretarError(estado int, cuerpo []byte) *ErrorProveedorPush
+}
+
+func nuevoAdaptadorPush(proveedor ProveedorPush) (adaptadorPush, error) {
+	switch proveedor {
+	case "", ProveedorGenerico:
+		return adaptadorGenerico{}, nil
+	case ProveedorFCM:
+		return adaptadorFCM{}, nil
+	case ProveedorAPNs:
+		return adaptadorAPNs{}, nil
+	}
+	return nil, fmt.Errorf("proveedor push desconocido: %q", proveedor)
+}
+
+func nuevaSolicitudJSON(url string, cuerpo interface{}) (*http.Request, error) {
+	contenido, err := json.Marshal(cuerpo)
+	if err != nil {
+		return nil, fmt.Errorf("error al serializar payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(contenido))
+	if err != nil {
+		return nil, fmt.Errorf("error al crear solicitud HTTP: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// adaptadorGenerico conserva el Payload original del servicio
+type adaptadorGenerico struct{}
+
+func (adaptadorGenerico) solicitud(config ConfigPush, mensaje MensajePush) (*http.Request, error) {
+	idCompra, _ := strconv.Atoi(mensaje.Datos["id_compra"])
+	req, err := nuevaSolicitudJSON(config.ServidorAPI, Payload{
+		IDCompra:     idCompra,
+		Tipo:         mensaje.Datos["tipo"],
+		Titulo:       mensaje.Titulo,
+		Descripcion:  mensaje.Cuerpo,
+		Destinatario: mensaje.Token,
+		Idioma:       mensaje.Datos["idioma"],
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.ClaveAPI)
+	return req, nil
+}
+
+func (adaptadorGenerico) interpretarError(estado int, cuerpo []byte) *ErrorProveedorPush {
+	var respuesta struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(cuerpo, &respuesta)
+	return &ErrorProveedorPush{Proveedor: ProveedorGenerico, Estado: estado, Mensaje: respuesta.Error}
+}
+
+// adaptadorFCM arma mensajes para https://fcm.googleapis.com/v1/projects/<proyecto>/messages:send
+type adaptadorFCM struct{}
+
+type mensajeFCM struct {
+	Mensaje cuerpoFCM `json:"message"`
+}
+
+type cuerpoFCM struct {
+	Token        string            `json:"token"`
+	Notificacion notificacionFCM   `json:"notification"`
+	Datos        map[string]string `json:"data,omitempty"`
+	Android      androidFCM        `json:"android"`
+}
+
+type notificacionFCM struct {
+	Titulo string `json:"title"`
+	Cuerpo string `json:"body"`
+}
+
+type androidFCM struct {
+	Prioridad    string `json:"priority"`
+	TTL          string `json:"ttl,omitempty"`
+	ClaveColapso string `json:"collapse_key,omitempty"`
+}
+
+func (adaptadorFCM) solicitud(config ConfigPush, mensaje MensajePush) (*http.Request, error) {
+	android := androidFCM{Prioridad: "HIGH", ClaveColapso: mensaje.ClaveColapso}
+	if mensaje.Prioridad == PrioridadNormal {
+		android.Prioridad = "NORMAL"
+	}
+	if mensaje.TTL > 0 {
+		android.TTL = fmt.Sprintf("%ds", int64(mensaje.TTL/time.Second))
+	}
+
+	req, err := nuevaSolicitudJSON(config.ServidorAPI, mensajeFCM{Mensaje: cuerpoFCM{
+		Token:        mensaje.Token,
+		Notificacion: notificacionFCM{Titulo: mensaje.Titulo, Cuerpo: mensaje.Cuerpo},
+		Datos:        mensaje.Datos,
+		Android:      android,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.ClaveAPI)
+	return req, nil
+}
+
+// interpretarError lee el formato google.rpc.Status con el detalle FcmError
+func (adaptadorFCM) interpretarError(estado int, cuerpo []byte) *ErrorProveedorPush {
+	var respuesta struct {
+		Error struct {
+			Mensaje  string `json:"message"`
+			Estado   string `json:"status"`
+			Detalles []struct {
+				Codigo string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.Unmarshal(cuerpo, &respuesta)
+
+	codigo := respuesta.Error.Estado
+	for _, detalle := range respuesta.Error.Detalles {
+		if detalle.Codigo != "" {
+			codigo = detalle.Codigo
+			break
+		}
+	}
+
+	return &ErrorProveedorPush{
+		Proveedor:     ProveedorFCM,
+		Estado:        estado,
+		Codigo:        codigo,
+		Mensaje:       respuesta.Error.Mensaje,
+		TokenInvalido: codigo == "UNREGISTERED" || (codigo == "INVALID_ARGUMENT" && strings.Contains(strings.ToLower(respuesta.Error.Mensaje), "registration token")),
+	}
+}
+
+// adaptadorAPNs arma mensajes para https://api.push.apple.com/3/device/<token>
+type adaptadorAPNs struct{}
+
+type alertaAPNs struct {
+	Titulo string `json:"title"`
+	Cuerpo string `json:"body"`
+}
+
+type apsAPNs struct {
+	Alerta alertaAPNs `json:"alert"`
+	Sonido string     `json:"sound,omitempty"`
+}
+
+func (adaptadorAPNs) solicitud(config ConfigPush, mensaje MensajePush) (*http.Request, error) {
+	// Los datos propios van en la raíz del payload junto a "aps"
+	cuerpo := map[string]interface{}{
+		"aps": apsAPNs{Alerta: alertaAPNs{Titulo: mensaje.Titulo, Cuerpo: mensaje.Cuerpo}, Sonido: "default"},
+	}
+	for clave, valor := range mensaje.Datos {
+		cuerpo[clave] = valor
+	}
+
+	// El token es un dato del destinatario; se escapa para que no altere la ruta ni agregue parámetros
+	direccion := strings.TrimSuffix(config.ServidorAPI, "/") + "/3/device/" + url.PathEscape(mensaje.Token)
+	req, err := nuevaSolicitudJSON(direccion, cuerpo)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "bearer "+config.ClaveAPI)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if mensaje.Prioridad == PrioridadNormal {
+		req.Header.Set("apns-priority", "5")
+	}
+	if config.Tema != "" {
+		req.Header.Set("apns-topic", config.Tema)
+	}
+	if mensaje.TTL > 0 {
+		req.Header.Set("apns-expiration", strconv.FormatInt(time.Now().Add(mensaje.TTL).Unix(), 10))
+	}
+	if mensaje.ClaveColapso != "" {
+		req.Header.Set("apns-collapse-id", mensaje.ClaveColapso)
+	}
+	return req, nil
+}
+
+// interpretarError lee el cuerpo {"reason": "..."} de APNs
+func (adaptadorAPNs) interpretarError(estado int, cuerpo []byte) *ErrorProveedorPush {
+	var respuesta struct {
+		Motivo string `json:"reason"`
+	}
+	json.Unmarshal(cuerpo, &respuesta)
+
+	return &ErrorProveedorPush{
+		Proveedor: ProveedorAPNs,
+		Estado:    estado,
+		Codigo:    respuesta.Motivo,
+		TokenInvalido: estado == http.StatusGone || respuesta.Motivo == "BadDeviceToken" ||
+			respuesta.Motivo == "Unregistered" || respuesta.Motivo == "DeviceTokenNotForTopic",
+	}
+}
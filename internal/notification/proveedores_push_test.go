@@ -0,0 +1,177 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"transport-challenge/internal/notification/notificationtest"
+)
+
+func nuevoServidorPushPrueba(t *testing.T) *notificationtest.ServidorPush {
+	servidor, err := notificationtest.IniciarServidorPush("", "clave")
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor push simulado: %v", err)
+	}
+	t.Cleanup(func() { servidor.Cerrar() })
+	return servidor
+}
+
+func TestPushFormatoFCM(t *testing.T) {
+	servidor := nuevoServidorPushPrueba(t)
+	servicio := NuevoServicioPush(ConfigPush{
+		ServidorAPI: servidor.URL() + "/v1/projects/demo/messages:send",
+		ClaveAPI:    "clave",
+		Proveedor:   ProveedorFCM,
+		TTL:         time.Hour,
+	})
+
+	notificacion := nuevaNotificacionPrueba(5)
+	notificacion.Destinatario = "token-fcm"
+	if err := servicio.Enviar(notificacion); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+
+	var cuerpo mensajeFCM
+	if err := servidor.Solicitudes()[0].Decodificar(&cuerpo); err != nil {
+		t.Fatalf("No se esperaba un error al decodificar: %v", err)
+	}
+	mensaje := cuerpo.Mensaje
+	if mensaje.Token != "token-fcm" || mensaje.Notificacion.Titulo == "" || mensaje.Notificacion.Cuerpo != "Compra en ruta" {
+		t.Errorf("Mensaje FCM inesperado: %+v", mensaje)
+	}
+	if mensaje.Datos["id_compra"] != "5" || mensaje.Datos["tipo"] != string(NotificacionCompraEnRuta) {
+		t.Errorf("Datos FCM inesperados: %+v", mensaje.Datos)
+	}
+	if mensaje.Android.Prioridad != "HIGH" || mensaje.Android.TTL != "3600s" || mensaje.Android.ClaveColapso != "compra-5" {
+		t.Errorf("Opciones Android inesperadas: %+v", mensaje.Android)
+	}
+}
+
+func TestPushFormatoAPNs(t *testing.T) {
+	servidor := nuevoServidorPushPrueba(t)
+	servicio := NuevoServicioPush(ConfigPush{
+		ServidorAPI: servidor.URL(),
+		ClaveAPI:    "clave",
+		Proveedor:   ProveedorAPNs,
+		Tema:        "com.ejemplo.transporte",
+		Prioridad:   PrioridadNormal,
+	})
+
+	notificacion := nuevaNotificacionPrueba(6)
+	notificacion.Destinatario = "token-apns"
+	if err := servicio.Enviar(notificacion); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+
+	solicitud := servidor.Solicitudes()[0]
+	if solicitud.Ruta != "/3/device/token-apns" {
+		t.Errorf("Ruta APNs inesperada: %s", solicitud.Ruta)
+	}
+	if solicitud.Encabezados.Get("apns-topic") != "com.ejemplo.transporte" || solicitud.Encabezados.Get("apns-priority") != "5" || solicitud.Encabezados.Get("apns-collapse-id") != "compra-6" {
+		t.Errorf("Encabezados APNs inesperados: %v", solicitud.Encabezados)
+	}
+
+	var cuerpo struct {
+		Aps      apsAPNs `json:"aps"`
+		IDCompra string  `json:"id_compra"`
+	}
+	if err := solicitud.Decodificar(&cuerpo); err != nil {
+		t.Fatalf("No se esperaba un error al decodificar: %v", err)
+	}
+	if cuerpo.Aps.Alerta.Cuerpo != "Compra en ruta" || cuerpo.IDCompra != "6" {
+		t.Errorf("Payload APNs inesperado: %+v", cuerpo)
+	}
+}
+
+func TestPushAPNsEscapaElToken(t *testing.T) {
+	var ruta, consulta string
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ruta, consulta = r.URL.EscapedPath(), r.URL.RawQuery
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "clave", Proveedor: ProveedorAPNs})
+
+	notificacion := nuevaNotificacionPrueba(6)
+	notificacion.Destinatario = "../../admin?borrar=1"
+	if err := servicio.Enviar(notificacion); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+
+	if ruta != "/3/device/..%2F..%2Fadmin%3Fborrar=1" || consulta != "" {
+		t.Errorf("Se esperaba el token escapado en la ruta, obtenida %q con consulta %q", ruta, consulta)
+	}
+}
+
+func TestPushAceptaCualquier2xx(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "clave"})
+	if err := servicio.Enviar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Errorf("No se esperaba un error con 202 Accepted: %v", err)
+	}
+}
+
+func TestPushInterpretaErroresDelProveedor(t *testing.T) {
+	servidor := nuevoServidorPushPrueba(t)
+	servidor.ProgramarFalla(notificationtest.FallaPush{
+		Codigo: http.StatusNotFound,
+		Cuerpo: `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`,
+		Veces:  1,
+	})
+
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL(), ClaveAPI: "clave", Proveedor: ProveedorFCM})
+	detalle, err := servicio.EnviarConDetalle(nuevaNotificacionPrueba(1))
+
+	if !errors.Is(err, ErrTokenInvalido) {
+		t.Fatalf("Se esperaba ErrTokenInvalido, obtenido %v", err)
+	}
+	var errProveedor *ErrorProveedorPush
+	if !errors.As(err, &errProveedor) || errProveedor.Codigo != "UNREGISTERED" || errProveedor.Estado != http.StatusNotFound {
+		t.Errorf("Error del proveedor inesperado: %+v", errProveedor)
+	}
+	if detalle.Intentos != 1 {
+		t.Errorf("No se esperaba reintentar un token inválido, intentos: %d", detalle.Intentos)
+	}
+}
+
+func TestServicioMarcaTokenInvalidoEnElContacto(t *testing.T) {
+	servidor := nuevoServidorPushPrueba(t)
+	servidor.ProgramarFalla(notificationtest.FallaPush{Codigo: http.StatusGone, Cuerpo: `{"reason":"Unregistered"}`, Veces: 1})
+
+	contactos := NuevoAlmacenContactosMemoria()
+	contactos.Guardar(Contacto{ID: "cliente-1", TokenDispositivo: "token-viejo"})
+
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalSMS),
+		ConCanal(NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL(), ClaveAPI: "clave", Proveedor: ProveedorAPNs, Tema: "com.ejemplo"}), true),
+		ConContactos(contactos),
+	)
+
+	notificacion := nuevaNotificacionPrueba(1)
+	notificacion.Destinatario = "cliente-1"
+	if err := servicio.Notificar(notificacion); err == nil {
+		t.Fatal("Se esperaba un error por el token inválido")
+	}
+
+	contacto, _ := contactos.Obtener("cliente-1")
+	if !contacto.TokenInvalido {
+		t.Fatal("Se esperaba el token marcado como inválido")
+	}
+
+	resultados := servicio.NotificarConResultados(notificacion)
+	if len(resultados) != 1 || resultados[0].Omitido == "" {
+		t.Errorf("Se esperaba omitir el push con token inválido: %+v", resultados)
+	}
+	if len(servidor.Solicitudes()) != 1 {
+		t.Errorf("No se esperaba otra solicitud al proveedor, obtenidas %d", len(servidor.Solicitudes()))
+	}
+}
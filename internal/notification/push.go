@@ -1,67 +1,149 @@
 package notification
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// timeoutPushPorDefecto se usa cuando la configuración no define un timeout
+const timeoutPushPorDefecto = 10 * time.Second
+
+// maxCuerpoErrorPush limita lo que se lee de una respuesta de error del proveedor
+const maxCuerpoErrorPush = 64 << 10
+
 // ServicioPush maneja el envío de notificaciones push
 type ServicioPush struct {
-	config ConfigPush
+	config       ConfigPush
+	cliente      *http.Client
+	reintentos   PoliticaReintentos
+	disyuntor    *Disyuntor
+	dormir       func(time.Duration)
+	plantillas   *MotorPlantillas
+	adaptador    adaptadorPush
+	errAdaptador error
 }
 
+// Payload es el cuerpo que recibe la API push del proveedor genérico
 type Payload struct {
 	IDCompra     int    `json:"id_compra"`
 	Tipo         string `json:"tipo"`
+	Titulo       string `json:"titulo,omitempty"`
 	Descripcion  string `json:"descripcion"`
 	Destinatario string `json:"destinatario"`
+	Idioma       string `json:"idioma,omitempty"`
 }
 
-func NuevoServicioPush(config ConfigPush) *ServicioPush {
+func NuevoServicioPush(config ConfigPush, opciones ...OpcionEnvio) *ServicioPush {
+	o := nuevasOpcionesEnvio(opciones)
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = timeoutPushPorDefecto
+	}
+
+	adaptador, err := nuevoAdaptadorPush(config.Proveedor)
+
 	return &ServicioPush{
-		config: config,
+		config:       config,
+		cliente:      &http.Client{Timeout: timeout},
+		reintentos:   o.reintentos,
+		disyuntor:    NuevoDisyuntor(o.disyuntor),
+		dormir:       o.dormir,
+		plantillas:   o.plantillas,
+		adaptador:    adaptador,
+		errAdaptador: err,
 	}
 }
 
+// Nombre identifica al canal en el registro
+func (s *ServicioPush) Nombre() string {
+	return CanalPush
+}
+
+// EstadoDisyuntor devuelve el estado del disyuntor del canal push
+func (s *ServicioPush) EstadoDisyuntor() InstantaneaDisyuntor {
+	return s.disyuntor.Instantanea()
+}
+
 func (s *ServicioPush) Enviar(notificacion Notificacion) error {
+	_, err := s.EnviarConDetalle(notificacion)
+	return err
+}
+
+// EnviarConDetalle envía la notificación e informa los intentos y el estado HTTP del servidor push.
+// Si el proveedor rechaza el token el error satisface errors.Is(err, ErrTokenInvalido).
+func (s *ServicioPush) EnviarConDetalle(notificacion Notificacion) (DetalleEnvio, error) {
 
 	if s.config.ServidorAPI == "" || s.config.ClaveAPI == "" {
-		return fmt.Errorf("configuración de push incompleta")
+		return DetalleEnvio{}, fmt.Errorf("configuración de push incompleta")
 	}
-
-	payload := Payload{
-		IDCompra:     notificacion.IDCompra,
-		Tipo:         string(notificacion.Tipo),
-		Descripcion:  notificacion.Descripcion,
-		Destinatario: notificacion.Destinatario,
+	if s.errAdaptador != nil {
+		return DetalleEnvio{}, s.errAdaptador
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	// El título es el asunto de la plantilla en el idioma del destinatario
+	renderizado, err := s.plantillas.Renderizar(notificacion)
 	if err != nil {
-		return fmt.Errorf("error al serializar payload: %w", err)
+		return DetalleEnvio{}, err
 	}
 
-	req, err := http.NewRequest("POST", s.config.ServidorAPI, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return fmt.Errorf("error al crear solicitud HTTP: %w", err)
+	mensaje := MensajePush{
+		Token:  notificacion.Destinatario,
+		Titulo: renderizado.Asunto,
+		Cuerpo: notificacion.Descripcion,
+		Datos: map[string]string{
+			"id_compra": strconv.Itoa(notificacion.IDCompra),
+			"tipo":      string(notificacion.Tipo),
+		},
+		Prioridad: s.config.Prioridad,
+		TTL:       s.config.TTL,
+		// Las actualizaciones de una misma compra reemplazan a la anterior en el dispositivo
+		ClaveColapso: fmt.Sprintf("compra-%d", notificacion.IDCompra),
 	}
+	if notificacion.Idioma != "" {
+		mensaje.Datos["idioma"] = notificacion.Idioma
+	}
+
+	var respuesta string
+	intentos, err := ejecutarContandoIntentos(s.reintentos, s.disyuntor, s.dormir, func() error {
+		var err error
+		respuesta, err = s.enviarSolicitud(mensaje)
+		return err
+	})
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.ClaveAPI))
+	return DetalleEnvio{Intentos: intentos, Respuesta: respuesta}, err
+}
 
-	cliente := &http.Client{}
+// enviarSolicitud realiza un único intento de envío al servidor push y devuelve el estado HTTP recibido.
+// Cualquier respuesta 2xx se considera aceptada.
+func (s *ServicioPush) enviarSolicitud(mensaje MensajePush) (string, error) {
+	req, err := s.adaptador.solicitud(s.config, mensaje)
+	if err != nil {
+		return "", err
+	}
 
-	resp, err := cliente.Do(req)
+	resp, err := s.cliente.Do(req)
 	if err != nil {
-		return fmt.Errorf("error al enviar notificación push: %w", err)
+		return "", &ErrorReintentable{Err: fmt.Errorf("error al enviar notificación push: %w", err)}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error en respuesta push: código de estado %d", resp.StatusCode)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.Status, nil
 	}
 
-	return nil
+	cuerpo, _ := io.ReadAll(io.LimitReader(resp.Body, maxCuerpoErrorPush))
+	errProveedor := s.adaptador.interpretarError(resp.StatusCode, cuerpo)
+	if !errProveedor.TokenInvalido && s.reintentos.EsCodigoReintentable(resp.StatusCode) {
+		return resp.Status, &ErrorReintentable{
+			Err:    errProveedor,
+			Espera: esperaRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return resp.Status, errProveedor
 }
+
+var _ CanalDetallado = &ServicioPush{}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"transport-challenge/internal/notification/notificationtest"
 )
 
 func TestNuevoServicioPush(t *testing.T) {
@@ -104,3 +107,33 @@ func TestEnviarPush(t *testing.T) {
 		})
 	}
 }
+
+func TestEnviarPushAServidorSimulado(t *testing.T) {
+	servidor, err := notificationtest.IniciarServidorPush("", "token_prueba")
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor push simulado: %v", err)
+	}
+	defer servidor.Cerrar()
+	servidor.ProgramarFalla(notificationtest.FallaPush{Codigo: http.StatusServiceUnavailable, RetryAfter: 1, Veces: 1})
+
+	var esperas []time.Duration
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL() + "/enviar", ClaveAPI: "token_prueba"})
+	servicio.dormir = func(espera time.Duration) { esperas = append(esperas, espera) }
+
+	detalle, err := servicio.EnviarConDetalle(nuevaNotificacionPrueba(77))
+	if err != nil {
+		t.Fatalf("No se esperaba un error tras el reintento: %v", err)
+	}
+	if detalle.Intentos != 2 || len(esperas) != 1 || esperas[0] != time.Second {
+		t.Errorf("Se esperaban 2 intentos con una espera de 1s según Retry-After, obtenidos %d y %v", detalle.Intentos, esperas)
+	}
+
+	solicitudes := servidor.Solicitudes()
+	if len(solicitudes) != 2 || solicitudes[1].Ruta != "/enviar" {
+		t.Fatalf("Solicitudes capturadas inesperadas: %+v", solicitudes)
+	}
+	var payload Payload
+	if err := solicitudes[1].Decodificar(&payload); err != nil || payload.IDCompra != 77 {
+		t.Errorf("Payload capturado inesperado: %+v (%v)", payload, err)
+	}
+}
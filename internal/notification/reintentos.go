@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PoliticaReintentos define cuántas veces y con qué espera se reintenta un envío.
+// RetardoMaximo también acota la espera que pide el proveedor con Retry-After.
+type PoliticaReintentos struct {
+	MaxIntentos          int
+	RetardoInicial       time.Duration
+	RetardoMaximo        time.Duration
+	Multiplicador        float64
+	Jitter               float64 // Fracción aleatoria (0 a 1) aplicada sobre cada espera
+	CodigosReintentables []int   // Códigos HTTP que justifican un nuevo intento
+}
+
+// PoliticaReintentosPorDefecto reintenta errores de red, 429 y 5xx con espera exponencial
+func PoliticaReintentosPorDefecto() PoliticaReintentos {
+	return PoliticaReintentos{
+		MaxIntentos:    3,
+		RetardoInicial: 200 * time.Millisecond,
+		RetardoMaximo:  5 * time.Second,
+		Multiplicador:  2,
+		Jitter:         0.2,
+		CodigosReintentables: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// EsCodigoReintentable indica si el código de estado HTTP admite un nuevo intento
+func (p PoliticaReintentos) EsCodigoReintentable(codigo int) bool {
+	for _, reintentable := range p.CodigosReintentables {
+		if reintentable == codigo {
+			return true
+		}
+	}
+	return false
+}
+
+// Retardo calcula la espera antes del intento siguiente al indicado (comenzando en 1)
+func (p PoliticaReintentos) Retardo(intento int) time.Duration {
+	multiplicador := p.Multiplicador
+	if multiplicador < 1 {
+		multiplicador = 1
+	}
+
+	retardo := float64(p.RetardoInicial) * math.Pow(multiplicador, float64(intento-1))
+	if p.RetardoMaximo > 0 && retardo > float64(p.RetardoMaximo) {
+		retardo = float64(p.RetardoMaximo)
+	}
+
+	// Se vuelve a acotar tras el jitter: en el máximo las esperas quedan dispersas por debajo de él
+	if p.Jitter > 0 {
+		retardo += retardo * p.Jitter * (rand.Float64()*2 - 1)
+		if p.RetardoMaximo > 0 && retardo > float64(p.RetardoMaximo) {
+			retardo = float64(p.RetardoMaximo)
+		}
+	}
+
+	return time.Duration(retardo)
+}
+
+// ErrorReintentable marca un error transitorio que puede resolverse con un nuevo intento
+type ErrorReintentable struct {
+	Err error
+	// Espera sugerida por el proveedor (por ejemplo, el encabezado Retry-After)
+	Espera time.Duration
+}
+
+func (e *ErrorReintentable) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrorReintentable) Unwrap() error {
+	return e.Err
+}
+
+// EsReintentable indica si el error admite un nuevo intento
+func EsReintentable(err error) bool {
+	var reintentable *ErrorReintentable
+	return errors.As(err, &reintentable)
+}
+
+// ejecutarConReintentos ejecuta la operación según la política, respetando el disyuntor del canal.
+// Solo los errores reintentables cuentan como fallos del proveedor para el disyuntor.
+func ejecutarConReintentos(politica PoliticaReintentos, disyuntor *Disyuntor, dormir func(time.Duration), operacion func() error) error {
+	_, err := ejecutarContandoIntentos(politica, disyuntor, dormir, operacion)
+	return err
+}
+
+// ejecutarContandoIntentos es ejecutarConReintentos informando además cuántas veces se ejecutó la operación
+func ejecutarContandoIntentos(politica PoliticaReintentos, disyuntor *Disyuntor, dormir func(time.Duration), operacion func() error) (int, error) {
+	maxIntentos := politica.MaxIntentos
+	if maxIntentos < 1 {
+		maxIntentos = 1
+	}
+
+	var err error
+	intentos := 0
+	for intento := 1; intento <= maxIntentos; intento++ {
+		if disyuntor != nil && !disyuntor.Permitir() {
+			if err != nil {
+				return intentos, fmt.Errorf("%w (último error: %v)", ErrCircuitoAbierto, err)
+			}
+			return intentos, ErrCircuitoAbierto
+		}
+
+		err = operacion()
+		intentos++
+
+		var reintentable *ErrorReintentable
+		if !errors.As(err, &reintentable) {
+			if disyuntor != nil {
+				disyuntor.RegistrarExito()
+			}
+			return intentos, err
+		}
+
+		if disyuntor != nil {
+			disyuntor.RegistrarFallo()
+		}
+
+		if intento == maxIntentos {
+			break
+		}
+
+		// Una espera pedida por el proveedor mayor al retardo máximo no se cumple aquí, para no
+		// retener la goroutine ni el cupo de concurrencia del canal: la bandeja de salida reprograma el envío
+		espera := reintentable.Espera
+		if politica.RetardoMaximo > 0 && espera > politica.RetardoMaximo {
+			return intentos, fmt.Errorf("el proveedor pidió esperar %v, más que el retardo máximo de %v: %w", espera, politica.RetardoMaximo, err)
+		}
+		if espera <= 0 {
+			espera = politica.Retardo(intento)
+		}
+		dormir(espera)
+	}
+
+	return intentos, fmt.Errorf("envío fallido tras %d intentos: %w", maxIntentos, err)
+}
+
+// esperaRetryAfter interpreta el encabezado Retry-After en segundos o como fecha HTTP
+func esperaRetryAfter(valor string, ahora time.Time) time.Duration {
+	if valor == "" {
+		return 0
+	}
+
+	if segundos, err := strconv.Atoi(valor); err == nil {
+		if segundos < 0 {
+			return 0
+		}
+		return time.Duration(segundos) * time.Second
+	}
+
+	if fecha, err := http.ParseTime(valor); err == nil && fecha.After(ahora) {
+		return fecha.Sub(ahora)
+	}
+
+	return 0
+}
@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoliticaReintentosRetardo(t *testing.T) {
+	politica := PoliticaReintentos{
+		RetardoInicial: 100 * time.Millisecond,
+		RetardoMaximo:  time.Second,
+		Multiplicador:  2,
+	}
+
+	esperados := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+	}
+
+	for i, esperado := range esperados {
+		if obtenido := politica.Retardo(i + 1); obtenido != esperado {
+			t.Errorf("Retardo del intento %d incorrecto. Esperado: %v, Obtenido: %v", i+1, esperado, obtenido)
+		}
+	}
+
+	politica.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		retardo := politica.Retardo(1)
+		if retardo < 50*time.Millisecond || retardo > 150*time.Millisecond {
+			t.Fatalf("Retardo con jitter fuera de rango: %v", retardo)
+		}
+	}
+}
+
+func TestPoliticaReintentosJitterNoSuperaElMaximo(t *testing.T) {
+	politica := PoliticaReintentos{RetardoInicial: time.Second, RetardoMaximo: time.Second, Multiplicador: 2, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		retardo := politica.Retardo(5)
+		if retardo > time.Second || retardo < 500*time.Millisecond {
+			t.Fatalf("Retardo con jitter fuera de rango [500ms, 1s]: %v", retardo)
+		}
+	}
+}
+
+func TestEjecutarConReintentos(t *testing.T) {
+	politica := PoliticaReintentos{MaxIntentos: 3, RetardoInicial: time.Second, Multiplicador: 2}
+	var esperas []time.Duration
+	dormir := func(d time.Duration) { esperas = append(esperas, d) }
+
+	intentos := 0
+	err := ejecutarConReintentos(politica, nil, dormir, func() error {
+		intentos++
+		if intentos < 3 {
+			return &ErrorReintentable{Err: errors.New("temporal")}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if len(esperas) != 2 || esperas[0] != time.Second || esperas[1] != 2*time.Second {
+		t.Errorf("Esperas incorrectas: %v", esperas)
+	}
+
+	intentos = 0
+	err = ejecutarConReintentos(politica, nil, dormir, func() error {
+		intentos++
+		return errors.New("permanente")
+	})
+	if err == nil || intentos != 1 {
+		t.Errorf("Se esperaba un único intento ante un error no reintentable, intentos: %d", intentos)
+	}
+}
+
+func TestEjecutarConReintentosNoEsperaMasQueElRetardoMaximo(t *testing.T) {
+	politica := PoliticaReintentos{MaxIntentos: 3, RetardoInicial: time.Second, RetardoMaximo: 5 * time.Second}
+	var esperas []time.Duration
+	dormir := func(d time.Duration) { esperas = append(esperas, d) }
+
+	intentos := 0
+	err := ejecutarConReintentos(politica, nil, dormir, func() error {
+		intentos++
+		return &ErrorReintentable{Err: errors.New("limite excedido"), Espera: time.Hour}
+	})
+
+	if !EsReintentable(err) {
+		t.Fatalf("Se esperaba devolver el error reintentable para reprogramar el envío: %v", err)
+	}
+	if intentos != 1 || len(esperas) != 0 {
+		t.Errorf("No se esperaba esperar una hora ni reintentar, intentos: %d, esperas: %v", intentos, esperas)
+	}
+}
+
+func TestEsperaRetryAfter(t *testing.T) {
+	ahora := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	if espera := esperaRetryAfter("3", ahora); espera != 3*time.Second {
+		t.Errorf("Espera en segundos incorrecta: %v", espera)
+	}
+
+	fecha := ahora.Add(time.Minute).Format(http.TimeFormat)
+	if espera := esperaRetryAfter(fecha, ahora); espera != time.Minute {
+		t.Errorf("Espera con fecha HTTP incorrecta: %v", espera)
+	}
+
+	if espera := esperaRetryAfter("mañana", ahora); espera != 0 {
+		t.Errorf("Se esperaba ignorar un Retry-After inválido: %v", espera)
+	}
+}
+
+func TestServicioPushReintentaRespetandoRetryAfter(t *testing.T) {
+	var llamadas int32
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&llamadas, 1) == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "token"})
+	var esperas []time.Duration
+	servicio.dormir = func(d time.Duration) { esperas = append(esperas, d) }
+
+	if err := servicio.Enviar(nuevaNotificacionPrueba(1)); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if llamadas != 2 {
+		t.Errorf("Se esperaban 2 llamadas, obtenidas: %d", llamadas)
+	}
+	if len(esperas) != 1 || esperas[0] != 3*time.Second {
+		t.Errorf("Se esperaba respetar Retry-After, esperas: %v", esperas)
+	}
+}
+
+func TestServicioPushNoReintentaErroresDelCliente(t *testing.T) {
+	var llamadas int32
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&llamadas, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioPush(ConfigPush{ServidorAPI: servidor.URL, ClaveAPI: "token"})
+	servicio.dormir = func(time.Duration) {}
+
+	if err := servicio.Enviar(nuevaNotificacionPrueba(1)); err == nil {
+		t.Fatal("Se esperaba un error ante una respuesta 400")
+	}
+	if llamadas != 1 {
+		t.Errorf("Se esperaba una sola llamada, obtenidas: %d", llamadas)
+	}
+}
@@ -0,0 +1,254 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// ElementoResumen es una notificación agrupada dentro de un resumen
+type ElementoResumen struct {
+	IDCompra    int              `json:"id_compra"`
+	Tipo        TipoNotificacion `json:"tipo"`
+	Descripcion string           `json:"descripcion"`
+	Ruta        *DatosRuta       `json:"ruta,omitempty"`
+	Fecha       time.Time        `json:"fecha"`
+}
+
+// ConfigResumen define cuándo se envía el resumen de cada destinatario: al vencer la ventana
+// desde su primera notificación o al alcanzar MaxNotificaciones, lo que ocurra primero.
+// Intervalo es cada cuánto se buscan ventanas vencidas.
+type ConfigResumen struct {
+	Ventana           time.Duration
+	MaxNotificaciones int
+	Intervalo         time.Duration
+}
+
+// ConfigResumenPorDefecto devuelve valores razonables para el agrupador
+func ConfigResumenPorDefecto() ConfigResumen {
+	return ConfigResumen{
+		Ventana:           5 * time.Minute,
+		MaxNotificaciones: 50,
+		Intervalo:         10 * time.Second,
+	}
+}
+
+type grupoResumen struct {
+	notificaciones []Notificacion
+	fechas         []time.Time
+	inicio         time.Time
+}
+
+// AgrupadorResumen acumula las notificaciones de cada destinatario y las envía como un único
+// resumen, por ejemplo un email al despachante con todas las compras de una ruta despachada.
+// Si el grupo tiene una sola notificación se envía sin cambios.
+type AgrupadorResumen struct {
+	notificador Notificador
+	config      ConfigResumen
+	plantillas  *MotorPlantillas
+	ahora       func() time.Time
+
+	mu     sync.Mutex
+	grupos map[string]*grupoResumen
+}
+
+// NuevoAgrupadorResumen crea el agrupador; plantillas traduce la descripción del resumen
+// y puede ser nil para usar las incluidas
+func NuevoAgrupadorResumen(notificador Notificador, config ConfigResumen, plantillas *MotorPlantillas) *AgrupadorResumen {
+	porDefecto := ConfigResumenPorDefecto()
+	if config.Ventana <= 0 {
+		config.Ventana = porDefecto.Ventana
+	}
+	if config.MaxNotificaciones <= 0 {
+		config.MaxNotificaciones = porDefecto.MaxNotificaciones
+	}
+	if config.Intervalo <= 0 {
+		config.Intervalo = config.Ventana
+	}
+	if plantillas == nil {
+		plantillas = NuevoMotorPlantillas()
+	}
+
+	return &AgrupadorResumen{
+		notificador: notificador,
+		config:      config,
+		plantillas:  plantillas,
+		ahora:       time.Now,
+		grupos:      make(map[string]*grupoResumen),
+	}
+}
+
+// Notificar agrega la notificación al grupo de su destinatario. Si el grupo alcanza
+// el máximo se envía el resumen en el momento y se devuelve el error del envío.
+func (a *AgrupadorResumen) Notificar(notificacion Notificacion) error {
+	a.mu.Lock()
+	ahora := a.ahora()
+	grupo, ok := a.grupos[notificacion.Destinatario]
+	if !ok {
+		grupo = &grupoResumen{inicio: ahora}
+		a.grupos[notificacion.Destinatario] = grupo
+	}
+	grupo.notificaciones = append(grupo.notificaciones, notificacion)
+	grupo.fechas = append(grupo.fechas, ahora)
+
+	var completo *grupoResumen
+	if len(grupo.notificaciones) >= a.config.MaxNotificaciones {
+		completo = grupo
+		delete(a.grupos, notificacion.Destinatario)
+	}
+	a.mu.Unlock()
+
+	if completo == nil {
+		return nil
+	}
+	return a.notificador.Notificar(a.resumir(completo))
+}
+
+// Iniciar envía los resúmenes vencidos periódicamente hasta que se cancele el contexto;
+// al terminar envía los grupos pendientes
+func (a *AgrupadorResumen) Iniciar(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.Vaciar()
+			return
+		case <-ticker.C:
+			a.ProcesarVencidos()
+		}
+	}
+}
+
+// ProcesarVencidos envía los resúmenes cuya ventana venció y devuelve cuántos se enviaron
+func (a *AgrupadorResumen) ProcesarVencidos() int {
+	limite := a.ahora().Add(-a.config.Ventana)
+	return a.enviarGrupos(func(grupo *grupoResumen) bool {
+		return !grupo.inicio.After(limite)
+	})
+}
+
+// Vaciar envía todos los resúmenes pendientes sin esperar su ventana y devuelve cuántos se enviaron
+func (a *AgrupadorResumen) Vaciar() int {
+	return a.enviarGrupos(func(*grupoResumen) bool { return true })
+}
+
+// Pendientes devuelve la cantidad de notificaciones acumuladas sin enviar
+func (a *AgrupadorResumen) Pendientes() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pendientes := 0
+	for _, grupo := range a.grupos {
+		pendientes += len(grupo.notificaciones)
+	}
+	return pendientes
+}
+
+// enviarGrupos retira los grupos seleccionados y los envía fuera del bloqueo. Los errores
+// se registran en el log; para reintentar, el notificador puede ser la bandeja de salida.
+func (a *AgrupadorResumen) enviarGrupos(seleccionar func(grupo *grupoResumen) bool) int {
+	a.mu.Lock()
+	var listos []*grupoResumen
+	for destinatario, grupo := range a.grupos {
+		if seleccionar(grupo) {
+			listos = append(listos, grupo)
+			delete(a.grupos, destinatario)
+		}
+	}
+	a.mu.Unlock()
+
+	enviados := 0
+	for _, grupo := range listos {
+		resumen := a.resumir(grupo)
+		if err := a.notificador.Notificar(resumen); err != nil {
+			log.Printf("Error al enviar el resumen de %d notificaciones a %s: %v", len(grupo.notificaciones), resumen.Destinatario, err)
+			continue
+		}
+		enviados++
+	}
+	return enviados
+}
+
+// resumir arma la notificación de resumen del grupo. La ruta se incluye solo si todas
+// las notificaciones corresponden a la misma.
+func (a *AgrupadorResumen) resumir(grupo *grupoResumen) Notificacion {
+	if len(grupo.notificaciones) == 1 {
+		return grupo.notificaciones[0]
+	}
+
+	primera := grupo.notificaciones[0]
+	resumen := Notificacion{
+		Tipo:         NotificacionResumen,
+		Destinatario: primera.Destinatario,
+		Telefono:     primera.Telefono,
+		Idioma:       primera.Idioma,
+		Ruta:         primera.Ruta,
+		Resumen:      make([]ElementoResumen, 0, len(grupo.notificaciones)),
+	}
+
+	for i, notificacion := range grupo.notificaciones {
+		if resumen.Ruta != nil && (notificacion.Ruta == nil || notificacion.Ruta.ID != resumen.Ruta.ID) {
+			resumen.Ruta = nil
+		}
+		resumen.Resumen = append(resumen.Resumen, ElementoResumen{
+			IDCompra:    notificacion.IDCompra,
+			Tipo:        notificacion.Tipo,
+			Descripcion: notificacion.Descripcion,
+			Ruta:        notificacion.Ruta,
+			Fecha:       grupo.fechas[i],
+		})
+	}
+	resumen.Descripcion = a.plantillas.Traducir(resumen.Idioma, "resumen.descripcion", len(resumen.Resumen))
+
+	return resumen
+}
+
+// DerivadorResumen envía por el agrupador las notificaciones de los destinatarios que reciben
+// resúmenes, como los despachantes, y el resto directamente por el notificador. Puede ser el
+// notificador del despachador de la bandeja o del pool; los resúmenes se envían por el
+// notificador del agrupador.
+type DerivadorResumen struct {
+	notificador   NotificadorPorCanal
+	agrupador     *AgrupadorResumen
+	destinatarios map[string]bool
+}
+
+func NuevoDerivadorResumen(notificador NotificadorPorCanal, agrupador *AgrupadorResumen, destinatarios []string) *DerivadorResumen {
+	d := &DerivadorResumen{
+		notificador:   notificador,
+		agrupador:     agrupador,
+		destinatarios: make(map[string]bool, len(destinatarios)),
+	}
+	for _, destinatario := range destinatarios {
+		d.destinatarios[destinatario] = true
+	}
+	return d
+}
+
+func (d *DerivadorResumen) Notificar(notificacion Notificacion) error {
+	if d.agrupa(notificacion) {
+		return d.agrupador.Notificar(notificacion)
+	}
+	return d.notificador.Notificar(notificacion)
+}
+
+// NotificarPorCanales agrupa la notificación sin informar resultados por canal, porque
+// los canales del resumen se resuelven al enviarlo
+func (d *DerivadorResumen) NotificarPorCanales(notificacion Notificacion, canales []string) ([]ResultadoCanal, error) {
+	if d.agrupa(notificacion) {
+		return nil, d.agrupador.Notificar(notificacion)
+	}
+	return d.notificador.NotificarPorCanales(notificacion, canales)
+}
+
+func (d *DerivadorResumen) agrupa(notificacion Notificacion) bool {
+	return notificacion.Tipo != NotificacionResumen && d.destinatarios[notificacion.Destinatario]
+}
+
+var (
+	_ Notificador         = &AgrupadorResumen{}
+	_ NotificadorPorCanal = &DerivadorResumen{}
+)
@@ -0,0 +1,178 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func nuevoAgrupadorPrueba(config ConfigResumen) (*AgrupadorResumen, *notificadorSimulado, *time.Time) {
+	ahora := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	notificador := &notificadorSimulado{}
+	agrupador := NuevoAgrupadorResumen(notificador, config, nil)
+	agrupador.ahora = func() time.Time { return ahora }
+	return agrupador, notificador, &ahora
+}
+
+func TestAgrupadorEnviaResumenAlVencerLaVentana(t *testing.T) {
+	agrupador, notificador, ahora := nuevoAgrupadorPrueba(ConfigResumen{Ventana: time.Minute, MaxNotificaciones: 10})
+	ruta := &DatosRuta{ID: 3, Nombre: "Zona Norte"}
+
+	for i := 1; i <= 3; i++ {
+		notificacion := nuevaNotificacionPrueba(i)
+		notificacion.Destinatario = "despacho@ejemplo.com"
+		notificacion.Ruta = ruta
+		agrupador.Notificar(notificacion)
+	}
+	agrupador.Notificar(nuevaNotificacionPrueba(9))
+
+	if enviados := agrupador.ProcesarVencidos(); enviados != 0 {
+		t.Fatalf("No se esperaban envíos antes de la ventana, obtenidos %d", enviados)
+	}
+
+	*ahora = ahora.Add(time.Minute)
+	if enviados := agrupador.ProcesarVencidos(); enviados != 2 {
+		t.Fatalf("Se esperaban 2 envíos, obtenidos %d", enviados)
+	}
+
+	var resumen, individual Notificacion
+	for _, enviada := range notificador.enviadas {
+		if enviada.Destinatario == "despacho@ejemplo.com" {
+			resumen = enviada
+		} else {
+			individual = enviada
+		}
+	}
+
+	if resumen.Tipo != NotificacionResumen || len(resumen.Resumen) != 3 {
+		t.Fatalf("Se esperaba un resumen con 3 compras: %+v", resumen)
+	}
+	if resumen.Ruta == nil || resumen.Ruta.ID != 3 {
+		t.Errorf("Se esperaba la ruta común en el resumen: %+v", resumen.Ruta)
+	}
+	if resumen.Descripcion != "Se actualizaron 3 compras." {
+		t.Errorf("Descripción inesperada: %q", resumen.Descripcion)
+	}
+	if individual.Tipo != NotificacionCompraEnRuta || individual.IDCompra != 9 {
+		t.Errorf("Se esperaba la notificación individual sin cambios: %+v", individual)
+	}
+	if agrupador.Pendientes() != 0 {
+		t.Errorf("No se esperaban notificaciones pendientes, obtenidas %d", agrupador.Pendientes())
+	}
+}
+
+func TestAgrupadorEnviaAlAlcanzarElMaximo(t *testing.T) {
+	agrupador, notificador, _ := nuevoAgrupadorPrueba(ConfigResumen{Ventana: time.Hour, MaxNotificaciones: 2})
+
+	agrupador.Notificar(nuevaNotificacionPrueba(1))
+	if len(notificador.enviadas) != 0 {
+		t.Fatal("No se esperaba un envío con una sola notificación")
+	}
+
+	segunda := nuevaNotificacionPrueba(2)
+	segunda.Ruta = &DatosRuta{ID: 1}
+	if err := agrupador.Notificar(segunda); err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+
+	if len(notificador.enviadas) != 1 || len(notificador.enviadas[0].Resumen) != 2 {
+		t.Fatalf("Se esperaba un resumen de 2 compras: %+v", notificador.enviadas)
+	}
+	if notificador.enviadas[0].Ruta != nil {
+		t.Error("No se esperaba ruta en un resumen de rutas distintas")
+	}
+}
+
+func TestAgrupadorReportaErrorDelEnvioPorMaximo(t *testing.T) {
+	agrupador, notificador, _ := nuevoAgrupadorPrueba(ConfigResumen{MaxNotificaciones: 2})
+	notificador.fallos = 1
+
+	agrupador.Notificar(nuevaNotificacionPrueba(1))
+	if err := agrupador.Notificar(nuevaNotificacionPrueba(2)); err == nil {
+		t.Error("Se esperaba el error del envío del resumen")
+	}
+}
+
+func TestRenderizarResumen(t *testing.T) {
+	motor := NuevoMotorPlantillas()
+	agrupador, notificador, _ := nuevoAgrupadorPrueba(ConfigResumen{MaxNotificaciones: 2})
+
+	primera := nuevaNotificacionPrueba(11)
+	segunda := nuevaNotificacionPrueba(12)
+	segunda.Tipo = NotificacionCompraEntregada
+	segunda.Descripcion = "Entregada en portería"
+	agrupador.Notificar(primera)
+	agrupador.Notificar(segunda)
+
+	renderizado, err := motor.Renderizar(notificador.enviadas[0])
+	if err != nil {
+		t.Fatalf("No se esperaba un error al renderizar: %v", err)
+	}
+
+	if renderizado.Asunto != "Resumen de 2 compras" {
+		t.Errorf("Asunto inesperado: %q", renderizado.Asunto)
+	}
+	for _, esperado := range []string{"#11: En camino", "#12: Entregada - Entregada en portería"} {
+		if !strings.Contains(renderizado.Texto, esperado) {
+			t.Errorf("Se esperaba %q en el texto:\n%s", esperado, renderizado.Texto)
+		}
+	}
+	if !strings.Contains(renderizado.HTML, "<td>#12</td><td>Entregada</td>") {
+		t.Errorf("Se esperaba la fila de la compra 12 en el HTML:\n%s", renderizado.HTML)
+	}
+}
+
+func TestClaveIdempotenciaDistingueResumenes(t *testing.T) {
+	primero := Notificacion{Tipo: NotificacionResumen, Destinatario: "despacho", Resumen: []ElementoResumen{{IDCompra: 1}, {IDCompra: 2}}}
+	segundo := Notificacion{Tipo: NotificacionResumen, Destinatario: "despacho", Resumen: []ElementoResumen{{IDCompra: 3}, {IDCompra: 4}}}
+
+	if primero.ClaveIdempotencia() == segundo.ClaveIdempotencia() {
+		t.Error("Se esperaban claves distintas para resúmenes de otras compras")
+	}
+}
+
+// notificadorPorCanalSimulado registra las notificaciones enviadas por canal
+type notificadorPorCanalSimulado struct {
+	notificadorSimulado
+}
+
+func (n *notificadorPorCanalSimulado) NotificarPorCanales(notificacion Notificacion, canales []string) ([]ResultadoCanal, error) {
+	return []ResultadoCanal{{Canal: CanalEmail}}, n.Notificar(notificacion)
+}
+
+func TestDerivadorResumenAgrupaLasDelDespachante(t *testing.T) {
+	notificador := &notificadorPorCanalSimulado{}
+	agrupador := NuevoAgrupadorResumen(notificador, ConfigResumen{Ventana: time.Hour, MaxNotificaciones: 10}, nil)
+	derivador := NuevoDerivadorResumen(notificador, agrupador, []string{"despacho@ejemplo.com"})
+
+	bandeja := NuevaBandejaSalida()
+	for i := 1; i <= 3; i++ {
+		notificacion := nuevaNotificacionPrueba(i)
+		notificacion.Destinatario = "despacho@ejemplo.com"
+		notificacion.Ruta = &DatosRuta{ID: 3, Nombre: "Zona Norte"}
+		bandeja.Agregar(notificacion)
+	}
+	bandeja.Agregar(nuevaNotificacionPrueba(9))
+
+	despachador := NuevoDespachadorBandeja(bandeja, derivador, ConfigDespachador{})
+	if enviadas := despachador.ProcesarPendientes(); enviadas != 4 {
+		t.Fatalf("Se esperaban 4 entradas procesadas, obtenidas %d", enviadas)
+	}
+	if len(notificador.enviadas) != 1 || notificador.enviadas[0].IDCompra != 9 {
+		t.Fatalf("Se esperaba enviar solo la notificación del cliente: %+v", notificador.enviadas)
+	}
+	if agrupador.Pendientes() != 3 {
+		t.Fatalf("Se esperaban 3 notificaciones agrupadas, obtenidas %d", agrupador.Pendientes())
+	}
+
+	agrupador.Vaciar()
+	resumen := notificador.enviadas[1]
+	if resumen.Tipo != NotificacionResumen || resumen.Destinatario != "despacho@ejemplo.com" || len(resumen.Resumen) != 3 {
+		t.Errorf("Se esperaba un único resumen para el despachante: %+v", resumen)
+	}
+
+	// Un resumen dirigido al despachante no vuelve a agruparse
+	if err := derivador.Notificar(resumen); err != nil || len(notificador.enviadas) != 3 || agrupador.Pendientes() != 0 {
+		t.Errorf("Se esperaba enviar el resumen directamente: %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package notification
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
 // TipoNotificacion representa los diferentes tipos de notificaciones
@@ -13,55 +16,465 @@ const (
 	NotificacionCompraEnRuta    TipoNotificacion = "COMPRA_EN_RUTA"
 	NotificacionCompraEntregada TipoNotificacion = "COMPRA_ENTREGADA"
 	NotificacionCompraEnError   TipoNotificacion = "COMPRA_EN_ERROR"
+
+	// NotificacionResumen agrupa varias notificaciones de un destinatario en un único mensaje
+	NotificacionResumen TipoNotificacion = "RESUMEN"
 )
 
 type Notificacion struct {
-	Tipo         TipoNotificacion
-	IDCompra     int
-	Descripcion  string
-	Destinatario string
+	Tipo         TipoNotificacion  `json:"tipo"`
+	IDCompra     int               `json:"id_compra"`
+	Descripcion  string            `json:"descripcion"`
+	Destinatario string            `json:"destinatario"`
+	Telefono     string            `json:"telefono,omitempty"`
+	Idioma       string            `json:"idioma,omitempty"` // Etiqueta de idioma del destinatario, por ejemplo "pt-BR"
+	Ruta         *DatosRuta        `json:"ruta,omitempty"`
+	Resumen      []ElementoResumen `json:"resumen,omitempty"` // Notificaciones agrupadas, solo en las de tipo RESUMEN
+}
+
+// DatosRuta describe la ruta de la compra para usar en las plantillas
+type DatosRuta struct {
+	ID        int    `json:"id"`
+	Nombre    string `json:"nombre"`
+	Conductor string `json:"conductor"`
+	Vehiculo  string `json:"vehiculo"`
 }
 
-// ServicioNotificaciones gestiona el envío de notificaciones
+// ServicioNotificaciones gestiona el envío de notificaciones por los canales registrados
 type ServicioNotificaciones struct {
-	config       ConfiguracionNotificaciones
-	emailService *ServicioEmail
-	pushService  *ServicioPush
+	config     ConfiguracionNotificaciones
+	canales    *RegistroCanales
+	plantillas *MotorPlantillas
+	contactos  AlmacenContactos
+	historial  AlmacenHistorial
+	limites    map[string]chan struct{}
+	ahora      func() time.Time
+
+	idempotencia         AlmacenIdempotencia
+	ventanaDeduplicacion time.Duration
 }
 
-func NuevoServicioNotificaciones(config ConfiguracionNotificaciones) *ServicioNotificaciones {
-	return &ServicioNotificaciones{
-		config:       config,
-		emailService: NuevoServicioEmail(config.ConfiguracionSMTP),
-		pushService:  NuevoServicioPush(config.ConfiguracionPush),
+// OpcionServicio modifica los canales y componentes del servicio al construirlo
+type OpcionServicio func(servicio *ServicioNotificaciones)
+
+// ConCanal agrega un canal; si ya existe uno con el mismo nombre lo reemplaza junto con su habilitación
+func ConCanal(canal Canal, habilitado bool) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		servicio.canales.Eliminar(canal.Nombre())
+		servicio.canales.Registrar(canal, habilitado)
 	}
 }
 
-func (s *ServicioNotificaciones) Notificar(notificacion Notificacion) error {
-	var errores []error
+// ReemplazarCanal cambia la implementación de un canal existente conservando su habilitación
+func ReemplazarCanal(canal Canal) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		if err := servicio.canales.Reemplazar(canal); err != nil {
+			log.Printf("Error al reemplazar canal: %v", err)
+		}
+	}
+}
+
+// SinCanal quita un canal del servicio
+func SinCanal(nombre string) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		servicio.canales.Eliminar(nombre)
+	}
+}
+
+// HabilitarCanal activa o desactiva un canal registrado
+func HabilitarCanal(nombre string, habilitado bool) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		if err := servicio.canales.Habilitar(nombre, habilitado); err != nil {
+			log.Printf("Error al habilitar canal: %v", err)
+		}
+	}
+}
+
+// ConLimiteConcurrencia limita los envíos simultáneos por un canal, por ejemplo
+// para no superar las conexiones permitidas por el servidor SMTP
+func ConLimiteConcurrencia(canal string, maximo int) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		if maximo <= 0 {
+			delete(servicio.limites, canal)
+			return
+		}
+		servicio.limites[canal] = make(chan struct{}, maximo)
+	}
+}
+
+// ConHistorial registra el resultado de cada envío por canal
+func ConHistorial(almacen AlmacenHistorial) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		servicio.historial = almacen
+	}
+}
+
+// ConDeduplicacion evita reenviar por un canal la misma notificación (compra, tipo y destinatario)
+// mientras no venza la ventana. Con ventana cero o negativa se usa la ventana por defecto.
+func ConDeduplicacion(almacen AlmacenIdempotencia, ventana time.Duration) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		if ventana <= 0 {
+			ventana = ventanaDeduplicacionPorDefecto
+		}
+		servicio.idempotencia = almacen
+		servicio.ventanaDeduplicacion = ventana
+	}
+}
+
+// ConContactos consulta las preferencias de cada destinatario antes de enviar
+func ConContactos(almacen AlmacenContactos) OpcionServicio {
+	return func(servicio *ServicioNotificaciones) {
+		servicio.contactos = almacen
+	}
+}
+
+func NuevoServicioNotificaciones(config ConfiguracionNotificaciones, opciones ...OpcionServicio) *ServicioNotificaciones {
+	plantillas := config.motorPlantillas()
+	opcionesEnvio := append(config.opcionesEnvio(), ConPlantillas(plantillas))
+
+	registro := NuevoRegistroCanales()
+	registro.Registrar(NuevoServicioEmail(config.ConfiguracionSMTP, opcionesEnvio...), config.EmailHabilitado)
+	registro.Registrar(NuevoServicioPush(config.ConfiguracionPush, opcionesEnvio...), config.PushHabilitado)
+	registro.Registrar(NuevoServicioSMS(config.ConfiguracionSMS, opcionesEnvio...), config.SMSHabilitado)
+
+	servicio := &ServicioNotificaciones{
+		config:     config,
+		canales:    registro,
+		plantillas: plantillas,
+		limites:    make(map[string]chan struct{}),
+		ahora:      time.Now,
+	}
 
-	if s.config.EmailHabilitado {
-		if err := s.emailService.Enviar(notificacion); err != nil {
-			errores = append(errores, fmt.Errorf("error en notificación por email: %w", err))
+	for canal, maximo := range config.ConcurrenciaPorCanal {
+		ConLimiteConcurrencia(canal, maximo)(servicio)
+	}
+
+	if config.VentanaDeduplicacion > 0 {
+		ConDeduplicacion(NuevoAlmacenIdempotenciaMemoria(), config.VentanaDeduplicacion)(servicio)
+	}
+
+	for _, opcion := range opciones {
+		opcion(servicio)
+	}
+
+	return servicio
+}
+
+// Plantillas devuelve el motor de plantillas en uso, que se recarga con RecargarPlantillas
+func (s *ServicioNotificaciones) Plantillas() *MotorPlantillas {
+	return s.plantillas
+}
+
+// Traducir devuelve el mensaje del catálogo de las plantillas en uso para el idioma indicado
+func (s *ServicioNotificaciones) Traducir(idioma, clave string, args ...interface{}) string {
+	return s.plantillas.Traducir(idioma, clave, args...)
+}
+
+// RecargarPlantillas vuelve a leer las plantillas del directorio configurado
+func (s *ServicioNotificaciones) RecargarPlantillas() error {
+	return s.plantillas.Recargar()
+}
+
+// Canales devuelve el registro de canales del servicio
+func (s *ServicioNotificaciones) Canales() *RegistroCanales {
+	return s.canales
+}
+
+// EstadoDisyuntores devuelve el estado del disyuntor de cada canal para monitoreo
+func (s *ServicioNotificaciones) EstadoDisyuntores() map[string]InstantaneaDisyuntor {
+	estados := make(map[string]InstantaneaDisyuntor)
+	for _, canal := range s.canales.Todos() {
+		if monitoreable, ok := canal.(interface{ EstadoDisyuntor() InstantaneaDisyuntor }); ok {
+			estados[canal.Nombre()] = monitoreable.EstadoDisyuntor()
+		}
+	}
+	return estados
+}
+
+// ResultadoCanal informa el resultado del envío por un canal. Omitido explica por qué
+// no se intentó el envío según las preferencias del destinatario.
+type ResultadoCanal struct {
+	Canal   string
+	Error   error
+	Omitido string
+}
+
+// ErrorNotificacion reúne los canales que fallaron al enviar una notificación
+type ErrorNotificacion struct {
+	Resultados []ResultadoCanal
+}
+
+func (e *ErrorNotificacion) Error() string {
+	mensajes := make([]string, 0, len(e.Resultados))
+	for _, resultado := range e.Fallidos() {
+		mensajes = append(mensajes, fmt.Sprintf("%s: %v", resultado.Canal, resultado.Error))
+	}
+	return "error al enviar notificaciones (" + strings.Join(mensajes, "; ") + ")"
+}
+
+// Fallidos devuelve solo los resultados con error
+func (e *ErrorNotificacion) Fallidos() []ResultadoCanal {
+	var fallidos []ResultadoCanal
+	for _, resultado := range e.Resultados {
+		if resultado.Error != nil {
+			fallidos = append(fallidos, resultado)
 		}
 	}
+	return fallidos
+}
 
-	if s.config.PushHabilitado {
-		if err := s.pushService.Enviar(notificacion); err != nil {
-			errores = append(errores, fmt.Errorf("error en notificación push: %w", err))
+// NotificarConResultados envía la notificación por cada canal habilitado y devuelve el resultado de cada uno
+func (s *ServicioNotificaciones) NotificarConResultados(notificacion Notificacion) []ResultadoCanal {
+	resultados, err := s.notificar(notificacion, nil)
+	if err != nil {
+		for _, canal := range s.canales.Habilitados() {
+			resultados = append(resultados, ResultadoCanal{Canal: canal.Nombre(), Error: err})
 		}
 	}
+	return resultados
+}
+
+// Notificar envía la notificación por los canales habilitados. Si algún canal falla
+// devuelve un *ErrorNotificacion con el resultado de cada canal; si el destinatario
+// está en su horario de silencio devuelve un *ErrorHorarioSilencio sin intentar el envío.
+func (s *ServicioNotificaciones) Notificar(notificacion Notificacion) error {
+	resultados, err := s.notificar(notificacion, nil)
+	if err != nil {
+		return err
+	}
 
-	if len(errores) > 0 {
-		for _, err := range errores {
-			log.Printf("Error de notificación: %v", err)
+	for _, resultado := range resultados {
+		if resultado.Error != nil {
+			return &ErrorNotificacion{Resultados: resultados}
 		}
-		return fmt.Errorf("ocurrieron %d errores al enviar notificaciones", len(errores))
 	}
 
 	return nil
 }
 
+// NotificarPorCanales envía la notificación solo por los canales habilitados indicados, o por
+// todos si canales es nil, y devuelve el resultado de cada uno. Permite reintentar únicamente
+// los canales que fallaron. El error indica que no se intentó ningún envío, por ejemplo
+// un *ErrorHorarioSilencio.
+func (s *ServicioNotificaciones) NotificarPorCanales(notificacion Notificacion, canales []string) ([]ResultadoCanal, error) {
+	return s.notificar(notificacion, canales)
+}
+
+// notificar aplica las preferencias del destinatario y envía por cada canal habilitado,
+// limitándose a soloCanales si no es nil. El error indica que no se intentó ningún envío.
+func (s *ServicioNotificaciones) notificar(notificacion Notificacion, soloCanales []string) ([]ResultadoCanal, error) {
+	contacto, err := s.buscarContacto(notificacion.Destinatario)
+	if err != nil {
+		return nil, err
+	}
+
+	if contacto != nil && contacto.HorarioSilencio != nil {
+		if activo, hasta := contacto.HorarioSilencio.Activo(s.ahora()); activo {
+			return nil, &ErrorHorarioSilencio{Destinatario: contacto.ID, Hasta: hasta}
+		}
+	}
+
+	canales := s.canales.Habilitados()
+	resultados := make([]ResultadoCanal, 0, len(canales))
+
+	for _, canal := range canales {
+		if soloCanales != nil && !contiene(soloCanales, canal.Nombre()) {
+			continue
+		}
+
+		envio := notificacion
+		if contacto != nil {
+			omitido := ""
+			switch {
+			case !contacto.AceptaTipo(notificacion.Tipo):
+				omitido = fmt.Sprintf("el destinatario no está suscripto a %s", notificacion.Tipo)
+			case !contacto.AceptaCanal(canal.Nombre()):
+				omitido = "el destinatario se dio de baja del canal"
+			case canal.Nombre() == CanalPush && contacto.TokenInvalido:
+				omitido = "el token de dispositivo del destinatario es inválido"
+			}
+
+			var tieneDireccion bool
+			if envio, tieneDireccion = contacto.direccionar(canal.Nombre(), notificacion); !tieneDireccion && omitido == "" {
+				omitido = "el destinatario no tiene dirección para el canal"
+			}
+
+			if omitido != "" {
+				resultado := ResultadoCanal{Canal: canal.Nombre(), Omitido: omitido}
+				s.registrarEnvio(envio, resultado, DetalleEnvio{})
+				resultados = append(resultados, resultado)
+				continue
+			}
+		}
+
+		// El destinatario es un email o un token de dispositivo, nunca un número al que enviar SMS
+		if canal.Nombre() == CanalSMS && envio.Telefono == "" {
+			resultado := ResultadoCanal{Canal: canal.Nombre(), Omitido: "el destinatario no tiene teléfono"}
+			s.registrarEnvio(envio, resultado, DetalleEnvio{})
+			resultados = append(resultados, resultado)
+			continue
+		}
+
+		clave, duplicada := s.reservarEnvio(notificacion, canal.Nombre())
+		if duplicada {
+			resultado := ResultadoCanal{Canal: canal.Nombre(), Omitido: "notificación duplicada dentro de la ventana de deduplicación"}
+			s.registrarEnvio(envio, resultado, DetalleEnvio{})
+			resultados = append(resultados, resultado)
+			continue
+		}
+
+		detalle, err := s.enviar(canal, envio)
+		if err != nil {
+			log.Printf("Error de notificación por %s: %v", canal.Nombre(), err)
+		}
+		if contacto != nil && errors.Is(err, ErrTokenInvalido) {
+			s.marcarTokenInvalido(contacto.ID, envio.Destinatario)
+		}
+		s.completarEnvio(clave, err)
+		resultado := ResultadoCanal{Canal: canal.Nombre(), Error: err}
+		s.registrarEnvio(envio, resultado, detalle)
+		resultados = append(resultados, resultado)
+	}
+
+	return resultados, nil
+}
+
+func contiene(valores []string, buscado string) bool {
+	for _, valor := range valores {
+		if valor == buscado {
+			return true
+		}
+	}
+	return false
+}
+
+// reservarEnvio reserva la clave de idempotencia de la notificación para el canal. Devuelve
+// true si ya se envió dentro de la ventana o hay otro envío en curso. Si el almacén falla
+// se envía igual, sin clave, para no perder la notificación.
+func (s *ServicioNotificaciones) reservarEnvio(notificacion Notificacion, canal string) (string, bool) {
+	if s.idempotencia == nil {
+		return "", false
+	}
+
+	clave := claveIdempotenciaCanal(notificacion, canal)
+	reservada, err := s.idempotencia.Reservar(clave, s.ahora().Add(s.ventanaDeduplicacion))
+	if err != nil {
+		log.Printf("Error al reservar la clave de idempotencia %s: %v", clave, err)
+		return "", false
+	}
+	return clave, !reservada
+}
+
+// completarEnvio confirma la clave si el envío fue exitoso o la libera para permitir el reintento
+func (s *ServicioNotificaciones) completarEnvio(clave string, errEnvio error) {
+	if clave == "" {
+		return
+	}
+
+	var err error
+	if errEnvio != nil {
+		err = s.idempotencia.Liberar(clave)
+	} else {
+		err = s.idempotencia.Confirmar(clave, s.ahora().Add(s.ventanaDeduplicacion))
+	}
+	if err != nil {
+		log.Printf("Error al actualizar la clave de idempotencia %s: %v", clave, err)
+	}
+}
+
+// enviar entrega por el canal respetando su límite de concurrencia
+func (s *ServicioNotificaciones) enviar(canal Canal, notificacion Notificacion) (DetalleEnvio, error) {
+	if limite, ok := s.limites[canal.Nombre()]; ok {
+		limite <- struct{}{}
+		defer func() { <-limite }()
+	}
+
+	if detallado, ok := canal.(CanalDetallado); ok {
+		return detallado.EnviarConDetalle(notificacion)
+	}
+	return DetalleEnvio{Intentos: 1}, canal.Enviar(notificacion)
+}
+
+// registrarEnvio guarda el resultado del canal en el historial, si está configurado
+func (s *ServicioNotificaciones) registrarEnvio(notificacion Notificacion, resultado ResultadoCanal, detalle DetalleEnvio) {
+	if s.historial == nil {
+		return
+	}
+
+	registro := RegistroEnvio{
+		IDCompra:     notificacion.IDCompra,
+		Tipo:         notificacion.Tipo,
+		Canal:        resultado.Canal,
+		Destinatario: notificacion.Destinatario,
+		Estado:       EnvioExitoso,
+		Intentos:     detalle.Intentos,
+		Respuesta:    detalle.Respuesta,
+		Motivo:       resultado.Omitido,
+		Fecha:        s.ahora(),
+	}
+	if resultado.Canal == CanalSMS && notificacion.Telefono != "" {
+		registro.Destinatario = notificacion.Telefono
+	}
+
+	switch {
+	case resultado.Omitido != "":
+		registro.Estado = EnvioOmitido
+	case resultado.Error != nil:
+		registro.Estado = EnvioFallido
+		registro.Error = resultado.Error.Error()
+	}
+
+	// Un resumen se registra una vez por compra incluida, para que el historial de cada compra lo muestre
+	registros := []RegistroEnvio{registro}
+	if notificacion.Tipo == NotificacionResumen && len(notificacion.Resumen) > 0 {
+		registros = registros[:0]
+		for _, elemento := range notificacion.Resumen {
+			registro.IDCompra = elemento.IDCompra
+			registro.Tipo = elemento.Tipo
+			registro.EnResumen = true
+			registros = append(registros, registro)
+		}
+	}
+
+	for _, registro := range registros {
+		if _, err := s.historial.Registrar(registro); err != nil {
+			log.Printf("Error al registrar el envío por %s de la compra %d: %v", resultado.Canal, registro.IDCompra, err)
+		}
+	}
+}
+
+// marcarTokenInvalido registra en el contacto que su token fue rechazado, salvo que ya lo haya cambiado
+func (s *ServicioNotificaciones) marcarTokenInvalido(id, token string) {
+	contacto, err := s.contactos.Obtener(id)
+	if err != nil || contacto.TokenDispositivo != token {
+		return
+	}
+
+	contacto.TokenInvalido = true
+	if _, err := s.contactos.Guardar(contacto); err != nil {
+		log.Printf("Error al marcar el token inválido de %s: %v", id, err)
+		return
+	}
+	log.Printf("Token de dispositivo de %s marcado como inválido", id)
+}
+
+// buscarContacto devuelve las preferencias del destinatario, o nil si no tiene registradas
+func (s *ServicioNotificaciones) buscarContacto(destinatario string) (*Contacto, error) {
+	if s.contactos == nil || destinatario == "" {
+		return nil, nil
+	}
+
+	contacto, err := s.contactos.Obtener(destinatario)
+	if errors.Is(err, ErrContactoNoEncontrado) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener preferencias de %s: %w", destinatario, err)
+	}
+
+	return &contacto, nil
+}
+
 func (s *ServicioNotificaciones) NotificarCambioEstadoCompra(idCompra int, tipo TipoNotificacion, descripcion string, destinatario string) error {
 	notificacion := Notificacion{
 		Tipo:         tipo,
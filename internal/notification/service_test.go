@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -11,6 +12,10 @@ type mockServicioEmail struct {
 	error   error
 }
 
+func (m *mockServicioEmail) Nombre() string {
+	return CanalEmail
+}
+
 func (m *mockServicioEmail) Enviar(notificacion Notificacion) error {
 	m.enviado = true
 	return m.error
@@ -21,6 +26,10 @@ type mockServicioPush struct {
 	error   error
 }
 
+func (m *mockServicioPush) Nombre() string {
+	return CanalPush
+}
+
 func (m *mockServicioPush) Enviar(notificacion Notificacion) error {
 	m.enviado = true
 	return m.error
@@ -101,11 +110,11 @@ func TestNotificar(t *testing.T) {
 				mockPush.error = fmt.Errorf("error simulado en push")
 			}
 
-			servicio := &ServicioNotificaciones{
-				config:       tc.configuracion,
-				emailService: mockEmail,
-				pushService:  mockPush,
-			}
+			servicio := NuevoServicioNotificaciones(
+				tc.configuracion,
+				ReemplazarCanal(mockEmail),
+				ReemplazarCanal(mockPush),
+			)
 
 			err := servicio.Notificar(tc.notificacion)
 
@@ -136,7 +145,9 @@ func TestNotificarCambioEstadoCompra(t *testing.T) {
 		PushHabilitado:  true,
 	}
 
-	servicio := NuevoServicioNotificaciones(config)
+	mockEmail := &mockServicioEmail{}
+	mockPush := &mockServicioPush{}
+	servicio := NuevoServicioNotificaciones(config, ReemplazarCanal(mockEmail), ReemplazarCanal(mockPush))
 
 	err := servicio.NotificarCambioEstadoCompra(
 		456,
@@ -148,4 +159,106 @@ func TestNotificarCambioEstadoCompra(t *testing.T) {
 	if err != nil {
 		t.Errorf("No se esperaba un error al notificar cambio de estado: %v", err)
 	}
+
+	if !mockEmail.enviado || !mockPush.enviado {
+		t.Errorf("Se esperaba que ambos canales enviaran la notificación")
+	}
+}
+
+// canalSimulado permite registrar canales adicionales en las pruebas
+type canalSimulado struct {
+	nombre  string
+	enviado int
+	error   error
+}
+
+func (c *canalSimulado) Nombre() string {
+	return c.nombre
+}
+
+func (c *canalSimulado) Enviar(notificacion Notificacion) error {
+	c.enviado++
+	return c.error
+}
+
+func TestNotificarInformaResultadoPorCanal(t *testing.T) {
+	fax := &canalSimulado{nombre: "fax", error: fmt.Errorf("sin papel")}
+	paloma := &canalSimulado{nombre: "paloma"}
+
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalPush),
+		ConCanal(fax, true),
+		ConCanal(paloma, true),
+	)
+
+	err := servicio.Notificar(Notificacion{Tipo: NotificacionCompraEnRuta, IDCompra: 1})
+
+	var errNotificacion *ErrorNotificacion
+	if !errors.As(err, &errNotificacion) {
+		t.Fatalf("Se esperaba un *ErrorNotificacion, obtenido: %v", err)
+	}
+	if len(errNotificacion.Resultados) != 2 {
+		t.Fatalf("Se esperaban 2 resultados, obtenidos: %d", len(errNotificacion.Resultados))
+	}
+
+	fallidos := errNotificacion.Fallidos()
+	if len(fallidos) != 1 || fallidos[0].Canal != "fax" {
+		t.Errorf("Se esperaba que solo fallara el canal fax: %+v", fallidos)
+	}
+	if paloma.enviado != 1 {
+		t.Errorf("Se esperaba que el canal paloma enviara aunque fax fallara")
+	}
+}
+
+func TestNotificarOmiteSMSSinTelefono(t *testing.T) {
+	sms := &canalSimulado{nombre: CanalSMS}
+
+	servicio := NuevoServicioNotificaciones(
+		ConfiguracionNotificaciones{},
+		SinCanal(CanalEmail),
+		SinCanal(CanalPush),
+		ConCanal(sms, true),
+	)
+
+	resultados := servicio.NotificarConResultados(Notificacion{Tipo: NotificacionCompraEnRuta, IDCompra: 1, Destinatario: "cliente@example.com"})
+
+	if len(resultados) != 1 || resultados[0].Omitido == "" {
+		t.Fatalf("Se esperaba omitir el canal SMS: %+v", resultados)
+	}
+	if sms.enviado != 0 {
+		t.Errorf("No se esperaba enviar un SMS al email del destinatario")
+	}
+}
+
+func TestRegistroCanales(t *testing.T) {
+	registro := NuevoRegistroCanales()
+	canal := &canalSimulado{nombre: "sms"}
+
+	if err := registro.Registrar(canal, false); err != nil {
+		t.Fatalf("No se esperaba un error al registrar: %v", err)
+	}
+	if err := registro.Registrar(canal, true); err == nil {
+		t.Errorf("Se esperaba un error al registrar un canal duplicado")
+	}
+	if len(registro.Habilitados()) != 0 {
+		t.Errorf("No se esperaban canales habilitados")
+	}
+
+	registro.Habilitar("sms", true)
+	reemplazo := &canalSimulado{nombre: "sms"}
+	registro.Reemplazar(reemplazo)
+
+	habilitados := registro.Habilitados()
+	if len(habilitados) != 1 || habilitados[0] != reemplazo {
+		t.Errorf("Se esperaba el canal reemplazado y habilitado: %+v", habilitados)
+	}
+
+	if err := registro.Eliminar("sms"); err != nil {
+		t.Errorf("No se esperaba un error al eliminar: %v", err)
+	}
+	if _, existe := registro.Obtener("sms"); existe {
+		t.Errorf("No se esperaba encontrar el canal eliminado")
+	}
 }
@@ -0,0 +1,305 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CanalSMS identifica al canal de mensajes de texto
+const CanalSMS = "sms"
+
+// timeoutSMSPorDefecto se usa cuando la configuración no define un timeout
+const timeoutSMSPorDefecto = 10 * time.Second
+
+// Límites de longitud de un SMS según su codificación
+const (
+	caracteresGSM7Simple      = 160
+	caracteresGSM7Segmento    = 153
+	caracteresUCS2Simple      = 70
+	caracteresUCS2Segmento    = 67
+	codificacionGSM7          = "GSM-7"
+	codificacionUCS2          = "UCS-2"
+	maxSegmentosSMSPorDefecto = 3
+)
+
+// alfabetoGSM7 contiene el alfabeto básico GSM 03.38; extensionGSM7 los caracteres que ocupan dos posiciones
+const (
+	alfabetoGSM7  = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	extensionGSM7 = "^{}\\[~]|€\f"
+)
+
+// MensajeSMS es el cuerpo enviado a la pasarela SMS. Todos los segmentos viajan
+// en la misma solicitud para que la pasarela los entregue como un único SMS
+// concatenado y un reintento no repita segmentos ya entregados.
+type MensajeSMS struct {
+	Destino        string   `json:"to"`
+	Remitente      string   `json:"from,omitempty"`
+	Texto          string   `json:"text"`
+	Segmentos      []string `json:"segments"`
+	TotalSegmentos int      `json:"total_segments"`
+	Codificacion   string   `json:"encoding"`
+}
+
+// PasarelaSMS abstrae al proveedor que entrega los mensajes de texto
+type PasarelaSMS interface {
+	EnviarSMS(mensaje MensajeSMS) error
+}
+
+// PasarelaHTTPSMS envía cada mensaje como JSON a una pasarela HTTP genérica
+type PasarelaHTTPSMS struct {
+	url        string
+	claveAPI   string
+	cliente    *http.Client
+	reintentos PoliticaReintentos
+}
+
+func NuevaPasarelaHTTPSMS(config ConfigSMS, reintentos PoliticaReintentos) *PasarelaHTTPSMS {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = timeoutSMSPorDefecto
+	}
+
+	return &PasarelaHTTPSMS{
+		url:        config.URLPasarela,
+		claveAPI:   config.ClaveAPI,
+		cliente:    &http.Client{Timeout: timeout},
+		reintentos: reintentos,
+	}
+}
+
+func (p *PasarelaHTTPSMS) EnviarSMS(mensaje MensajeSMS) error {
+	cuerpo, err := json.Marshal(mensaje)
+	if err != nil {
+		return fmt.Errorf("error al serializar SMS: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.url, bytes.NewBuffer(cuerpo))
+	if err != nil {
+		return fmt.Errorf("error al crear solicitud HTTP: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.claveAPI != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.claveAPI))
+	}
+
+	resp, err := p.cliente.Do(req)
+	if err != nil {
+		return &ErrorReintentable{Err: fmt.Errorf("error al enviar SMS: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("error en respuesta de la pasarela SMS: código de estado %d", resp.StatusCode)
+		if p.reintentos.EsCodigoReintentable(resp.StatusCode) {
+			return &ErrorReintentable{
+				Err:    err,
+				Espera: esperaRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ServicioSMS maneja el envío de notificaciones por mensaje de texto
+type ServicioSMS struct {
+	config     ConfigSMS
+	pasarela   PasarelaSMS
+	reintentos PoliticaReintentos
+	disyuntor  *Disyuntor
+	dormir     func(time.Duration)
+	plantillas *MotorPlantillas
+}
+
+func NuevoServicioSMS(config ConfigSMS, opciones ...OpcionEnvio) *ServicioSMS {
+	o := nuevasOpcionesEnvio(opciones)
+
+	return &ServicioSMS{
+		config:     config,
+		pasarela:   NuevaPasarelaHTTPSMS(config, o.reintentos),
+		reintentos: o.reintentos,
+		disyuntor:  NuevoDisyuntor(o.disyuntor),
+		dormir:     o.dormir,
+		plantillas: o.plantillas,
+	}
+}
+
+// Nombre identifica al canal en el registro
+func (s *ServicioSMS) Nombre() string {
+	return CanalSMS
+}
+
+// EstadoDisyuntor devuelve el estado del disyuntor del canal SMS
+func (s *ServicioSMS) EstadoDisyuntor() InstantaneaDisyuntor {
+	return s.disyuntor.Instantanea()
+}
+
+func (s *ServicioSMS) Enviar(notificacion Notificacion) error {
+	_, err := s.EnviarConDetalle(notificacion)
+	return err
+}
+
+// EnviarConDetalle envía el mensaje con todos sus segmentos e informa los intentos realizados
+func (s *ServicioSMS) EnviarConDetalle(notificacion Notificacion) (DetalleEnvio, error) {
+	if s.config.URLPasarela == "" {
+		return DetalleEnvio{}, fmt.Errorf("configuración de SMS incompleta")
+	}
+
+	if notificacion.Telefono == "" {
+		return DetalleEnvio{}, fmt.Errorf("la notificación no tiene teléfono")
+	}
+
+	destino, err := NormalizarTelefonoE164(notificacion.Telefono, s.config.CodigoPaisPorDefecto)
+	if err != nil {
+		return DetalleEnvio{}, err
+	}
+
+	texto := s.plantillas.Traducir(notificacion.Idioma, "sms.texto", notificacion.IDCompra, notificacion.Descripcion)
+	if notificacion.Tipo == NotificacionResumen {
+		texto = notificacion.Descripcion
+	}
+
+	maxSegmentos := s.config.MaxSegmentos
+	if maxSegmentos <= 0 {
+		maxSegmentos = maxSegmentosSMSPorDefecto
+	}
+
+	segmentos, codificacion := SegmentarSMS(texto, maxSegmentos)
+	mensaje := MensajeSMS{
+		Destino:        destino,
+		Remitente:      s.config.Remitente,
+		Texto:          strings.Join(segmentos, ""),
+		Segmentos:      segmentos,
+		TotalSegmentos: len(segmentos),
+		Codificacion:   codificacion,
+	}
+
+	var detalle DetalleEnvio
+	intentos, err := ejecutarContandoIntentos(s.reintentos, s.disyuntor, s.dormir, func() error {
+		return s.pasarela.EnviarSMS(mensaje)
+	})
+	detalle.Intentos = intentos
+	if err != nil {
+		return detalle, fmt.Errorf("error al enviar SMS de %d segmento(s): %w", len(segmentos), err)
+	}
+
+	detalle.Respuesta = fmt.Sprintf("%d segmento(s) %s aceptados por la pasarela para %s", len(segmentos), codificacion, destino)
+	return detalle, nil
+}
+
+// NormalizarTelefonoE164 convierte un número telefónico al formato E.164 (+<código país><número>).
+// Los números sin prefijo internacional usan el código de país indicado; se descarta el 0 troncal.
+func NormalizarTelefonoE164(numero string, codigoPaisPorDefecto string) (string, error) {
+	limpio := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '/':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(numero))
+
+	switch {
+	case strings.HasPrefix(limpio, "+"):
+		limpio = limpio[1:]
+	case strings.HasPrefix(limpio, "00"):
+		limpio = limpio[2:]
+	default:
+		if codigoPaisPorDefecto == "" {
+			return "", fmt.Errorf("número telefónico %q sin código de país", numero)
+		}
+		limpio = strings.TrimPrefix(codigoPaisPorDefecto, "+") + strings.TrimPrefix(limpio, "0")
+	}
+
+	if len(limpio) < 8 || len(limpio) > 15 || limpio[0] == '0' {
+		return "", fmt.Errorf("número telefónico %q no es válido", numero)
+	}
+
+	for _, r := range limpio {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("número telefónico %q no es válido", numero)
+		}
+	}
+
+	return "+" + limpio, nil
+}
+
+// SegmentarSMS divide el texto en segmentos según su codificación (GSM-7 o UCS-2)
+// y trunca el último segmento si el mensaje excede el máximo permitido
+func SegmentarSMS(texto string, maxSegmentos int) ([]string, string) {
+	codificacion := codificacionGSM7
+	limiteSimple, limiteSegmento := caracteresGSM7Simple, caracteresGSM7Segmento
+	if !esGSM7(texto) {
+		codificacion = codificacionUCS2
+		limiteSimple, limiteSegmento = caracteresUCS2Simple, caracteresUCS2Segmento
+	}
+
+	if longitudSMS(texto, codificacion) <= limiteSimple {
+		return []string{texto}, codificacion
+	}
+
+	var segmentos []string
+	var actual strings.Builder
+	ocupado := 0
+	for _, r := range texto {
+		tamano := longitudSMS(string(r), codificacion)
+		if ocupado+tamano > limiteSegmento {
+			segmentos = append(segmentos, actual.String())
+			actual.Reset()
+			ocupado = 0
+		}
+		actual.WriteRune(r)
+		ocupado += tamano
+	}
+	segmentos = append(segmentos, actual.String())
+
+	if maxSegmentos > 0 && len(segmentos) > maxSegmentos {
+		segmentos = segmentos[:maxSegmentos]
+		ultimo := []rune(segmentos[maxSegmentos-1])
+		segmentos[maxSegmentos-1] = string(ultimo[:len(ultimo)-3]) + "..."
+	}
+
+	return segmentos, codificacion
+}
+
+// esGSM7 indica si el texto puede codificarse con el alfabeto GSM de 7 bits
+func esGSM7(texto string) bool {
+	for _, r := range texto {
+		if !strings.ContainsRune(alfabetoGSM7, r) && !strings.ContainsRune(extensionGSM7, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// longitudSMS calcula las posiciones que ocupa el texto en la codificación indicada
+func longitudSMS(texto string, codificacion string) int {
+	if codificacion == codificacionUCS2 {
+		longitud := 0
+		for _, r := range texto {
+			// Los caracteres fuera del plano básico ocupan dos unidades UTF-16
+			if r > 0xFFFF {
+				longitud += 2
+			} else {
+				longitud++
+			}
+		}
+		return longitud
+	}
+
+	longitud := utf8.RuneCountInString(texto)
+	for _, r := range texto {
+		if strings.ContainsRune(extensionGSM7, r) {
+			longitud++
+		}
+	}
+	return longitud
+}
+
+var _ CanalDetallado = &ServicioSMS{}
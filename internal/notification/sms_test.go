@@ -0,0 +1,194 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizarTelefonoE164(t *testing.T) {
+	testCases := []struct {
+		nombre      string
+		numero      string
+		codigoPais  string
+		esperado    string
+		esperaError bool
+	}{
+		{nombre: "Formato internacional", numero: "+54 9 11 1234-5678", esperado: "+5491112345678"},
+		{nombre: "Prefijo 00", numero: "0055 (11) 98765-4321", esperado: "+5511987654321"},
+		{nombre: "Número local con 0 troncal", numero: "011 1234 5678", codigoPais: "54", esperado: "+541112345678"},
+		{nombre: "Número local con código con +", numero: "612345678", codigoPais: "+34", esperado: "+34612345678"},
+		{nombre: "Sin código de país", numero: "1112345678", esperaError: true},
+		{nombre: "Con letras", numero: "+54 11 CALL-NOW", esperaError: true},
+		{nombre: "Demasiado corto", numero: "+5411", esperaError: true},
+		{nombre: "Demasiado largo", numero: "+5491112345678901234", esperaError: true},
+		{nombre: "Email", numero: "cliente@ejemplo.com", codigoPais: "54", esperaError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.nombre, func(t *testing.T) {
+			obtenido, err := NormalizarTelefonoE164(tc.numero, tc.codigoPais)
+
+			if tc.esperaError {
+				if err == nil {
+					t.Errorf("Se esperaba un error para %q, obtenido: %s", tc.numero, obtenido)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No se esperaba un error: %v", err)
+			}
+			if obtenido != tc.esperado {
+				t.Errorf("Número incorrecto. Esperado: %s, Obtenido: %s", tc.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestSegmentarSMS(t *testing.T) {
+	segmentos, codificacion := SegmentarSMS("Tu compra fue entregada", 3)
+	if len(segmentos) != 1 || codificacion != "GSM-7" {
+		t.Errorf("Se esperaba un único segmento GSM-7: %v %s", segmentos, codificacion)
+	}
+
+	largo := strings.Repeat("a", 200)
+	segmentos, _ = SegmentarSMS(largo, 3)
+	if len(segmentos) != 2 || len(segmentos[0]) != 153 || len(segmentos[1]) != 47 {
+		t.Errorf("Segmentación GSM-7 incorrecta: %d segmentos", len(segmentos))
+	}
+
+	// Los caracteres de la extensión GSM ocupan dos posiciones
+	segmentos, _ = SegmentarSMS(strings.Repeat("€", 81), 3)
+	if len(segmentos) != 2 {
+		t.Errorf("Se esperaba que los caracteres extendidos ocuparan dos posiciones: %d segmentos", len(segmentos))
+	}
+
+	segmentos, codificacion = SegmentarSMS("Entrega confirmada ✅ "+strings.Repeat("b", 60), 3)
+	if codificacion != "UCS-2" || len(segmentos) != 2 {
+		t.Errorf("Se esperaba UCS-2 en 2 segmentos: %d %s", len(segmentos), codificacion)
+	}
+
+	segmentos, _ = SegmentarSMS(strings.Repeat("c", 1000), 2)
+	if len(segmentos) != 2 || !strings.HasSuffix(segmentos[1], "...") {
+		t.Errorf("Se esperaba truncar el mensaje a 2 segmentos: %d", len(segmentos))
+	}
+}
+
+func TestServicioSMSEnviaPorPasarelaHTTP(t *testing.T) {
+	var recibidos []MensajeSMS
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer clave_sms" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var mensaje MensajeSMS
+		json.NewDecoder(r.Body).Decode(&mensaje)
+		recibidos = append(recibidos, mensaje)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioSMS(ConfigSMS{
+		URLPasarela:          servidor.URL,
+		ClaveAPI:             "clave_sms",
+		Remitente:            "TRANSPORTE",
+		CodigoPaisPorDefecto: "54",
+	})
+
+	err := servicio.Enviar(Notificacion{
+		Tipo:         NotificacionCompraEnRuta,
+		IDCompra:     42,
+		Descripcion:  strings.Repeat("Tu compra está en camino. ", 4),
+		Destinatario: "cliente@ejemplo.com",
+		Telefono:     "011 1234-5678",
+	})
+
+	if err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if len(recibidos) != 1 {
+		t.Fatalf("Se esperaba una única solicitud con todos los segmentos, obtenidas: %d", len(recibidos))
+	}
+	if recibidos[0].Destino != "+541112345678" || recibidos[0].Remitente != "TRANSPORTE" {
+		t.Errorf("Mensaje incorrecto: %+v", recibidos[0])
+	}
+	if len(recibidos[0].Segmentos) != 2 || recibidos[0].TotalSegmentos != 2 {
+		t.Errorf("Segmentos incorrectos: %+v", recibidos[0])
+	}
+	if recibidos[0].Texto != strings.Join(recibidos[0].Segmentos, "") {
+		t.Errorf("El texto no coincide con los segmentos: %+v", recibidos[0])
+	}
+}
+
+func TestServicioSMSReintentoNoRepiteSegmentos(t *testing.T) {
+	solicitudes := 0
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		solicitudes++
+		var mensaje MensajeSMS
+		json.NewDecoder(r.Body).Decode(&mensaje)
+		if len(mensaje.Segmentos) != 2 {
+			t.Errorf("Se esperaban los 2 segmentos en cada solicitud, obtenidos: %d", len(mensaje.Segmentos))
+		}
+		if solicitudes == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioSMS(ConfigSMS{URLPasarela: servidor.URL})
+	servicio.dormir = func(time.Duration) {}
+
+	detalle, err := servicio.EnviarConDetalle(Notificacion{
+		IDCompra:    42,
+		Descripcion: strings.Repeat("Tu compra esta en camino. ", 8),
+		Telefono:    "+5491112345678",
+	})
+
+	if err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if solicitudes != 2 || detalle.Intentos != 2 {
+		t.Errorf("Se esperaban 2 solicitudes y 2 intentos, obtenidos: %d y %d", solicitudes, detalle.Intentos)
+	}
+}
+
+func TestServicioSMSTraduceTexto(t *testing.T) {
+	var recibido MensajeSMS
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&recibido)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer servidor.Close()
+
+	servicio := NuevoServicioSMS(ConfigSMS{URLPasarela: servidor.URL})
+	err := servicio.Enviar(Notificacion{
+		Tipo:        NotificacionCompraEntregada,
+		IDCompra:    7,
+		Descripcion: "Your order was delivered",
+		Telefono:    "+14155550100",
+		Idioma:      "en-US",
+	})
+
+	if err != nil {
+		t.Fatalf("No se esperaba un error: %v", err)
+	}
+	if recibido.Texto != "Order #7: Your order was delivered" {
+		t.Errorf("Texto inesperado: %q", recibido.Texto)
+	}
+}
+
+func TestServicioSMSRechazaTelefonoInvalido(t *testing.T) {
+	servicio := NuevoServicioSMS(ConfigSMS{URLPasarela: "http://localhost"})
+	servicio.dormir = func(time.Duration) {}
+
+	err := servicio.Enviar(Notificacion{IDCompra: 1, Destinatario: "cliente@ejemplo.com"})
+	if err == nil {
+		t.Error("Se esperaba un error para un destinatario sin teléfono válido")
+	}
+}
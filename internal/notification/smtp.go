@@ -0,0 +1,390 @@
+package notification
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SeguridadSMTP indica cómo se protege la conexión con el servidor SMTP
+type SeguridadSMTP string
+
+const (
+	// SeguridadTLS abre la conexión directamente sobre TLS (SMTPS, usualmente puerto 465)
+	SeguridadTLS SeguridadSMTP = "TLS"
+	// SeguridadSTARTTLS conecta en texto plano y exige pasar a TLS con STARTTLS (usualmente puerto 587)
+	SeguridadSTARTTLS SeguridadSMTP = "STARTTLS"
+	// SeguridadNinguna no cifra la conexión; solo para relays locales o de desarrollo
+	SeguridadNinguna SeguridadSMTP = "NINGUNA"
+)
+
+// MecanismoAuthSMTP es el mecanismo de autenticación SMTP
+type MecanismoAuthSMTP string
+
+const (
+	AuthPlain   MecanismoAuthSMTP = "PLAIN"
+	AuthLogin   MecanismoAuthSMTP = "LOGIN"
+	AuthCRAMMD5 MecanismoAuthSMTP = "CRAM-MD5"
+	// AuthNinguna no se autentica, para relays que aceptan por dirección de origen
+	AuthNinguna MecanismoAuthSMTP = "NINGUNA"
+)
+
+// Valores por defecto del transporte SMTP
+const (
+	timeoutConexionSMTP     = 10 * time.Second
+	timeoutOperacionSMTP    = 30 * time.Second
+	inactividadMaximaSMTP   = 30 * time.Second
+	conexionesInactivasSMTP = 2
+	mensajesPorConexionSMTP = 100
+	puertoSMTPTLS           = 465
+	puertoSMTPSTARTTLS      = 587
+	puertoSMTPSinCifrar     = 25
+)
+
+// seguridad devuelve el modo configurado; por defecto TLS implícito
+func (c ConfigSMTP) seguridad() SeguridadSMTP {
+	if c.Seguridad == "" {
+		return SeguridadTLS
+	}
+	return c.Seguridad
+}
+
+// autenticacion devuelve el mecanismo configurado; por defecto PLAIN
+func (c ConfigSMTP) autenticacion() MecanismoAuthSMTP {
+	if c.Autenticacion == "" {
+		return AuthPlain
+	}
+	return c.Autenticacion
+}
+
+// puerto devuelve el puerto configurado o el habitual del modo de seguridad
+func (c ConfigSMTP) puerto() int {
+	if c.Puerto > 0 {
+		return c.Puerto
+	}
+	return PuertoSMTPPorDefecto(c.seguridad())
+}
+
+// PuertoSMTPPorDefecto devuelve el puerto estándar de cada modo de seguridad
+func PuertoSMTPPorDefecto(seguridad SeguridadSMTP) int {
+	switch seguridad {
+	case SeguridadSTARTTLS:
+		return puertoSMTPSTARTTLS
+	case SeguridadNinguna:
+		return puertoSMTPSinCifrar
+	default:
+		return puertoSMTPTLS
+	}
+}
+
+func (c ConfigSMTP) direccion() string {
+	return net.JoinHostPort(c.Host, fmt.Sprint(c.puerto()))
+}
+
+// Validar verifica los modos de seguridad y autenticación y el archivo de autoridades certificantes
+func (c ConfigSMTP) Validar() error {
+	switch c.seguridad() {
+	case SeguridadTLS, SeguridadSTARTTLS, SeguridadNinguna:
+	default:
+		return fmt.Errorf("modo de seguridad SMTP desconocido: %q", c.Seguridad)
+	}
+
+	switch c.autenticacion() {
+	case AuthPlain, AuthLogin, AuthCRAMMD5:
+		if c.Usuario == "" {
+			return fmt.Errorf("usuario SMTP es requerido para la autenticación %s", c.autenticacion())
+		}
+	case AuthNinguna:
+	default:
+		return fmt.Errorf("mecanismo de autenticación SMTP desconocido: %q", c.Autenticacion)
+	}
+
+	if _, err := c.configTLS(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// configTLS arma la configuración TLS verificando el certificado del servidor contra las
+// autoridades del sistema o, si se indica, las del archivo PEM ArchivoCA
+func (c ConfigSMTP) configTLS() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         c.Host,
+		InsecureSkipVerify: c.OmitirVerificacion,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.ArchivoCA != "" {
+		contenido, err := os.ReadFile(c.ArchivoCA)
+		if err != nil {
+			return nil, fmt.Errorf("error al leer autoridades certificantes SMTP: %w", err)
+		}
+		autoridades := x509.NewCertPool()
+		if !autoridades.AppendCertsFromPEM(contenido) {
+			return nil, fmt.Errorf("el archivo %s no contiene certificados PEM válidos", c.ArchivoCA)
+		}
+		config.RootCAs = autoridades
+	}
+
+	return config, nil
+}
+
+// authSMTP devuelve el mecanismo de autenticación, o nil si no se autentica
+func (c ConfigSMTP) authSMTP() smtp.Auth {
+	switch c.autenticacion() {
+	case AuthNinguna:
+		return nil
+	case AuthLogin:
+		return &authLogin{usuario: c.Usuario, clave: c.Clave, host: c.Host}
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(c.Usuario, c.Clave)
+	default:
+		return smtp.PlainAuth("", c.Usuario, c.Clave, c.Host)
+	}
+}
+
+// authLogin implementa el mecanismo LOGIN, que net/smtp no incluye. Como PLAIN,
+// solo envía la clave sobre TLS o a un servidor local.
+type authLogin struct {
+	usuario string
+	clave   string
+	host    string
+}
+
+func (a *authLogin) Start(servidor *smtp.ServerInfo) (string, []byte, error) {
+	if !servidor.TLS && !esHostLocal(servidor.Name) {
+		return "", nil, errors.New("conexión sin cifrar")
+	}
+	if servidor.Name != a.host {
+		return "", nil, errors.New("nombre de host incorrecto")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *authLogin) Next(desafio []byte, mas bool) ([]byte, error) {
+	if !mas {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(desafio))) {
+	case "username:", "user name", "username":
+		return []byte(a.usuario), nil
+	case "password:", "password":
+		return []byte(a.clave), nil
+	}
+	return nil, fmt.Errorf("desafío LOGIN inesperado: %q", desafio)
+}
+
+func esHostLocal(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// conexionSMTP es una sesión SMTP autenticada que puede enviar varios mensajes
+type conexionSMTP struct {
+	red       net.Conn
+	cliente   *smtp.Client
+	mensajes  int
+	ultimoUso time.Time
+}
+
+func (c *conexionSMTP) cerrar() {
+	c.cliente.Close()
+}
+
+// transporteSMTP mantiene conexiones abiertas para reutilizarlas entre mensajes.
+// Las conexiones inactivas se cierran al superar la inactividad máxima.
+type transporteSMTP struct {
+	config ConfigSMTP
+	ahora  func() time.Time
+
+	mu        sync.Mutex
+	inactivas []*conexionSMTP
+}
+
+func nuevoTransporteSMTP(config ConfigSMTP) *transporteSMTP {
+	return &transporteSMTP{
+		config: config,
+		ahora:  time.Now,
+	}
+}
+
+// enviar entrega el mensaje por una conexión reutilizada o nueva
+func (t *transporteSMTP) enviar(remitente, destinatario, mensaje string) error {
+	conexion, err := t.obtener()
+	if err != nil {
+		return err
+	}
+
+	if err := t.transmitir(conexion, remitente, destinatario, mensaje); err != nil {
+		conexion.cerrar()
+		return err
+	}
+
+	t.devolver(conexion)
+	return nil
+}
+
+func (t *transporteSMTP) transmitir(conexion *conexionSMTP, remitente, destinatario, mensaje string) error {
+	conexion.red.SetDeadline(t.ahora().Add(t.timeoutOperacion()))
+
+	if err := conexion.cliente.Mail(remitente); err != nil {
+		return clasificarErrorSMTP(fmt.Errorf("error al configurar remitente: %w", err))
+	}
+
+	if err := conexion.cliente.Rcpt(destinatario); err != nil {
+		return clasificarErrorSMTP(fmt.Errorf("error al configurar destinatario: %w", err))
+	}
+
+	w, err := conexion.cliente.Data()
+	if err != nil {
+		return clasificarErrorSMTP(fmt.Errorf("error al preparar datos: %w", err))
+	}
+
+	if _, err := w.Write([]byte(mensaje)); err != nil {
+		return clasificarErrorSMTP(fmt.Errorf("error al escribir mensaje: %w", err))
+	}
+
+	if err := w.Close(); err != nil {
+		return clasificarErrorSMTP(fmt.Errorf("error al cerrar escritura: %w", err))
+	}
+
+	conexion.mensajes++
+	conexion.ultimoUso = t.ahora()
+	return nil
+}
+
+// obtener toma una conexión inactiva que siga respondiendo o abre una nueva
+func (t *transporteSMTP) obtener() (*conexionSMTP, error) {
+	for {
+		t.mu.Lock()
+		if len(t.inactivas) == 0 {
+			t.mu.Unlock()
+			return t.conectar()
+		}
+		conexion := t.inactivas[len(t.inactivas)-1]
+		t.inactivas = t.inactivas[:len(t.inactivas)-1]
+		t.mu.Unlock()
+
+		if t.ahora().Sub(conexion.ultimoUso) > inactividadMaximaSMTP {
+			conexion.cerrar()
+			continue
+		}
+
+		// RSET descarta el estado de la transacción anterior y verifica que el servidor no cerró la sesión
+		conexion.red.SetDeadline(t.ahora().Add(t.timeoutOperacion()))
+		if err := conexion.cliente.Reset(); err != nil {
+			conexion.cerrar()
+			continue
+		}
+		return conexion, nil
+	}
+}
+
+// devolver guarda la conexión para el próximo mensaje o la cierra si no hay lugar
+func (t *transporteSMTP) devolver(conexion *conexionSMTP) {
+	t.mu.Lock()
+	if conexion.mensajes < mensajesPorConexionSMTP && len(t.inactivas) < conexionesInactivasSMTP {
+		t.inactivas = append(t.inactivas, conexion)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	conexion.cliente.Quit()
+	conexion.cerrar()
+}
+
+// cerrar termina las sesiones inactivas
+func (t *transporteSMTP) cerrar() {
+	t.mu.Lock()
+	inactivas := t.inactivas
+	t.inactivas = nil
+	t.mu.Unlock()
+
+	for _, conexion := range inactivas {
+		conexion.cliente.Quit()
+		conexion.cerrar()
+	}
+}
+
+// conectar abre la conexión según el modo de seguridad y se autentica
+func (t *transporteSMTP) conectar() (*conexionSMTP, error) {
+	configTLS, err := t.config.configTLS()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := t.config.TimeoutConexion
+	if timeout <= 0 {
+		timeout = timeoutConexionSMTP
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var red net.Conn
+	if t.config.seguridad() == SeguridadTLS {
+		red, err = tls.DialWithDialer(dialer, "tcp", t.config.direccion(), configTLS)
+		if err != nil {
+			return nil, clasificarErrorConexion(fmt.Errorf("error al establecer conexión TLS: %w", err))
+		}
+	} else {
+		red, err = dialer.Dial("tcp", t.config.direccion())
+		if err != nil {
+			return nil, &ErrorReintentable{Err: fmt.Errorf("error al conectar con el servidor SMTP: %w", err)}
+		}
+	}
+	red.SetDeadline(t.ahora().Add(t.timeoutOperacion()))
+
+	cliente, err := smtp.NewClient(red, t.config.Host)
+	if err != nil {
+		red.Close()
+		return nil, clasificarErrorSMTP(fmt.Errorf("error al crear cliente SMTP: %w", err))
+	}
+
+	if t.config.seguridad() == SeguridadSTARTTLS {
+		if ok, _ := cliente.Extension("STARTTLS"); !ok {
+			cliente.Close()
+			return nil, errors.New("el servidor SMTP no ofrece STARTTLS")
+		}
+		if err := cliente.StartTLS(configTLS); err != nil {
+			cliente.Close()
+			return nil, clasificarErrorConexion(fmt.Errorf("error al iniciar STARTTLS: %w", err))
+		}
+	}
+
+	if auth := t.config.authSMTP(); auth != nil {
+		if err := cliente.Auth(auth); err != nil {
+			cliente.Close()
+			return nil, clasificarErrorSMTP(fmt.Errorf("error de autenticación: %w", err))
+		}
+	}
+
+	return &conexionSMTP{red: red, cliente: cliente, ultimoUso: t.ahora()}, nil
+}
+
+func (t *transporteSMTP) timeoutOperacion() time.Duration {
+	if t.config.TimeoutOperacion > 0 {
+		return t.config.TimeoutOperacion
+	}
+	return timeoutOperacionSMTP
+}
+
+// clasificarErrorConexion no reintenta los errores de verificación de certificados,
+// que no se resuelven solos, y trata el resto como errores de red
+func clasificarErrorConexion(err error) error {
+	var (
+		errAutoridad   x509.UnknownAuthorityError
+		errNombre      x509.HostnameError
+		errCertificado x509.CertificateInvalidError
+	)
+	if errors.As(err, &errAutoridad) || errors.As(err, &errNombre) || errors.As(err, &errCertificado) {
+		return err
+	}
+	return &ErrorReintentable{Err: err}
+}
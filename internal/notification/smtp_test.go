@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"errors"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"transport-challenge/internal/notification/notificationtest"
+)
+
+func TestTransporteSMTPVerificaCertificado(t *testing.T) {
+	servidor, err := notificationtest.IniciarServidorSMTP(notificationtest.ConfigServidorSMTP{TLS: true, Usuario: "usuario", Clave: "clave"})
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor SMTP simulado: %v", err)
+	}
+	defer servidor.Cerrar()
+
+	config := ConfigSMTP{
+		Host:             servidor.Host(),
+		Puerto:           servidor.Puerto(),
+		Usuario:          "usuario",
+		Clave:            "clave",
+		Seguridad:        SeguridadTLS,
+		TimeoutOperacion: time.Second,
+	}
+
+	err = nuevoTransporteSMTP(config).enviar("remitente@ejemplo.com", "cliente@ejemplo.com", "Subject: hola\r\n\r\nhola\r\n")
+	if err == nil {
+		t.Fatal("Se esperaba un error con un certificado no confiable")
+	}
+	var reintentable *ErrorReintentable
+	if errors.As(err, &reintentable) {
+		t.Errorf("No se esperaba reintentar un error de certificado: %v", err)
+	}
+
+	config.ArchivoCA = servidor.ArchivoCA()
+	if err := nuevoTransporteSMTP(config).enviar("remitente@ejemplo.com", "cliente@ejemplo.com", "Subject: hola\r\n\r\nhola\r\n"); err != nil {
+		t.Fatalf("No se esperaba un error con la CA propia: %v", err)
+	}
+}
+
+func TestTransporteSMTPReutilizaConexion(t *testing.T) {
+	servidor, err := notificationtest.IniciarServidorSMTP(notificationtest.ConfigServidorSMTP{TLS: true, Usuario: "usuario", Clave: "clave"})
+	if err != nil {
+		t.Fatalf("No se esperaba un error al iniciar el servidor SMTP simulado: %v", err)
+	}
+	defer servidor.Cerrar()
+
+	transporte := nuevoTransporteSMTP(ConfigSMTP{
+		Host:          servidor.Host(),
+		Puerto:        servidor.Puerto(),
+		Usuario:       "usuario",
+		Clave:         "clave",
+		Autenticacion: AuthLogin,
+		ArchivoCA:     servidor.ArchivoCA(),
+	})
+	defer transporte.cerrar()
+
+	for i := 0; i < 3; i++ {
+		if err := transporte.enviar("remitente@ejemplo.com", "cliente@ejemplo.com", "Subject: hola\r\n\r\nhola\r\n"); err != nil {
+			t.Fatalf("No se esperaba un error en el envío %d: %v", i+1, err)
+		}
+	}
+
+	if len(servidor.Mensajes()) != 3 || servidor.Conexiones() != 1 {
+		t.Errorf("Se esperaban 3 mensajes por 1 conexión, obtenidos %d por %d", len(servidor.Mensajes()), servidor.Conexiones())
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	auth := &authLogin{usuario: "usuario", clave: "clave", host: "smtp.ejemplo.com"}
+
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.ejemplo.com"}); err == nil {
+		t.Error("Se esperaba un error al autenticar sin cifrado")
+	}
+	if mecanismo, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.ejemplo.com", TLS: true}); err != nil || mecanismo != "LOGIN" {
+		t.Errorf("Se esperaba el mecanismo LOGIN, obtenido %q (%v)", mecanismo, err)
+	}
+
+	if respuesta, _ := auth.Next([]byte("Username:"), true); string(respuesta) != "usuario" {
+		t.Errorf("Respuesta inesperada al usuario: %q", respuesta)
+	}
+	if respuesta, _ := auth.Next([]byte("Password:"), true); string(respuesta) != "clave" {
+		t.Errorf("Respuesta inesperada a la clave: %q", respuesta)
+	}
+}
+
+func TestValidarConfigSMTP(t *testing.T) {
+	testCases := []struct {
+		nombre      string
+		config      ConfigSMTP
+		esperaError bool
+	}{
+		{"Por defecto", ConfigSMTP{Host: "smtp", Usuario: "usuario"}, false},
+		{"Relay sin autenticación", ConfigSMTP{Host: "relay", Seguridad: SeguridadNinguna, Autenticacion: AuthNinguna}, false},
+		{"Seguridad desconocida", ConfigSMTP{Host: "smtp", Usuario: "usuario", Seguridad: "SSLv3"}, true},
+		{"Autenticación desconocida", ConfigSMTP{Host: "smtp", Usuario: "usuario", Autenticacion: "NTLM"}, true},
+		{"CRAM-MD5 sin usuario", ConfigSMTP{Host: "smtp", Autenticacion: AuthCRAMMD5}, true},
+		{"CA inexistente", ConfigSMTP{Host: "smtp", Usuario: "usuario", ArchivoCA: "/no/existe.pem"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.nombre, func(t *testing.T) {
+			err := tc.config.Validar()
+			if tc.esperaError && err == nil {
+				t.Errorf("Se esperaba un error para el caso: %s", tc.nombre)
+			}
+			if !tc.esperaError && err != nil {
+				t.Errorf("No se esperaba un error para el caso: %s. Error: %v", tc.nombre, err)
+			}
+		})
+	}
+
+	if puerto := (ConfigSMTP{Seguridad: SeguridadSTARTTLS}).puerto(); puerto != 587 {
+		t.Errorf("Se esperaba el puerto 587 para STARTTLS, obtenido %d", puerto)
+	}
+}
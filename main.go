@@ -1,27 +1,274 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"transport-challenge/config"
+	"transport-challenge/internal/application"
+	"transport-challenge/internal/domain"
+	"transport-challenge/internal/infrastructure/events"
+	transporthttp "transport-challenge/internal/infrastructure/http"
 	"transport-challenge/internal/infrastructure/persistence"
+	"transport-challenge/internal/infrastructure/webhook"
+	"transport-challenge/internal/notification"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// notificationHistorySize es la cantidad de envíos recientes que se conservan en el historial
+	notificationHistorySize = 10000
+
+	// shutdownTimeout limita la espera de los pedidos en curso y de los envíos pendientes al detener el servidor
+	shutdownTimeout = 30 * time.Second
 )
 
 func main() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		log.Fatalf("Error starting application: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: a.server.Router,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Iniciando servidor en %s...", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Printf("Error serving HTTP: %v", err)
+	case <-ctx.Done():
+		log.Printf("Deteniendo servidor...")
+	}
+
+	// Primero se dejan de aceptar pedidos y se esperan los que están en curso, para que
+	// ningún cambio de compra genere notificaciones después de detener su envío
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	db := persistence.NewDatabase()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+	if err := a.Close(shutdownCtx); err != nil {
+		log.Fatalf("Error stopping application: %v", err)
+	}
+}
 
-	routeRepo := persistence.NewRouteRepository
+// app reúne los componentes del servidor armados según la configuración
+type app struct {
+	server   *transporthttp.Server
+	bus      *events.Bus
+	webhooks *webhook.Manager
+	pool     *notification.PoolNotificaciones // Solo con NOTIFICATION_DELIVERY=pool
 
-	id, err := routeRepo.CreateRoute("Route 1")
+	stopDispatcher func(context.Context) error // Solo con NOTIFICATION_DELIVERY=outbox
+	stopDigest     func(context.Context) error // Solo con NOTIFICATION_DISPATCHERS
+
+	closeRepo func() error
+}
+
+// newApp abre el repositorio y arma los servicios y el servidor HTTP. Las notificaciones
+// de las compras se entregan según cfg.Notifications.Delivery; el despachador de la
+// bandeja de salida o el pool, y el agrupador de resúmenes de los despachantes, corren
+// hasta Close.
+func newApp(cfg *config.Config) (*app, error) {
+	routeRepo, closeRepo, err := newRouteRepository(cfg.Database)
 	if err != nil {
-		fmt.Println("Error creating route:", err)
-		return
+		return nil, fmt.Errorf("failed to open route repository: %w", err)
 	}
-	fmt.Printf("Created route with ID: %d\n", id)
+	a := &app{closeRepo: closeRepo}
+
+	history := notification.NuevoHistorialMemoria(notificationHistorySize)
+	contacts := notification.NuevoAlmacenContactosMemoria()
+	notificationConfig := notification.CargarConfiguracionDesdeVariablesEntorno()
+	notifications := notification.NuevoServicioNotificaciones(
+		notificationConfig,
+		notification.ConHistorial(history),
+		notification.ConContactos(contacts),
+	)
+
+	// Los webhooks se numeran en el orden de publicación y se entregan en segundo plano
+	a.bus = events.NewBus()
+	a.webhooks = webhook.NewManager(webhook.NewInMemoryStore(), webhook.DefaultConfig())
+	a.bus.SubscribeAll(a.webhooks.Enqueue, events.Sync)
+
+	serviceOpts := []application.RouteServiceOption{
+		application.WithEventPublisher(a.bus),
+		application.WithTranslator(notifications),
+	}
+
+	// Los despachantes reciben una copia de cada notificación de compra, agrupada en un
+	// único resumen por ventana en lugar de un email por compra
+	var sender notification.NotificadorPorCanal = notifications
+	if len(cfg.Notifications.Dispatchers) > 0 {
+		digestConfig := notification.ConfigResumenPorDefecto()
+		digestConfig.Ventana = cfg.Notifications.DigestWindow
+		digest := notification.NuevoAgrupadorResumen(notifications, digestConfig, notifications.Plantillas())
+		a.stopDigest = runUntilStopped(digest.Iniciar)
+
+		sender = notification.NuevoDerivadorResumen(notifications, digest, cfg.Notifications.Dispatchers)
+		serviceOpts = append(serviceOpts, application.WithDispatchers(cfg.Notifications.Dispatchers...))
+	}
+	serverOpts := []transporthttp.ServerOption{
+		transporthttp.WithChannelMonitor(notifications),
+		transporthttp.WithTemplateReloader(notifications),
+		transporthttp.WithPreferences(contacts),
+		transporthttp.WithPhoneCountryCode(notificationConfig.ConfiguracionSMS.CodigoPaisPorDefecto),
+		transporthttp.WithNotificationHistory(history),
+		transporthttp.WithWebhooks(a.webhooks),
+	}
+
+	switch cfg.Notifications.Delivery {
+	case config.NotificationDeliveryPool:
+		// Sin persistencia: una caída pierde las notificaciones encoladas
+		a.pool = notification.NuevoPoolNotificaciones(sender, notification.ConfigPoolPorDefecto())
+		serviceOpts = append(serviceOpts, application.WithNotifier(a.pool))
+
+	default:
+		// Las notificaciones se guardan junto al cambio de la compra y el despachador las envía
+		store, ok := routeRepo.(domain.OutboxStore)
+		if !ok {
+			a.Close(context.Background())
+			return nil, fmt.Errorf("route repository %T does not store an outbox", routeRepo)
+		}
+
+		outbox := application.NewNotificationOutbox(store)
+		dispatcher := notification.NuevoDespachadorBandeja(outbox, sender, notification.ConfigDespachadorPorDefecto())
+		a.stopDispatcher = runUntilStopped(dispatcher.Iniciar)
 
-	routes, err := routeRepo.GetAllRoutes()
+		serviceOpts = append(serviceOpts, application.WithOutbox())
+		serverOpts = append(serverOpts, transporthttp.WithOutbox(outbox))
+	}
+
+	routeService := application.NewRouteService(routeRepo, serviceOpts...)
+	a.server = transporthttp.NewServer(routeService, serverOpts...)
+
+	return a, nil
+}
+
+// Close detiene el envío de notificaciones y cierra el repositorio, en ese orden: el
+// despachador termina el lote en curso (que usa el repositorio), el pool envía las
+// notificaciones encoladas, el agrupador envía los resúmenes pendientes y el bus completa
+// las entregas de webhooks. Si ctx vence antes, el repositorio se cierra igual y se
+// devuelve el error del contexto.
+func (a *app) Close(ctx context.Context) error {
+	var stopErr error
+
+	if a.stopDispatcher != nil {
+		if err := a.stopDispatcher(ctx); err != nil {
+			stopErr = fmt.Errorf("outbox dispatcher did not stop: %w", err)
+		}
+	}
+
+	if a.pool != nil {
+		if err := a.pool.Detener(ctx); err != nil && stopErr == nil {
+			stopErr = fmt.Errorf("notification pool did not drain: %w", err)
+		}
+	}
+
+	if a.stopDigest != nil {
+		if err := a.stopDigest(ctx); err != nil && stopErr == nil {
+			stopErr = fmt.Errorf("notification digests were not sent: %w", err)
+		}
+	}
+
+	if err := waitContext(ctx, a.waitEvents); err != nil && stopErr == nil {
+		stopErr = fmt.Errorf("webhook deliveries did not finish: %w", err)
+	}
+
+	if err := a.closeRepo(); err != nil {
+		return fmt.Errorf("failed to close route repository: %w", err)
+	}
+
+	return stopErr
+}
+
+// waitEvents espera a los suscriptores asíncronos del bus y las entregas de webhooks
+func (a *app) waitEvents() {
+	a.bus.Wait()
+	a.webhooks.Wait()
+}
+
+// runUntilStopped corre run en segundo plano y devuelve la función que cancela su contexto
+// y espera a que termine, o a que venza el contexto de la espera
+func runUntilStopped(run func(ctx context.Context)) func(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	return func(wait context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-wait.Done():
+			return wait.Err()
+		}
+	}
+}
+
+// waitContext ejecuta wait y devuelve el error de ctx si vence antes de que termine
+func waitContext(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newRouteRepository abre el repositorio del driver configurado: un write-ahead log
+// local con el driver file, o la base SQL con sus migraciones pendientes aplicadas
+func newRouteRepository(cfg config.DatabaseConfig) (domain.RouteRepository, func() error, error) {
+	if cfg.Driver == config.DriverFile {
+		repo, err := persistence.NewFileRouteRepository(cfg.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	}
+
+	db, err := persistence.OpenDatabase(cfg)
 	if err != nil {
-		fmt.Println("Error getting routes:", err)
-		return
+		return nil, nil, err
+	}
+
+	// El bloqueo de migraciones evita que dos instancias las apliquen a la vez
+	if _, err := persistence.NewMigrator(db, cfg.Driver).Up(0); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
-	fmt.Println("All Routes:", routes)
+
+	return persistence.NewSQLRouteRepository(db), db.Close, nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"transport-challenge/config"
+	"transport-challenge/internal/notification"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApp(t *testing.T, delivery string, dispatchers ...string) *app {
+	t.Helper()
+
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Driver: config.DriverSQLite, Name: filepath.Join(t.TempDir(), "transporte.db")},
+		Server:   config.ServerConfig{Port: 8080},
+		Notifications: config.NotificationsConfig{
+			Delivery:     delivery,
+			Dispatchers:  dispatchers,
+			DigestWindow: time.Hour,
+		},
+	}
+
+	a, err := newApp(cfg)
+	require.NoError(t, err)
+	return a
+}
+
+// assignPurchases crea una ruta y le asigna compras con destinatario, lo que genera una notificación por compra
+func assignPurchases(t *testing.T, a *app, count int) {
+	t.Helper()
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		a.server.Router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve("POST", "/routes", `{"name": "Zona Norte", "vehicle": "AB123CD", "driver": "Juan Pérez"}`)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+
+	for id := 10; id < 10+count; id++ {
+		body := fmt.Sprintf(`{"id": %d, "description": "Heladera", "recipient": "cliente@ejemplo.com"}`, id)
+		recorder = serve("POST", "/routes/1/purchases", body)
+		require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestNewAppDeliversThroughThePool(t *testing.T) {
+	a := newTestApp(t, config.NotificationDeliveryPool)
+	require.NotNil(t, a.pool)
+
+	assignPurchases(t, a, 1)
+
+	require.NoError(t, a.Close(context.Background()))
+	stats := a.pool.Estadisticas()
+	assert.Equal(t, uint64(1), stats.Enviadas+stats.Fallidas, "la notificación debe pasar por el pool: %+v", stats)
+
+	req := httptest.NewRequest("GET", "/notifications/outbox", nil)
+	recorder := httptest.NewRecorder()
+	a.server.Router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestNewAppDeliversThroughTheOutbox(t *testing.T) {
+	a := newTestApp(t, config.NotificationDeliveryOutbox)
+	assert.Nil(t, a.pool)
+
+	assignPurchases(t, a, 1)
+
+	req := httptest.NewRequest("GET", "/notifications/outbox", nil)
+	recorder := httptest.NewRecorder()
+	a.server.Router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var entries []notification.EntradaBandeja
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, 10, entries[0].Notificacion.IDCompra)
+
+	require.NotNil(t, a.stopDispatcher)
+	require.NoError(t, a.Close(context.Background()))
+}
+
+func TestNewAppSendsDispatchersOneDigest(t *testing.T) {
+	// Sin servidor SMTP el envío falla, pero el historial registra a quién se intentó enviar
+	t.Setenv("EMAIL_HABILITADO", "true")
+	a := newTestApp(t, config.NotificationDeliveryPool, "despacho@ejemplo.com")
+	require.NotNil(t, a.stopDigest)
+
+	assignPurchases(t, a, 3)
+	require.NoError(t, a.Close(context.Background()))
+
+	req := httptest.NewRequest("GET", "/notifications?recipient=despacho@ejemplo.com", nil)
+	recorder := httptest.NewRecorder()
+	a.server.Router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var records []notification.RegistroEnvio
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &records))
+	require.NotEmpty(t, records)
+
+	purchases := map[int]bool{}
+	for _, record := range records {
+		assert.True(t, record.EnResumen, "el despachante solo debe recibir el resumen: %+v", record)
+		purchases[record.IDCompra] = true
+	}
+	assert.Len(t, purchases, 3)
+}